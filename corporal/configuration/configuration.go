@@ -16,6 +16,7 @@ type Configuration struct {
 	HttpApi        HttpApi
 	HttpGateway    HttpGateway
 	PolicyProvider PolicyProvider
+	Hooks          Hooks
 	Misc           Misc
 }
 
@@ -69,6 +70,14 @@ type Misc struct {
 
 type PolicyProvider map[string]interface{}
 
+// Hooks contains configuration for hooks defined outside of the policy
+type Hooks struct {
+	// Path specifies a file (or a directory of `*.json` files) to load additional hooks from.
+	// These hooks are merged with the policy's own hooks and get reloaded whenever they change.
+	// If empty, no hooks are loaded this way.
+	Path string
+}
+
 func LoadConfiguration(filePath string, logger *logrus.Logger) (*Configuration, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
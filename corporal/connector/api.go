@@ -283,6 +283,183 @@ func (me *ApiConnector) GetUserAccountDataContentByType(
 	return accountData, nil
 }
 
+func (me *ApiConnector) SetUserAccountDataContentByType(
+	ctx *AccessTokenContext,
+	userId string,
+	accountDataType string,
+	content map[string]interface{},
+) error {
+	client, err := me.createMatrixClientForUserId(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "user.set_account_data", func() error {
+		return client.MakeRequest(
+			"PUT",
+			client.BuildURL(
+				fmt.Sprintf("/user/%s/account_data/%s", userId, accountDataType),
+			),
+			content,
+			nil,
+		)
+	})
+}
+
+func (me *ApiConnector) GetUserRoomTags(
+	ctx *AccessTokenContext,
+	userId string,
+	roomId string,
+) (map[string]matrix.RoomTag, error) {
+	client, err := me.createMatrixClientForUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var response matrix.ApiRoomTagsResponse
+	err = client.MakeRequest(
+		"GET",
+		client.BuildURL("user", userId, "rooms", roomId, "tags"),
+		nil,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Tags == nil {
+		return map[string]matrix.RoomTag{}, nil
+	}
+
+	return response.Tags, nil
+}
+
+func (me *ApiConnector) SetUserRoomTag(
+	ctx *AccessTokenContext,
+	userId string,
+	roomId string,
+	tag string,
+	content matrix.RoomTag,
+) error {
+	client, err := me.createMatrixClientForUserId(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "user.set_room_tag", func() error {
+		return client.MakeRequest(
+			"PUT",
+			client.BuildURL("user", userId, "rooms", roomId, "tags", tag),
+			content,
+			nil,
+		)
+	})
+}
+
+func (me *ApiConnector) RemoveUserRoomTag(
+	ctx *AccessTokenContext,
+	userId string,
+	roomId string,
+	tag string,
+) error {
+	client, err := me.createMatrixClientForUserId(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "user.remove_room_tag", func() error {
+		return client.MakeRequest(
+			"DELETE",
+			client.BuildURL("user", userId, "rooms", roomId, "tags", tag),
+			nil,
+			nil,
+		)
+	})
+}
+
+func (me *ApiConnector) GetUserPushRules(ctx *AccessTokenContext, userId string) ([]CurrentUserPushRuleState, error) {
+	client, err := me.createMatrixClientForUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var response matrix.ApiPushRulesResponse
+	err = client.MakeRequest(
+		"GET",
+		// The trailing slash is required by the spec
+		client.BuildURL("pushrules")+"/",
+		nil,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var pushRules []CurrentUserPushRuleState
+	for kind, rules := range response.Global {
+		for _, rule := range rules {
+			pushRules = append(pushRules, CurrentUserPushRuleState{
+				Kind:       kind,
+				RuleId:     rule.RuleId,
+				Enabled:    rule.Enabled,
+				Actions:    rule.Actions,
+				Conditions: rule.Conditions,
+				Pattern:    rule.Pattern,
+			})
+		}
+	}
+
+	return pushRules, nil
+}
+
+// SetUserPushRule creates or updates a push rule in the user's `global` scope.
+//
+// Server-default rules (e.g. `.m.rule.master`) cannot be created or replaced, so only their actions are updated.
+func (me *ApiConnector) SetUserPushRule(ctx *AccessTokenContext, userId string, pushRule CurrentUserPushRuleState) error {
+	client, err := me.createMatrixClientForUserId(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	err = matrix.ExecuteWithRateLimitRetries(me.logger, "user.set_push_rule", func() error {
+		if pushRule.IsServerDefault() {
+			return client.MakeRequest(
+				"PUT",
+				client.BuildURL("pushrules", "global", pushRule.Kind, pushRule.RuleId, "actions"),
+				matrix.ApiPushRuleActionsRequestPayload{
+					Actions: pushRule.Actions,
+				},
+				nil,
+			)
+		}
+
+		return client.MakeRequest(
+			"PUT",
+			client.BuildURL("pushrules", "global", pushRule.Kind, pushRule.RuleId),
+			matrix.ApiPushRuleRequestPayload{
+				Actions:    pushRule.Actions,
+				Conditions: pushRule.Conditions,
+				Pattern:    pushRule.Pattern,
+			},
+			nil,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "user.set_push_rule_enabled", func() error {
+		return client.MakeRequest(
+			"PUT",
+			client.BuildURL("pushrules", "global", pushRule.Kind, pushRule.RuleId, "enabled"),
+			matrix.ApiPushRuleEnabledRequestPayload{
+				Enabled: pushRule.Enabled,
+			},
+			nil,
+		)
+	})
+}
+
 func (me *ApiConnector) GetUserProfileByUserId(ctx *AccessTokenContext, userId string) (*matrix.ApiUserProfileResponse, error) {
 	client, err := me.createMatrixClientForUserId(ctx, userId)
 	if err != nil {
@@ -424,6 +601,108 @@ func (me *ApiConnector) SetUserDisplayName(
 	})
 }
 
+func (me *ApiConnector) GetUserThreepids(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+) ([]CurrentUserThreepidState, error) {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (me *ApiConnector) SetUserThreepids(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	threepids []CurrentUserThreepidState,
+) error {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	// Adding 3pids via the Client-Server API requires them to be validated by the user.
+	return fmt.Errorf("not implemented")
+}
+
+func (me *ApiConnector) SetUserServerAdmin(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	isServerAdmin bool,
+) error {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	return fmt.Errorf("not implemented")
+}
+
+func (me *ApiConnector) GetUserRateLimitOverride(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+) (*CurrentUserRateLimitOverrideState, error) {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (me *ApiConnector) SetUserRateLimitOverride(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	rateLimitOverride *CurrentUserRateLimitOverrideState,
+) error {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	return fmt.Errorf("not implemented")
+}
+
+func (me *ApiConnector) GetUserDevices(userId string) ([]CurrentUserDeviceState, error) {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	// Listing devices via the Client-Server API requires impersonating the user and wouldn't tell us about other users' devices.
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (me *ApiConnector) DeleteUserDevices(userId string, deviceIds []string) error {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	// Deleting devices via the Client-Server API requires User-Interactive Authentication.
+	return fmt.Errorf("not implemented")
+}
+
+func (me *ApiConnector) LogoutAllDevicesForUser(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+) error {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	// Deleting devices via the Client-Server API requires User-Interactive Authentication.
+	return fmt.Errorf("not implemented")
+}
+
+func (me *ApiConnector) RedactUserMessages(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	limit int,
+) error {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	return fmt.Errorf("not implemented")
+}
+
+func (me *ApiConnector) DeactivateUserOnHomeserver(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	erase bool,
+) error {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	// Deactivation via the Client-Server API requires User-Interactive Authentication.
+	return fmt.Errorf("not implemented")
+}
+
+func (me *ApiConnector) ReactivateUserOnHomeserver(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	password string,
+) error {
+	// This cannot be implemented using standard (implementation-agnostic) Client-Server APIs.
+	return fmt.Errorf("not implemented")
+}
+
 func (me *ApiConnector) InviteUserToRoom(
 	ctx *AccessTokenContext,
 	inviterId string,
@@ -524,6 +803,62 @@ func (me *ApiConnector) KickUserFromRoom(
 	})
 }
 
+func (me *ApiConnector) BanUserFromRoom(
+	ctx *AccessTokenContext,
+	bannerUserId string,
+	banneeUserId string,
+	roomId string,
+) error {
+	if bannerUserId == banneeUserId {
+		return fmt.Errorf("banning self (%s) does not make sense", bannerUserId)
+	}
+
+	client, err := me.createMatrixClientForUserId(ctx, bannerUserId)
+	if err != nil {
+		return err
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "room.ban", func() error {
+		// This request is idempotent.
+		_, err := client.BanUser(roomId, &gomatrix.ReqBanUser{
+			UserID: banneeUserId,
+		})
+		return err
+	})
+}
+
+// GetRoomMembers returns the current members of the room (all memberships other than `leave`), as seen by the given user
+func (me *ApiConnector) GetRoomMembers(
+	ctx *AccessTokenContext,
+	userId string,
+	roomId string,
+) ([]CurrentRoomMemberState, error) {
+	client, err := me.createMatrixClientForUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var response matrix.ApiRoomMembersResponse
+	err = client.MakeRequest("GET", client.BuildURL("rooms", roomId, "members"), nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]CurrentRoomMemberState, 0, len(response.Chunk))
+	for _, event := range response.Chunk {
+		if event.Content.Membership == matrix.MembershipLeave {
+			continue
+		}
+
+		members = append(members, CurrentRoomMemberState{
+			UserId:     event.StateKey,
+			Membership: event.Content.Membership,
+		})
+	}
+
+	return members, nil
+}
+
 func (me *ApiConnector) LeaveRoom(
 	ctx *AccessTokenContext,
 	userId string,
@@ -546,6 +881,20 @@ func (me *ApiConnector) UpdateRoomUserPowerLevel(
 	ownerId string,
 	roomPowerForUserId map[string]int,
 	roomId string,
+) error {
+	return me.UpdateRoomPowerLevels(ctx, ownerId, roomId, roomPowerForUserId, nil)
+}
+
+// UpdateRoomPowerLevels merges user power levels and room-wide power levels (if any) into the room's
+// existing `m.room.power_levels` state event and sends it as a single update.
+//
+// Fields which are not mentioned in roomPowerForUserId and roomPowerLevels are left untouched.
+func (me *ApiConnector) UpdateRoomPowerLevels(
+	ctx *AccessTokenContext,
+	ownerId string,
+	roomId string,
+	roomPowerForUserId map[string]int,
+	roomPowerLevels *matrix.RoomPowerLevelsContent,
 ) error {
 	client, err := me.createMatrixClientForUserId(ctx, ownerId)
 	if err != nil {
@@ -567,12 +916,99 @@ func (me *ApiConnector) UpdateRoomUserPowerLevel(
 		}
 	}
 
+	if roomPowerLevels != nil {
+		err = mergeRoomPowerLevelsIntoContent(jsonObj, *roomPowerLevels)
+		if err != nil {
+			return err
+		}
+	}
+
 	return matrix.ExecuteWithRateLimitRetries(me.logger, "room.powerLevel", func() error {
 		_, err = client.SendStateEvent(roomId, "m.room.power_levels", "", jsonObj.Data())
 		return err
 	})
 }
 
+// GetRoomPowerLevels returns the room-wide power levels of the given room, as seen by the given user
+func (me *ApiConnector) GetRoomPowerLevels(
+	ctx *AccessTokenContext,
+	userId string,
+	roomId string,
+) (*matrix.RoomPowerLevelsContent, error) {
+	client, err := me.createMatrixClientForUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var content matrix.RoomPowerLevelsContent
+	err = client.StateEvent(roomId, "m.room.power_levels", "", &content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &content, nil
+}
+
+func mergeRoomPowerLevelsIntoContent(jsonObj *gabs.Container, roomPowerLevels matrix.RoomPowerLevelsContent) error {
+	fields := map[string]*int{
+		"events_default": roomPowerLevels.EventsDefault,
+		"state_default":  roomPowerLevels.StateDefault,
+		"invite":         roomPowerLevels.Invite,
+		"kick":           roomPowerLevels.Kick,
+		"ban":            roomPowerLevels.Ban,
+		"redact":         roomPowerLevels.Redact,
+	}
+
+	for field, value := range fields {
+		if value == nil {
+			continue
+		}
+
+		_, err := jsonObj.Set(*value, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	for eventType, powerLevel := range roomPowerLevels.Events {
+		_, err := jsonObj.Set(powerLevel, "events", eventType)
+		if err != nil {
+			return err
+		}
+	}
+
+	for key, powerLevel := range roomPowerLevels.Notifications {
+		_, err := jsonObj.Set(powerLevel, "notifications", key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResolveRoomAlias resolves a room alias (e.g. `#room:example.com`) to a room id, via the room directory.
+//
+// Room alias lookups do not require authentication, so no access token is involved.
+func (me *ApiConnector) ResolveRoomAlias(roomAlias string) (string, error) {
+	client, err := me.createMatrixClientForUserIdAndToken("", "")
+	if err != nil {
+		return "", err
+	}
+
+	var response matrix.ApiDirectoryRoomResponse
+	err = client.MakeRequest("GET", client.BuildURL("directory", "room", roomAlias), nil, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if response.RoomId == "" {
+		return "", fmt.Errorf("no room id in directory response")
+	}
+
+	return response.RoomId, nil
+}
+
 // createMatrixClientForUserId gets an access token (reuses or obtains a new one) for the user
 // and creates an API client with it
 func (me *ApiConnector) createMatrixClientForUserId(
@@ -17,11 +17,35 @@ type MatrixConnector interface {
 	EnsureUserAccountExists(userId, password string) error
 
 	GetUserProfileByUserId(ctx *AccessTokenContext, userId string) (*matrix.ApiUserProfileResponse, error)
+	GetUserAccountDataContentByType(ctx *AccessTokenContext, userId string, accountDataType string) (map[string]interface{}, error)
+	SetUserAccountDataContentByType(ctx *AccessTokenContext, userId string, accountDataType string, content map[string]interface{}) error
+	GetUserRoomTags(ctx *AccessTokenContext, userId string, roomId string) (map[string]matrix.RoomTag, error)
+	SetUserRoomTag(ctx *AccessTokenContext, userId string, roomId string, tag string, content matrix.RoomTag) error
+	RemoveUserRoomTag(ctx *AccessTokenContext, userId string, roomId string, tag string) error
+	GetUserPushRules(ctx *AccessTokenContext, userId string) ([]CurrentUserPushRuleState, error)
+	SetUserPushRule(ctx *AccessTokenContext, userId string, pushRule CurrentUserPushRuleState) error
 	SetUserDisplayName(ctx *AccessTokenContext, userId string, displayName string) error
 	SetUserAvatar(ctx *AccessTokenContext, userId string, avatar *avatar.Avatar) error
+	GetUserThreepids(ctx *AccessTokenContext, adminUserId string, userId string) ([]CurrentUserThreepidState, error)
+	SetUserThreepids(ctx *AccessTokenContext, adminUserId string, userId string, threepids []CurrentUserThreepidState) error
+	SetUserServerAdmin(ctx *AccessTokenContext, adminUserId string, userId string, isServerAdmin bool) error
+	GetUserRateLimitOverride(ctx *AccessTokenContext, adminUserId string, userId string) (*CurrentUserRateLimitOverrideState, error)
+	SetUserRateLimitOverride(ctx *AccessTokenContext, adminUserId string, userId string, rateLimitOverride *CurrentUserRateLimitOverrideState) error
+	GetUserDevices(userId string) ([]CurrentUserDeviceState, error)
+	DeleteUserDevices(userId string, deviceIds []string) error
+	LogoutAllDevicesForUser(ctx *AccessTokenContext, adminUserId string, userId string) error
+	RedactUserMessages(ctx *AccessTokenContext, adminUserId string, userId string, limit int) error
+	DeactivateUserOnHomeserver(ctx *AccessTokenContext, adminUserId string, userId string, erase bool) error
+	ReactivateUserOnHomeserver(ctx *AccessTokenContext, adminUserId string, userId string, password string) error
 
 	InviteUserToRoom(ctx *AccessTokenContext, inviterId string, inviteeId string, roomId string) error
 	UpdateRoomUserPowerLevel(ctx *AccessTokenContext, updaterId string, roomPowerForUserId map[string]int, roomId string) error
+	UpdateRoomPowerLevels(ctx *AccessTokenContext, updaterId string, roomId string, roomPowerForUserId map[string]int, roomPowerLevels *matrix.RoomPowerLevelsContent) error
+	GetRoomPowerLevels(ctx *AccessTokenContext, userId string, roomId string) (*matrix.RoomPowerLevelsContent, error)
 	JoinRoom(ctx *AccessTokenContext, userId string, roomId string) error
 	LeaveRoom(ctx *AccessTokenContext, userId string, roomId string) error
+	KickUserFromRoom(ctx *AccessTokenContext, kickerUserId string, kickeeUserId string, roomId string) error
+	BanUserFromRoom(ctx *AccessTokenContext, bannerUserId string, banneeUserId string, roomId string) error
+	GetRoomMembers(ctx *AccessTokenContext, userId string, roomId string) ([]CurrentRoomMemberState, error)
+	ResolveRoomAlias(roomAlias string) (string, error)
 }
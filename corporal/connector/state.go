@@ -1,7 +1,16 @@
 package connector
 
+import (
+	"devture-matrix-corporal/corporal/matrix"
+	"sort"
+	"strings"
+)
+
 type CurrentState struct {
 	Users []CurrentUserState `json:"users"`
+
+	// Rooms contains the state of rooms we're interested in (not necessarily all managed rooms)
+	Rooms []CurrentRoomState `json:"rooms"`
 }
 
 func (me *CurrentState) GetUserStateByUserId(userId string) *CurrentUserState {
@@ -13,11 +22,47 @@ func (me *CurrentState) GetUserStateByUserId(userId string) *CurrentUserState {
 	return nil
 }
 
+func (me *CurrentState) GetRoomStateByRoomId(roomId string) *CurrentRoomState {
+	for _, roomState := range me.Rooms {
+		if roomState.RoomId == roomId {
+			return &roomState
+		}
+	}
+	return nil
+}
+
+type CurrentRoomState struct {
+	RoomId string `json:"roomId"`
+
+	// Members is only populated for rooms whose membership we're interested in (exclusive rooms)
+	Members []CurrentRoomMemberState `json:"members"`
+
+	// PowerLevels is only populated for rooms whose power levels we're interested in
+	PowerLevels *matrix.RoomPowerLevelsContent `json:"powerLevels"`
+}
+
+type CurrentRoomMemberState struct {
+	UserId string `json:"userId"`
+
+	// Membership is one of the `matrix.Membership*` constants
+	Membership string `json:"membership"`
+}
+
 type CurrentUserRoomState struct {
 	RoomId     string `json:"roomId"`
 	PowerLevel int    `json:"powerLevel"`
 }
 
+type CurrentUserThreepidState struct {
+	Medium  string `json:"medium"`
+	Address string `json:"address"`
+}
+
+type CurrentUserRateLimitOverrideState struct {
+	MessagesPerSecond int `json:"messagesPerSecond"`
+	BurstCount        int `json:"burstCount"`
+}
+
 type CurrentUserState struct {
 	Id                  string                 `json:"id"`
 	Active              bool                   `json:"active"`
@@ -25,4 +70,79 @@ type CurrentUserState struct {
 	AvatarMxcUri        string                 `json:"avatarMxcUri"`
 	AvatarSourceUriHash string                 `json:"avatarSourceUriHash"`
 	JoinedRooms         []CurrentUserRoomState `json:"joinedRooms"`
+
+	// Threepids is only populated by connectors which support it (like SynapseConnector)
+	// and only for users whose 3pids the policy manages.
+	Threepids []CurrentUserThreepidState `json:"threepids"`
+
+	// IsServerAdmin is only populated by connectors which support it (like SynapseConnector)
+	IsServerAdmin bool `json:"isServerAdmin"`
+
+	// RateLimitOverride is only populated by connectors which support it (like SynapseConnector)
+	// and only for users whose rate limit override the policy manages.
+	// A nil value means there's no override and the homeserver's default rate limits apply.
+	RateLimitOverride *CurrentUserRateLimitOverrideState `json:"rateLimitOverride"`
+
+	// IsDeactivatedOnHomeserver tells whether the user account has been deactivated via the homeserver's own deactivation API
+	// (as opposed to our regular display-name-marker-based deactivation).
+	// It's only populated by connectors which support it (like SynapseConnector).
+	IsDeactivatedOnHomeserver bool `json:"isDeactivatedOnHomeserver"`
+
+	// AccountData contains the user's global account data (keyed by type).
+	// Only account data types that the policy manages are populated. Missing account data has empty content.
+	AccountData map[string]map[string]interface{} `json:"accountData"`
+
+	// RoomTags contains the user's room tags (keyed by room id).
+	// Only rooms for which the policy manages tags are populated.
+	RoomTags map[string]map[string]matrix.RoomTag `json:"roomTags"`
+
+	// PushRules contains the user's push rules (in the `global` scope).
+	// It's only populated for users whose push rules the policy manages.
+	PushRules []CurrentUserPushRuleState `json:"pushRules"`
+
+	// Devices contains the user's devices.
+	// It's only populated for users whose devices the policy restricts
+	// and only by connectors which support it (like SynapseConnector).
+	Devices []CurrentUserDeviceState `json:"devices"`
+}
+
+type CurrentUserDeviceState struct {
+	DeviceId    string `json:"deviceId"`
+	DisplayName string `json:"displayName"`
+
+	// LastSeenTs is a timestamp (in milliseconds). It's 0 for devices which have never been seen.
+	LastSeenTs int64 `json:"lastSeenTs"`
+}
+
+type CurrentUserPushRuleState struct {
+	Kind       string                   `json:"kind"`
+	RuleId     string                   `json:"ruleId"`
+	Enabled    bool                     `json:"enabled"`
+	Actions    []interface{}            `json:"actions"`
+	Conditions []map[string]interface{} `json:"conditions"`
+	Pattern    string                   `json:"pattern"`
+}
+
+// IsServerDefault tells whether this is a server-default rule (e.g. `.m.rule.master`)
+func (me CurrentUserPushRuleState) IsServerDefault() bool {
+	return strings.HasPrefix(me.RuleId, ".")
+}
+
+// DetermineLeastRecentlySeenDevices returns up to `count` devices, starting from the ones which have been seen least recently
+func DetermineLeastRecentlySeenDevices(devices []CurrentUserDeviceState, count int) []CurrentUserDeviceState {
+	sortedDevices := make([]CurrentUserDeviceState, len(devices))
+	copy(sortedDevices, devices)
+
+	sort.SliceStable(sortedDevices, func(i, j int) bool {
+		if sortedDevices[i].LastSeenTs == sortedDevices[j].LastSeenTs {
+			return sortedDevices[i].DeviceId < sortedDevices[j].DeviceId
+		}
+		return sortedDevices[i].LastSeenTs < sortedDevices[j].LastSeenTs
+	})
+
+	if count > len(sortedDevices) {
+		count = len(sortedDevices)
+	}
+
+	return sortedDevices[:count]
 }
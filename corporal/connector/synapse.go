@@ -158,8 +158,16 @@ func (me *SynapseConnector) DetermineCurrentState(
 	}
 
 	var currentUserIds []string
+	serverAdminUserIds := map[string]bool{}
+	deactivatedUsersById := map[string]matrix.ApiAdminEntityUser{}
 	for _, user := range response.Users {
 		currentUserIds = append(currentUserIds, user.Id)
+		if user.Admin {
+			serverAdminUserIds[user.Id] = true
+		}
+		if user.Deactivated {
+			deactivatedUsersById[user.Id] = user
+		}
 	}
 
 	var usersState []CurrentUserState
@@ -172,10 +180,28 @@ func (me *SynapseConnector) DetermineCurrentState(
 			continue
 		}
 
+		if deactivatedUser, isDeactivated := deactivatedUsersById[userId]; isDeactivated {
+			// Users deactivated via the homeserver's deactivation API cannot be impersonated,
+			// so there's no way (and no need) to find out more about them.
+			// Such deactivation makes them leave all rooms anyway.
+			usersState = append(usersState, CurrentUserState{
+				Id:                        userId,
+				Active:                    false,
+				DisplayName:               matrix.CleanDeactivationMarkerFromDisplayName(deactivatedUser.DisplayName),
+				AvatarMxcUri:              deactivatedUser.AvatarURL,
+				JoinedRooms:               []CurrentUserRoomState{},
+				IsServerAdmin:             serverAdminUserIds[userId],
+				IsDeactivatedOnHomeserver: true,
+			})
+			continue
+		}
+
 		userState, err := me.getUserStateByUserId(ctx, userId)
 		if err != nil {
 			return nil, err
 		}
+
+		userState.IsServerAdmin = serverAdminUserIds[userId]
 		usersState = append(usersState, *userState)
 	}
 
@@ -261,10 +287,380 @@ func (me *SynapseConnector) EnsureUserAccountExists(userId, password string) err
 	return nil
 }
 
+// SetUserThreepids replaces all 3pids (email addresses, phone numbers) of the given user with the ones provided.
+//
+// Unlike the Client-Server API, the Synapse admin API lets us do this without the user validating these 3pids.
+func (me *SynapseConnector) SetUserThreepids(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	threepids []CurrentUserThreepidState,
+) error {
+	client, err := me.createMatrixClientForUserId(ctx, adminUserId)
+	if err != nil {
+		return err
+	}
+
+	payload := matrix.ApiAdminUserThreepidsUpdateRequestPayload{
+		Threepids: make([]matrix.ApiAdminEntityThreepid, 0, len(threepids)),
+	}
+	for _, threepid := range threepids {
+		payload.Threepids = append(payload.Threepids, matrix.ApiAdminEntityThreepid{
+			Medium:  threepid.Medium,
+			Address: threepid.Address,
+		})
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "user.set_threepids", func() error {
+		return client.MakeRequest(
+			"PUT",
+			buildPrefixlessURL(client, fmt.Sprintf("/_synapse/admin/v2/users/%s", userId), map[string]string{}),
+			payload,
+			nil,
+		)
+	})
+}
+
+// SetUserServerAdmin promotes the given user to a homeserver administrator or demotes them.
+func (me *SynapseConnector) SetUserServerAdmin(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	isServerAdmin bool,
+) error {
+	client, err := me.createMatrixClientForUserId(ctx, adminUserId)
+	if err != nil {
+		return err
+	}
+
+	payload := matrix.ApiAdminUserServerAdminRequestPayload{
+		Admin: isServerAdmin,
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "user.set_server_admin", func() error {
+		return client.MakeRequest(
+			"PUT",
+			buildPrefixlessURL(client, fmt.Sprintf("/_synapse/admin/v1/users/%s/admin", userId), map[string]string{}),
+			payload,
+			nil,
+		)
+	})
+}
+
+// SetUserRateLimitOverride sets custom message rate limits for the given user.
+// A nil rateLimitOverride removes the override, making the homeserver's default rate limits apply.
+func (me *SynapseConnector) SetUserRateLimitOverride(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	rateLimitOverride *CurrentUserRateLimitOverrideState,
+) error {
+	client, err := me.createMatrixClientForUserId(ctx, adminUserId)
+	if err != nil {
+		return err
+	}
+
+	url := buildPrefixlessURL(client, fmt.Sprintf("/_synapse/admin/v1/users/%s/override_ratelimit", userId), map[string]string{})
+
+	if rateLimitOverride == nil {
+		return matrix.ExecuteWithRateLimitRetries(me.logger, "user.remove_rate_limit_override", func() error {
+			return client.MakeRequest("DELETE", url, nil, nil)
+		})
+	}
+
+	payload := matrix.ApiAdminUserRateLimitOverride{
+		MessagesPerSecond: &rateLimitOverride.MessagesPerSecond,
+		BurstCount:        &rateLimitOverride.BurstCount,
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "user.set_rate_limit_override", func() error {
+		return client.MakeRequest("POST", url, payload, nil)
+	})
+}
+
+// GetUserDevices returns the given user's devices.
+//
+// Unlike most other calls, this one doesn't need an access token context.
+// It uses the matrix-corporal user's own access token, so it can be used outside of reconciliation (e.g. while handling logins).
+func (me *SynapseConnector) GetUserDevices(userId string) ([]CurrentUserDeviceState, error) {
+	client, err := me.createMatrixClientForCorporalUser()
+	if err != nil {
+		return nil, err
+	}
+
+	return me.listUserDevices(client, userId)
+}
+
+// DeleteUserDevices deletes (logs out) the given devices of a user.
+//
+// Like GetUserDevices, this doesn't need an access token context.
+func (me *SynapseConnector) DeleteUserDevices(userId string, deviceIds []string) error {
+	client, err := me.createMatrixClientForCorporalUser()
+	if err != nil {
+		return err
+	}
+
+	return me.deleteUserDevices(client, userId, deviceIds)
+}
+
+// LogoutAllDevicesForUser deletes all of the given user's devices (and thus, their access tokens).
+func (me *SynapseConnector) LogoutAllDevicesForUser(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+) error {
+	client, err := me.createMatrixClientForUserId(ctx, adminUserId)
+	if err != nil {
+		return err
+	}
+
+	devices, err := me.listUserDevices(client, userId)
+	if err != nil {
+		return fmt.Errorf("failed listing devices: %s", err)
+	}
+
+	deviceIds := make([]string, 0, len(devices))
+	for _, device := range devices {
+		deviceIds = append(deviceIds, device.DeviceId)
+	}
+
+	err = me.deleteUserDevices(client, userId, deviceIds)
+	if err != nil {
+		return err
+	}
+
+	// Access tokens that we may have obtained for this user are now gone as well.
+	ctx.ClearAccessTokenForUserId(userId)
+
+	return nil
+}
+
+func (me *SynapseConnector) listUserDevices(client *gomatrix.Client, userId string) ([]CurrentUserDeviceState, error) {
+	var response matrix.ApiAdminUserDevicesResponse
+	err := client.MakeRequest(
+		"GET",
+		buildPrefixlessURL(client, fmt.Sprintf("/_synapse/admin/v2/users/%s/devices", userId), map[string]string{}),
+		nil,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]CurrentUserDeviceState, 0, len(response.Devices))
+	for _, device := range response.Devices {
+		devices = append(devices, CurrentUserDeviceState{
+			DeviceId:    device.DeviceId,
+			DisplayName: device.DisplayName,
+			LastSeenTs:  device.LastSeenTs,
+		})
+	}
+
+	return devices, nil
+}
+
+func (me *SynapseConnector) deleteUserDevices(client *gomatrix.Client, userId string, deviceIds []string) error {
+	if len(deviceIds) == 0 {
+		return nil
+	}
+
+	payload := matrix.ApiAdminUserDeleteDevicesRequestPayload{
+		Devices: deviceIds,
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "user.delete_devices", func() error {
+		return client.MakeRequest(
+			"POST",
+			buildPrefixlessURL(client, fmt.Sprintf("/_synapse/admin/v2/users/%s/delete_devices", userId), map[string]string{}),
+			payload,
+			nil,
+		)
+	})
+}
+
+// RedactUserMessages redacts the given user's most recent events in all rooms they're a member of.
+// A limit of 0 means "use the homeserver's default".
+//
+// Redaction happens in the background (on the homeserver side), so it may not be complete when this returns.
+func (me *SynapseConnector) RedactUserMessages(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	limit int,
+) error {
+	client, err := me.createMatrixClientForUserId(ctx, adminUserId)
+	if err != nil {
+		return err
+	}
+
+	payload := matrix.ApiAdminUserRedactRequestPayload{
+		Rooms: []string{},
+		Limit: limit,
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "user.redact_messages", func() error {
+		return client.MakeRequest(
+			"POST",
+			buildPrefixlessURL(client, fmt.Sprintf("/_synapse/admin/v1/user/%s/redact", userId), map[string]string{}),
+			payload,
+			nil,
+		)
+	})
+}
+
+// DeactivateUserOnHomeserver deactivates the given user via Synapse's own deactivation API,
+// optionally erasing their data.
+func (me *SynapseConnector) DeactivateUserOnHomeserver(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	erase bool,
+) error {
+	if userId == me.corporalUserID {
+		return fmt.Errorf("refusing to deactivate the matrix-corporal user (%s)", userId)
+	}
+
+	client, err := me.createMatrixClientForUserId(ctx, adminUserId)
+	if err != nil {
+		return err
+	}
+
+	payload := matrix.ApiAdminUserDeactivateRequestPayload{
+		Erase: erase,
+	}
+
+	err = matrix.ExecuteWithRateLimitRetries(me.logger, "user.deactivate_on_homeserver", func() error {
+		return client.MakeRequest(
+			"POST",
+			buildPrefixlessURL(client, fmt.Sprintf("/_synapse/admin/v1/deactivate/%s", userId), map[string]string{}),
+			payload,
+			nil,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Deactivation invalidates all access tokens of the user.
+	ctx.ClearAccessTokenForUserId(userId)
+
+	return nil
+}
+
+// ReactivateUserOnHomeserver reactivates a user previously deactivated via DeactivateUserOnHomeserver.
+// The given password becomes the user's new password.
+func (me *SynapseConnector) ReactivateUserOnHomeserver(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+	password string,
+) error {
+	client, err := me.createMatrixClientForUserId(ctx, adminUserId)
+	if err != nil {
+		return err
+	}
+
+	payload := matrix.ApiAdminUserReactivateRequestPayload{
+		Deactivated: false,
+		Password:    password,
+	}
+
+	return matrix.ExecuteWithRateLimitRetries(me.logger, "user.reactivate_on_homeserver", func() error {
+		return client.MakeRequest(
+			"PUT",
+			buildPrefixlessURL(client, fmt.Sprintf("/_synapse/admin/v2/users/%s", userId), map[string]string{}),
+			payload,
+			nil,
+		)
+	})
+}
+
+// GetUserRateLimitOverride returns the given user's rate limit override or nil if there's no override.
+func (me *SynapseConnector) GetUserRateLimitOverride(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+) (*CurrentUserRateLimitOverrideState, error) {
+	client, err := me.createMatrixClientForUserId(ctx, adminUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	var response matrix.ApiAdminUserRateLimitOverride
+	err = client.MakeRequest(
+		"GET",
+		buildPrefixlessURL(client, fmt.Sprintf("/_synapse/admin/v1/users/%s/override_ratelimit", userId), map[string]string{}),
+		nil,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.MessagesPerSecond == nil && response.BurstCount == nil {
+		// No override for this user
+		return nil, nil
+	}
+
+	rateLimitOverride := &CurrentUserRateLimitOverrideState{}
+	if response.MessagesPerSecond != nil {
+		rateLimitOverride.MessagesPerSecond = *response.MessagesPerSecond
+	}
+	if response.BurstCount != nil {
+		rateLimitOverride.BurstCount = *response.BurstCount
+	}
+
+	return rateLimitOverride, nil
+}
+
+// GetUserThreepids returns the given user's 3pids.
+//
+// 3pids are not part of the users list response (see DetermineCurrentState), so they need to be fetched one user at a time.
+func (me *SynapseConnector) GetUserThreepids(
+	ctx *AccessTokenContext,
+	adminUserId string,
+	userId string,
+) ([]CurrentUserThreepidState, error) {
+	client, err := me.createMatrixClientForUserId(ctx, adminUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	var response matrix.ApiAdminEntityUserDetails
+	err = client.MakeRequest(
+		"GET",
+		buildPrefixlessURL(client, fmt.Sprintf("/_synapse/admin/v2/users/%s", userId), map[string]string{}),
+		nil,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	threepids := make([]CurrentUserThreepidState, 0, len(response.Threepids))
+	for _, threepid := range response.Threepids {
+		threepids = append(threepids, CurrentUserThreepidState{
+			Medium:  threepid.Medium,
+			Address: threepid.Address,
+		})
+	}
+
+	return threepids, nil
+}
+
 func (me *SynapseConnector) Release() {
 	me.corporalUserAccessTokenContext.Release()
 }
 
+func (me *SynapseConnector) createMatrixClientForCorporalUser() (*gomatrix.Client, error) {
+	corporalUserAccessToken, err := me.getAccessTokenForCorporalUser()
+	if err != nil {
+		return nil, fmt.Errorf("could not obtain access token for `%s`: %s", me.corporalUserID, err)
+	}
+
+	return me.createMatrixClientForUserIdAndToken(me.corporalUserID, corporalUserAccessToken)
+}
+
 func (me *SynapseConnector) getAccessTokenForCorporalUser() (string, error) {
 	me.corporalUserIDLock.Lock()
 	defer me.corporalUserIDLock.Unlock()
@@ -5,6 +5,7 @@ import (
 	"devture-matrix-corporal/corporal/configuration"
 	"devture-matrix-corporal/corporal/connector"
 	"devture-matrix-corporal/corporal/hook"
+	hookProvider "devture-matrix-corporal/corporal/hook/provider"
 	"devture-matrix-corporal/corporal/httpapi"
 	httpApiHandler "devture-matrix-corporal/corporal/httpapi/handler"
 	"devture-matrix-corporal/corporal/httpgateway"
@@ -17,7 +18,9 @@ import (
 	"devture-matrix-corporal/corporal/policy/provider"
 	"devture-matrix-corporal/corporal/reconciliation/computator"
 	"devture-matrix-corporal/corporal/reconciliation/reconciler"
+	"devture-matrix-corporal/corporal/roomupgrade"
 	"devture-matrix-corporal/corporal/userauth"
+	"errors"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -89,6 +92,13 @@ func BuildContainer(
 		}
 
 		reverseProxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, httphelp.ErrRequestBodyTooLarge) {
+				// The request body was limited (see httphelp.LimitRequestBody) and turned out to be larger than allowed.
+				logger.Infof("HTTP Reverse Proxy: aborted proxying [%s] %s: %s", r.Method, r.URL, err)
+				httphelp.RespondWithMatrixError(w, http.StatusRequestEntityTooLarge, matrix.ErrorTooLarge, "Denied by policy (request body too large)")
+				return
+			}
+
 			logger.Errorf("HTTP Reverse Proxy: failed proxying [%s] %s: %s", r.Method, r.URL, err)
 			w.WriteHeader(http.StatusBadGateway)
 		}
@@ -106,13 +116,24 @@ func BuildContainer(
 			configuration.Matrix.HomeserverDomainName,
 			container.Get("policy.userauth.checker").(*userauth.Checker),
 			container.Get("matrix.shared_secret_auth.password_generator").(*matrix.SharedSecretAuthPasswordGenerator),
+			container.Get("connector.synapse").(*connector.SynapseConnector),
 		)
 	})
 
 	container.Set("httpgateway.hook_runner", func(c service.Container) interface{} {
 		return hookrunner.NewHookRunner(
 			container.Get("policy.store").(*policy.Store),
+			container.Get("hook.store").(*hook.Store),
+			container.Get("hook.executor").(*hook.Executor),
+		)
+	})
+
+	container.Set("httpgateway.hook_simulator", func(c service.Container) interface{} {
+		return hookrunner.NewSimulator(
+			container.Get("policy.store").(*policy.Store),
+			container.Get("hook.store").(*hook.Store),
 			container.Get("hook.executor").(*hook.Executor),
+			container.Get("httpgateway.server.handler_registrators").([]httphelp.HandlerRegistrator),
 		)
 	})
 
@@ -138,6 +159,7 @@ func BuildContainer(
 		return []httphelp.HandlerRegistrator{
 			container.Get("httpgateway.server.handler_registrator.internal_rest_auth").(httphelp.HandlerRegistrator),
 			container.Get("httpgateway.server.handler_registrator.policy_checked_routes").(httphelp.HandlerRegistrator),
+			container.Get("httpgateway.server.handler_registrator.response_filtered_routes").(httphelp.HandlerRegistrator),
 			container.Get("httpgateway.server.handler_registrator.login").(httphelp.HandlerRegistrator),
 			container.Get("httpgateway.server.handler_registrator.corporal").(httphelp.HandlerRegistrator),
 			container.Get("httpgateway.server.handler_registrator.catchall").(httphelp.HandlerRegistrator),
@@ -156,6 +178,18 @@ func BuildContainer(
 
 	container.Set("httpgateway.server.handler_registrator.policy_checked_routes", func(c service.Container) interface{} {
 		return httpGatewayHandler.NewPolicyCheckedRoutesHandler(
+			container.Get("matrix.http_reverse_proxy").(*httputil.ReverseProxy),
+			container.Get("policy.store").(*policy.Store),
+			container.Get("policy.checker").(*policy.Checker),
+			container.Get("httpgateway.hook_runner").(*hookrunner.HookRunner),
+			container.Get("matrix.user_mapping_resolver").(*matrix.UserMappingResolver),
+			container.Get("roomupgrade.tracker").(*roomupgrade.Tracker),
+			logger,
+		)
+	})
+
+	container.Set("httpgateway.server.handler_registrator.response_filtered_routes", func(c service.Container) interface{} {
+		return httpGatewayHandler.NewResponseFilteredRoutesHandler(
 			container.Get("matrix.http_reverse_proxy").(*httputil.ReverseProxy),
 			container.Get("policy.store").(*policy.Store),
 			container.Get("policy.checker").(*policy.Checker),
@@ -211,6 +245,8 @@ func BuildContainer(
 		return []httphelp.HandlerRegistrator{
 			container.Get("httpapi.server.handler_registrator.policy").(httphelp.HandlerRegistrator),
 			container.Get("httpapi.server.handler_registrator.user").(httphelp.HandlerRegistrator),
+			container.Get("httpapi.server.handler_registrator.hook").(httphelp.HandlerRegistrator),
+			container.Get("httpapi.server.handler_registrator.room").(httphelp.HandlerRegistrator),
 		}
 	})
 
@@ -228,10 +264,55 @@ func BuildContainer(
 		)
 	})
 
+	container.Set("httpapi.server.handler_registrator.hook", func(c service.Container) interface{} {
+		return httpApiHandler.NewHookApiHandlerRegistrator(
+			container.Get("hook.store").(*hook.Store),
+			container.Get("httpgateway.hook_simulator").(*hookrunner.Simulator),
+			logger,
+		)
+	})
+
+	container.Set("httpapi.server.handler_registrator.room", func(c service.Container) interface{} {
+		return httpApiHandler.NewRoomApiHandlerRegistrator(
+			container.Get("roomupgrade.tracker").(*roomupgrade.Tracker),
+		)
+	})
+
+	container.Set("roomupgrade.tracker", func(c service.Container) interface{} {
+		return roomupgrade.NewTracker(
+			container.Get("policy.store").(*policy.Store),
+			container.Get("hook.store").(*hook.Store),
+			container.Get("hook.rest_service_consultor").(*hook.RESTServiceConsultor),
+			logger,
+		)
+	})
+
 	container.Set("hook.rest_service_consultor", func(c service.Container) interface{} {
 		return hook.NewRESTServiceConsultor(30 * time.Second)
 	})
 
+	container.Set("hook.store", func(c service.Container) interface{} {
+		return hook.NewStore()
+	})
+
+	container.Set("hook.provider.file", func(c service.Container) interface{} {
+		instance, err := hookProvider.NewFileProvider(
+			configuration.Hooks.Path,
+			container.Get("hook.store").(*hook.Store),
+			logger,
+		)
+
+		if err != nil {
+			panic(err)
+		}
+
+		shutdownHandler.Add(func() {
+			instance.Stop()
+		})
+
+		return instance
+	})
+
 	container.Set("hook.executor", func(c service.Container) interface{} {
 		return hook.NewExecutor(
 			container.Get("hook.rest_service_consultor").(*hook.RESTServiceConsultor),
@@ -242,6 +323,8 @@ func BuildContainer(
 		return policy.NewStore(
 			logger,
 			container.Get("policy.validator").(*policy.Validator),
+			container.Get("policy.room_alias_resolver").(*policy.RoomAliasResolver),
+			container.Get("hook.store").(*hook.Store),
 		)
 	})
 
@@ -252,9 +335,25 @@ func BuildContainer(
 	container.Set("policy.validator", func(c service.Container) interface{} {
 		return policy.NewValidator(
 			configuration.Matrix.HomeserverDomainName,
+			container.Get("hook.store").(*hook.Store),
+			container.Get("policy.room_alias_resolver").(*policy.RoomAliasResolver),
 		)
 	})
 
+	container.Set("policy.room_alias_resolver", func(c service.Container) interface{} {
+		instance := policy.NewRoomAliasResolver(
+			logger,
+			container.Get("connector.synapse").(*connector.SynapseConnector),
+			5*time.Minute,
+		)
+
+		shutdownHandler.Add(func() {
+			instance.Stop()
+		})
+
+		return instance
+	})
+
 	container.Set("matrix.userauth.rest_cache", func(c service.Container) interface{} {
 		cache, err := lru.New[string, bool](1000)
 		if err != nil {
@@ -310,6 +409,8 @@ func BuildContainer(
 	container.Set("reconciliation.computator", func(c service.Container) interface{} {
 		return computator.NewReconciliationStateComputator(
 			logger,
+			container.Get("policy.checker").(*policy.Checker),
+			configuration.Corporal.UserID,
 		)
 	})
 
@@ -320,6 +421,8 @@ func BuildContainer(
 			container.Get("reconciliation.computator").(*computator.ReconciliationStateComputator),
 			configuration.Corporal.UserID,
 			container.Get("avatar.avatar_reader").(*avatar.AvatarReader),
+			container.Get("hook.rest_service_consultor").(*hook.RESTServiceConsultor),
+			container.Get("hook.store").(*hook.Store),
 		)
 	})
 
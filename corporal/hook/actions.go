@@ -5,6 +5,11 @@ var (
 	// See restActionHookDetails for fields related to this action.
 	ActionConsultRESTServiceURL = "consult.RESTServiceURL"
 
+	// ActionConsultRESTServiceURLs is an action which will pass the request to multiple REST services concurrently
+	// and decide based on their combined result.
+	// See multiRESTActionHookDetails for fields related to this action.
+	ActionConsultRESTServiceURLs = "consult.RESTServiceURLs"
+
 	// ActionRespond is an action that outright responds to the request with a specified payload.
 	// See respondActionHookDetails for fields related to this action.
 	//
@@ -28,13 +33,20 @@ var (
 	// ActionPassModifiedResponse is an action that lets the request pass and then adjusts the JSON response.
 	// See passModifiedResponseActionHookDetails for fields related to this action.
 	ActionPassModifiedResponse = "pass.modifiedResponse"
+
+	// ActionFilterContent is an action that scans the content of messages (`m.room.message` events sent via the `room.send_event` route)
+	// and rejects, redacts or flags them if they match.
+	// See contentFilterActionHookDetails for fields related to this action.
+	ActionFilterContent = "filter.content"
 )
 
 var knownActions = []string{
 	ActionConsultRESTServiceURL,
+	ActionConsultRESTServiceURLs,
 	ActionRespond,
 	ActionReject,
 	ActionPassUnmodified,
 	ActionPassModifiedResponse,
 	ActionPassModifiedRequest,
+	ActionFilterContent,
 }
@@ -0,0 +1,307 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/util"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ContentFilterModeReject is a content filter mode which rejects messages that match
+	ContentFilterModeReject = "reject"
+
+	// ContentFilterModeRedact is a content filter mode which replaces the matching parts of messages
+	// (see ContentFilterRedactionReplacement) and lets them pass
+	ContentFilterModeRedact = "redact"
+
+	// ContentFilterModeFlag is a content filter mode which lets messages pass unmodified,
+	// but notifies a REST service (asynchronously) about matching ones
+	ContentFilterModeFlag = "flag"
+)
+
+var knownContentFilterModes = []string{
+	ContentFilterModeReject,
+	ContentFilterModeRedact,
+	ContentFilterModeFlag,
+}
+
+var (
+	contentFilterDefaultFields               = []string{"body", "formatted_body", "m.new_content.body", "m.new_content.formatted_body"}
+	contentFilterDefaultRedactionReplacement = "[REDACTED]"
+	contentFilterDefaultRejectionMessage     = "This message contains content which is not allowed"
+
+	// contentFilterSendEventRouteRegex matches the `room.send_event` route and captures the event type
+	contentFilterSendEventRouteRegex = regexp.MustCompile(`^/_matrix/client/(?:r0|v\d+)/rooms/[^/]+/send/([^/]+)/[^/]+/?$`)
+)
+
+// contentFilterActionHookDetails contains some fields which are useful when Hook.Action = ActionFilterContent
+type contentFilterActionHookDetails struct {
+	// ContentFilterMode specifies what happens with messages that match.
+	// It's one of the `ContentFilterMode*` constants.
+	// Required field.
+	ContentFilterMode *string `json:"contentFilterMode,omitempty"`
+
+	// ContentFilterFields specifies which fields of the `m.room.message` event content get scanned.
+	// Nested fields can be specified using a dotted path (e.g. `m.new_content.body`).
+	// If not specified, the `body` and `formatted_body` fields are scanned,
+	// along with their `m.new_content` counterparts (used by message edits).
+	ContentFilterFields []string `json:"contentFilterFields,omitempty"`
+
+	// ContentFilterRegexes contains a list of regular expressions to scan for.
+	ContentFilterRegexes []string `json:"contentFilterRegexes,omitempty"`
+
+	// ContentFilterKeywords contains a list of keywords to scan for (case-insensitive).
+	ContentFilterKeywords []string `json:"contentFilterKeywords,omitempty"`
+
+	// ContentFilterRedactionReplacement specifies what matches get replaced with when ContentFilterMode = ContentFilterModeRedact.
+	// If not specified, matches are replaced with `[REDACTED]`.
+	ContentFilterRedactionReplacement *string `json:"contentFilterRedactionReplacement,omitempty"`
+
+	// contentFilterPatternsCompiled contains the compiled ContentFilterRegexes and ContentFilterKeywords.
+	// See ensureContentFilterInitialized().
+	contentFilterPatternsCompiled []*regexp.Regexp
+
+	// This action also relies on:
+	// - some fields from `rejectActionHookDetails` (for ContentFilterModeReject)
+	// - some fields from `restActionHookDetails` (for ContentFilterModeFlag)
+}
+
+// ContentFilterInformation contains information about content matched by an ActionFilterContent hook.
+//
+// It gets sent to REST services notified about flagged messages (see ContentFilterModeFlag).
+type ContentFilterInformation struct {
+	// Matches contains the matching parts of the message, grouped by content field name
+	Matches map[string][]string `json:"matches"`
+}
+
+func (me *Hook) validateContentFilterActionHookDetails() error {
+	if !me.IsBeforeHook() {
+		return fmt.Errorf("content filtering can only be done in `before*` hooks")
+	}
+
+	if me.ContentFilterMode == nil || !util.IsStringInArray(*me.ContentFilterMode, knownContentFilterModes) {
+		return fmt.Errorf("a valid contentFilterMode is required")
+	}
+
+	if len(me.ContentFilterRegexes) == 0 && len(me.ContentFilterKeywords) == 0 {
+		return fmt.Errorf("at least one of contentFilterRegexes or contentFilterKeywords is required")
+	}
+
+	if *me.ContentFilterMode == ContentFilterModeFlag && (me.RESTServiceURL == nil || *me.RESTServiceURL == "") {
+		return fmt.Errorf("a RESTServiceURL is required for contentFilterMode=%s", ContentFilterModeFlag)
+	}
+
+	return me.ensureContentFilterInitialized()
+}
+
+// ensureContentFilterInitialized compiles the content filter patterns (once) and caches them on the hook.
+// Policy hooks get initialized during validation, so executing them doesn't need to recompile anything.
+func (me *Hook) ensureContentFilterInitialized() error {
+	if me.contentFilterPatternsCompiled != nil {
+		return nil
+	}
+
+	patterns, err := me.compileContentFilterPatterns()
+	if err != nil {
+		return err
+	}
+	me.contentFilterPatternsCompiled = patterns
+
+	return nil
+}
+
+func (me Hook) compileContentFilterPatterns() ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(me.ContentFilterRegexes)+len(me.ContentFilterKeywords))
+
+	for _, regex := range me.ContentFilterRegexes {
+		pattern, err := regexp.Compile(regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed compiling content filter regex (%s): %s", regex, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	for _, keyword := range me.ContentFilterKeywords {
+		patterns = append(patterns, regexp.MustCompile(fmt.Sprintf("(?i)%s", regexp.QuoteMeta(keyword))))
+	}
+
+	return patterns, nil
+}
+
+func (me *Executor) executeActionFilterContent(hookObj *Hook, w http.ResponseWriter, request *http.Request, response *http.Response, logger *logrus.Entry) ExecutionResult {
+	if hookObj.ContentFilterMode == nil {
+		return createProcessingErrorExecutionResult(hookObj, fmt.Errorf("a contentFilterMode is required"))
+	}
+
+	routeMatches := contentFilterSendEventRouteRegex.FindStringSubmatch(request.URL.Path)
+	if routeMatches == nil || routeMatches[1] != "m.room.message" {
+		// Only message events are filtered. Everything else passes through.
+		return executePassUnmodified(hookObj, w, request, response, logger)
+	}
+
+	err := hookObj.ensureContentFilterInitialized()
+	if err != nil {
+		return createProcessingErrorExecutionResult(hookObj, err)
+	}
+	patterns := hookObj.contentFilterPatternsCompiled
+
+	requestBytes, err := httphelp.GetRequestBody(request)
+	if err != nil {
+		return createProcessingErrorExecutionResult(hookObj, fmt.Errorf("failed to read request body: %s", err))
+	}
+
+	// The payload may get re-encoded (when redacting), so we need to preserve numbers as they are.
+	var content map[string]interface{}
+	err = httphelp.DecodeJsonPreservingNumbers(bytes.NewReader(requestBytes), &content)
+	if err != nil {
+		return createProcessingErrorExecutionResult(hookObj, fmt.Errorf("failed to interpret request body as JSON: %s", err))
+	}
+
+	fields := contentFilterDefaultFields
+	if len(hookObj.ContentFilterFields) != 0 {
+		fields = hookObj.ContentFilterFields
+	}
+
+	redactionReplacement := contentFilterDefaultRedactionReplacement
+	if hookObj.ContentFilterRedactionReplacement != nil {
+		redactionReplacement = *hookObj.ContentFilterRedactionReplacement
+	}
+
+	matches := map[string][]string{}
+
+	for _, field := range fields {
+		fieldContainer, fieldKey := findContentFilterField(content, field)
+		if fieldContainer == nil {
+			continue
+		}
+
+		value, ok := fieldContainer[fieldKey].(string)
+		if !ok {
+			continue
+		}
+
+		for _, pattern := range patterns {
+			fieldMatches := pattern.FindAllString(value, -1)
+			if len(fieldMatches) == 0 {
+				continue
+			}
+
+			matches[field] = append(matches[field], fieldMatches...)
+
+			value = pattern.ReplaceAllLiteralString(value, redactionReplacement)
+		}
+
+		fieldContainer[fieldKey] = value
+	}
+
+	if len(matches) == 0 {
+		return executePassUnmodified(hookObj, w, request, response, logger)
+	}
+
+	logger = logger.WithField("contentFilterMode", *hookObj.ContentFilterMode)
+	logger.Infof("Content filter matched")
+
+	if *hookObj.ContentFilterMode == ContentFilterModeReject {
+		statusCode := http.StatusForbidden
+		if hookObj.ResponseStatusCode != nil {
+			statusCode = *hookObj.ResponseStatusCode
+		}
+
+		errorCode := matrix.ErrorForbidden
+		if hookObj.RejectionErrorCode != nil {
+			errorCode = *hookObj.RejectionErrorCode
+		}
+
+		errorMessage := contentFilterDefaultRejectionMessage
+		if hookObj.RejectionErrorMessage != nil {
+			errorMessage = *hookObj.RejectionErrorMessage
+		}
+
+		httphelp.RespondWithMatrixError(w, statusCode, errorCode, errorMessage)
+
+		return ExecutionResult{
+			Hooks:                []*Hook{hookObj},
+			ResponseSent:         true,
+			SkipNextHooksInChain: hookObj.SkipNextHooksInChain,
+		}
+	}
+
+	if *hookObj.ContentFilterMode == ContentFilterModeRedact {
+		var newRequestBuffer bytes.Buffer
+		err := httphelp.EncodeJsonPreservingHtml(&newRequestBuffer, content)
+		if err != nil {
+			// We don't expect this to happen, but..
+			return createProcessingErrorExecutionResult(hookObj, fmt.Errorf("failed to serialize redacted request payload as JSON: %s", err))
+		}
+		newRequestBytes := newRequestBuffer.Bytes()
+
+		request.Body = io.NopCloser(bytes.NewReader(newRequestBytes))
+		request.ContentLength = int64(len(newRequestBytes))
+
+		return ExecutionResult{
+			Hooks:                []*Hook{hookObj},
+			SkipNextHooksInChain: hookObj.SkipNextHooksInChain,
+		}
+	}
+
+	if *hookObj.ContentFilterMode == ContentFilterModeFlag {
+		// The REST service gets the original request, along with information about what matched.
+		// We don't care about what it responds with. The request passes unmodified regardless.
+		// We don't care that this fails the SA1029 static check
+		flaggedRequest := request.WithContext(context.WithValue( //nolint:staticcheck
+			request.Context(),
+			"contentFilterInformation",
+			&ContentFilterInformation{Matches: matches},
+		))
+
+		notificationHook := *hookObj
+		notificationHook.RESTServiceAsync = true
+		notificationHook.RESTServiceAsyncResultHook = nil
+
+		_, err := me.restServiceConsultor.Consult(flaggedRequest, response, notificationHook, logger)
+		if err != nil {
+			logger.Warnf("Failed notifying REST service about flagged content: %s", err)
+		}
+
+		return executePassUnmodified(hookObj, w, request, response, logger)
+	}
+
+	return createProcessingErrorExecutionResult(hookObj, fmt.Errorf("unknown contentFilterMode: %s", *hookObj.ContentFilterMode))
+}
+
+// findContentFilterField locates the field at the given dotted path (e.g. `m.new_content.body`) within the content.
+// It returns the object containing the field and the field's key in it, or nil if there's no such field.
+//
+// Keys in Matrix event content often contain dots themselves (e.g. `m.new_content`),
+// so at each level we look for the longest key (made up of path segments) which exists.
+func findContentFilterField(content map[string]interface{}, path string) (map[string]interface{}, string) {
+	if _, exists := content[path]; exists {
+		return content, path
+	}
+
+	segments := strings.Split(path, ".")
+	for prefixLength := len(segments) - 1; prefixLength > 0; prefixLength-- {
+		key := strings.Join(segments[:prefixLength], ".")
+
+		nestedContent, ok := content[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		nestedContainer, nestedKey := findContentFilterField(nestedContent, strings.Join(segments[prefixLength:], "."))
+		if nestedContainer != nil {
+			return nestedContainer, nestedKey
+		}
+	}
+
+	return nil, ""
+}
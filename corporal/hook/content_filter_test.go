@@ -0,0 +1,61 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestContentFilterRedactPreservesPayload(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	var hookObj Hook
+	err := json.Unmarshal([]byte(`{
+		"id": "redact",
+		"eventType": "beforeAuthenticatedRequest",
+		"action": "filter.content",
+		"contentFilterMode": "redact",
+		"contentFilterKeywords": ["secret"]
+	}`), &hookObj)
+	if err != nil {
+		t.Fatalf("failed parsing hook: %s", err)
+	}
+
+	requestBody := `{"msgtype":"m.text","body":"a secret & <b>more</b>","formatted_body":"<b>secret</b>","custom.number":12345678901234567890}`
+
+	request := httptest.NewRequest(
+		"PUT",
+		"/_matrix/client/v3/rooms/!room:host/send/m.room.message/txn",
+		bytes.NewReader([]byte(requestBody)),
+	)
+	recorder := httptest.NewRecorder()
+
+	result := NewExecutor(NewRESTServiceConsultor(5*time.Second)).Execute(&hookObj, recorder, request, logrus.NewEntry(logger))
+	if result.ProcessingError != nil {
+		t.Fatalf("unexpected processing error: %s", result.ProcessingError)
+	}
+	if result.ResponseSent {
+		t.Fatalf("expected the request to pass")
+	}
+
+	redactedBody, err := io.ReadAll(request.Body)
+	if err != nil {
+		t.Fatalf("failed reading redacted body: %s", err)
+	}
+
+	// Numbers and HTML characters are expected to survive being re-encoded
+	expectedBody := `{"body":"a [REDACTED] & <b>more</b>","custom.number":12345678901234567890,"formatted_body":"<b>[REDACTED]</b>","msgtype":"m.text"}` + "\n"
+	if string(redactedBody) != expectedBody {
+		t.Errorf("expected redacted body:\n%s\ngot:\n%s", expectedBody, redactedBody)
+	}
+
+	if request.ContentLength != int64(len(redactedBody)) {
+		t.Errorf("expected content length %d, got %d", len(redactedBody), request.ContentLength)
+	}
+}
@@ -28,6 +28,13 @@ var (
 
 	// EventTypeBeforeUnauthenticatedRequest is the same as EventTypeBeforeAnyRequest, but only gets fired for unauthenticated requests.
 	EventTypeBeforeUnauthenticatedRequest = "beforeUnauthenticatedRequest"
+
+	// EventTypeBeforeLogin is a hook event type which gets executed before a login request gets forwarded to the upstream.
+	//
+	// Unlike EventTypeBeforeUnauthenticatedRequest, this runs after matrix-corporal has interpreted the login request,
+	// so information about the user trying to log in is available (see LoginInformation).
+	// Login requests denied by matrix-corporal itself never reach this point (see EventTypeAfterLoginFailure).
+	EventTypeBeforeLogin = "beforeLogin"
 )
 
 // `after*` hooks are executed in the order they're defined below.
@@ -73,6 +80,68 @@ var (
 
 	// EventTypeAfterUnauthenticatedRequest is the same as EventTypeAfterAnyRequest, but only gets fired for unauthenticated requests.
 	EventTypeAfterUnauthenticatedRequest = "afterUnauthenticatedRequest"
+
+	// EventTypeAfterLoginSuccess is a hook event type which gets executed after a login request succeeds.
+	//
+	// Information about the user who logged in is available (see LoginInformation).
+	EventTypeAfterLoginSuccess = "afterLoginSuccess"
+
+	// EventTypeAfterLoginFailure is a hook event type which gets executed after a login request fails.
+	//
+	// This fires both for login requests denied by matrix-corporal itself and for those that the upstream rejected.
+	// Information about the user trying to log in is available (see LoginInformation), to the extent it could be determined.
+	EventTypeAfterLoginFailure = "afterLoginFailure"
+)
+
+// Reconciliation event types are not related to HTTP requests going through the gateway.
+// They're emitted by the reconciler after it successfully carries out a reconciliation action.
+//
+// Because there is no request to influence, hooks of these types can only notify REST services (see ReconciliationEvent)
+// and are always executed asynchronously.
+var (
+	// EventTypeReconciliationUserCreated is a hook event type which gets executed after a user account gets created.
+	EventTypeReconciliationUserCreated = "reconciliationUserCreated"
+
+	// EventTypeReconciliationUserDeactivated is a hook event type which gets executed after a user account gets deactivated.
+	EventTypeReconciliationUserDeactivated = "reconciliationUserDeactivated"
+
+	// EventTypeReconciliationRoomJoined is a hook event type which gets executed after a user gets joined to a room.
+	EventTypeReconciliationRoomJoined = "reconciliationRoomJoined"
+
+	// EventTypeReconciliationRoomLeft is a hook event type which gets executed after a user leaves a room.
+	EventTypeReconciliationRoomLeft = "reconciliationRoomLeft"
+
+	// EventTypeReconciliationRoomUserRemoved is a hook event type which gets executed after a user gets kicked or banned from an exclusive room.
+	EventTypeReconciliationRoomUserRemoved = "reconciliationRoomUserRemoved"
+
+	// EventTypeReconciliationRoomPowerLevelsChanged is a hook event type which gets executed after power levels in a room get changed
+	// (those of some users and/or the room-wide ones).
+	EventTypeReconciliationRoomPowerLevelsChanged = "reconciliationRoomPowerLevelsChanged"
+)
+
+var knownReconciliationEventTypes = []string{
+	EventTypeReconciliationUserCreated,
+	EventTypeReconciliationUserDeactivated,
+	EventTypeReconciliationRoomJoined,
+	EventTypeReconciliationRoomLeft,
+	EventTypeReconciliationRoomUserRemoved,
+	EventTypeReconciliationRoomPowerLevelsChanged,
+}
+
+// Gateway notification event types are emitted by the HTTP gateway after something noteworthy happens.
+// They're not tied to the request/response lifecycle (see `before*` and `after*` event types), so just like reconciliation event types,
+// hooks of these types can only notify REST services and are always executed asynchronously.
+var (
+	// EventTypeManagedRoomUpgraded is a hook event type which gets executed after a managed room gets upgraded (see RoomUpgradeEvent).
+	//
+	// This only happens if upgrading managed rooms is allowed by the policy.
+	EventTypeManagedRoomUpgraded = "managedRoomUpgraded"
+)
+
+// knownNotificationEventTypes contains all event types, whose hooks can only notify REST services
+var knownNotificationEventTypes = append(
+	append([]string{}, knownReconciliationEventTypes...),
+	EventTypeManagedRoomUpgraded,
 )
 
 var knownEventTypes = []string{
@@ -80,9 +149,21 @@ var knownEventTypes = []string{
 	EventTypeBeforeAuthenticatedRequest,
 	EventTypeBeforeAuthenticatedPolicyCheckedRequest,
 	EventTypeBeforeUnauthenticatedRequest,
+	EventTypeBeforeLogin,
 
 	EventTypeAfterAnyRequest,
 	EventTypeAfterAuthenticatedRequest,
 	EventTypeAfterAuthenticatedPolicyCheckedRequest,
 	EventTypeAfterUnauthenticatedRequest,
+	EventTypeAfterLoginSuccess,
+	EventTypeAfterLoginFailure,
+
+	EventTypeReconciliationUserCreated,
+	EventTypeReconciliationUserDeactivated,
+	EventTypeReconciliationRoomJoined,
+	EventTypeReconciliationRoomLeft,
+	EventTypeReconciliationRoomUserRemoved,
+	EventTypeReconciliationRoomPowerLevelsChanged,
+
+	EventTypeManagedRoomUpgraded,
 }
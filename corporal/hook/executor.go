@@ -2,6 +2,7 @@ package hook
 
 import (
 	"bytes"
+	"context"
 	"devture-matrix-corporal/corporal/httphelp"
 	"devture-matrix-corporal/corporal/matrix"
 	"encoding/json"
@@ -27,12 +28,14 @@ func NewExecutor(restServiceConsultor *RESTServiceConsultor) *Executor {
 	}
 
 	me.actionToHandlerMap = map[string]executionHandler{
-		ActionConsultRESTServiceURL: me.executeActionConsultRESTServiceURL,
-		ActionReject:                executeActionReject,
-		ActionRespond:               executeActionRespond,
-		ActionPassUnmodified:        executePassUnmodified,
-		ActionPassModifiedRequest:   executePassModifiedRequest,
-		ActionPassModifiedResponse:  executePassModifiedResponse,
+		ActionConsultRESTServiceURL:  me.executeActionConsultRESTServiceURL,
+		ActionConsultRESTServiceURLs: me.executeActionConsultRESTServiceURLs,
+		ActionReject:                 executeActionReject,
+		ActionRespond:                executeActionRespond,
+		ActionPassUnmodified:         executePassUnmodified,
+		ActionPassModifiedRequest:    executePassModifiedRequest,
+		ActionPassModifiedResponse:   executePassModifiedResponse,
+		ActionFilterContent:          me.executeActionFilterContent,
 	}
 
 	return me
@@ -113,7 +116,7 @@ func (me *Executor) executeAfterHook(
 	// We only capture it for the action types we know will need it.
 	var requestBodyBytes []byte
 
-	if hookObj.Action == ActionConsultRESTServiceURL {
+	if hookObj.Action == ActionConsultRESTServiceURL || hookObj.Action == ActionConsultRESTServiceURLs {
 		var err error
 
 		requestBodyBytes, err = httphelp.GetRequestBody(request)
@@ -141,90 +144,122 @@ func (me *Executor) executeAfterHook(
 			)
 		}
 
-		// Instead of passing the writer for the original response (`w`) to the handler,
-		// we'd like to pass another one.
-		//
-		// This forwarding writer will capture calls to `.Write(..)` and `WriteHeader(statusCode int)`,
-		// and put that data into the `response` object.
-		//
-		// `after*` hooks run from the reverse-proxy's HTTP response modifier.
-		// Given that we're this far, the reverse-proxy service will be keen on writing the response's headers and data it already has.
-		//
-		// If we (or rather, the handler we call below) starts writing on its own, we'll be writing multiple times -
-		// first from the handler itself, then when we exit this "HTTP respone modifier" function and the reverse-proxy
-		// finally decides to write the response it sees in response.Body (unless we unset it).
-		//
-		// This unsetting thing works, but calls for writing headers (especially the response status code) don't,
-		// and we may get to call it twice, which leads to strange errors we'd rather not have.
-		//
-		// So, we do things in a cleaner way. We pass a "collect stuff and modify the original response" writer
-		// and let our hook action handlers run their normal course. All their attempts to send a header or data
-		// will be gathered and dumped into the `response`.
-		// We can then let the reverse-proxy send it (as it does by default) and we're done.
-		responseBoundWriter := httphelp.NewResponseBoundHttpWriter(response)
-		defer responseBoundWriter.Commit()
-
-		// We won't need to care about this execution result's `ResponseSent` field,
-		// because due to `responseBoundWriter` we never really send out a response,
-		// but rather just write it out into the `response` object.
-		result := handler(hookObj, responseBoundWriter, request, response, logger)
-
-		logger.Debugf("After-hook execution result: %#v\n", result)
-
-		if result.ProcessingError != nil {
-			logger = logger.WithField("error", result.ProcessingError)
-
-			logger.Errorf("After-hook HTTP modifier response: error\n")
-
-			// This gets sent to responseBoundWriter, so it ends up in the `response` object.
-			// It doesn't really get written out just yet.
-			httphelp.RespondWithMatrixError(
-				responseBoundWriter,
-				http.StatusServiceUnavailable,
-				matrix.ErrorUnknown,
-				"Afer-hook execution failed, cannot proceed",
-			)
+		_, skipNextModifiers, err := me.runAfterHookHandler(handler, hookObj, request, response, logger)
 
-			return true, nil
-		}
+		logger.Debugln("Finished after-hook response modifier")
 
-		if len(result.ReverseProxyResponseModifiers) != 0 {
-			for _, modifier := range result.ReverseProxyResponseModifiers {
-				logger.Debugln("Passing control to the action's response modifier")
-				skipNextModifiers, err := modifier(response)
-				logger.Debugln("Returned to the after-hook action's response modifier")
-
-				if err != nil {
-					return true, err
-				}
-
-				if skipNextModifiers {
-					// This embedded response modifier (spawned from the execution of the hook)
-					// asked that no one modifiers run.
-					// We should both "break" here and also prevent other top-level response modifiers from running.
-					return true, nil
-				}
-			}
+		return skipNextModifiers, err
+	}
 
-			// All response modifiers ran successfully
-			return false, nil
-		}
+	return ExecutionResult{
+		ReverseProxyResponseModifiers: []HttpResponseModifierFunc{responseModifier},
+	}
+}
 
-		if result.SkipNextHooksInChain {
-			logger.Debugf("After-hook execution result requested that we skip execution of all other hooks in the chain: %#v\n", result)
-			return true, nil
-		}
+// ExecuteAfterHookAgainstResponse runs an `after*` hook right away against the given response
+// and reports both the execution result and whether further response modifiers should be skipped.
+//
+// Unlike Execute(), which merely schedules `after*` hooks to run later (as HTTP response modifier functions),
+// this is useful when a response is already available (e.g. when simulating requests).
+func (me *Executor) ExecuteAfterHookAgainstResponse(
+	hookObj *Hook,
+	request *http.Request,
+	response *http.Response,
+	logger *logrus.Entry,
+) (ExecutionResult /* skipNextModifiers */, bool, error) {
+	handler, exists := me.actionToHandlerMap[hookObj.Action]
+	if !exists {
+		return createProcessingErrorExecutionResult(hookObj, fmt.Errorf("missing handler for hook action = %s", hookObj.Action)), true, nil
+	}
 
-		// Ignoring `ResponseSent` here.
+	return me.runAfterHookHandler(handler, hookObj, request, response, logger)
+}
 
-		logger.Debugln("Finished after-hook response modifier")
+// runAfterHookHandler runs the handler of an `after*` hook against the response the upstream returned.
+func (me *Executor) runAfterHookHandler(
+	handler executionHandler,
+	hookObj *Hook,
+	request *http.Request,
+	response *http.Response,
+	logger *logrus.Entry,
+) (ExecutionResult /* skipNextModifiers */, bool, error) {
+	// Instead of passing the writer for the original response (`w`) to the handler,
+	// we'd like to pass another one.
+	//
+	// This forwarding writer will capture calls to `.Write(..)` and `WriteHeader(statusCode int)`,
+	// and put that data into the `response` object.
+	//
+	// `after*` hooks run from the reverse-proxy's HTTP response modifier.
+	// Given that we're this far, the reverse-proxy service will be keen on writing the response's headers and data it already has.
+	//
+	// If we (or rather, the handler we call below) starts writing on its own, we'll be writing multiple times -
+	// first from the handler itself, then when we exit this "HTTP respone modifier" function and the reverse-proxy
+	// finally decides to write the response it sees in response.Body (unless we unset it).
+	//
+	// This unsetting thing works, but calls for writing headers (especially the response status code) don't,
+	// and we may get to call it twice, which leads to strange errors we'd rather not have.
+	//
+	// So, we do things in a cleaner way. We pass a "collect stuff and modify the original response" writer
+	// and let our hook action handlers run their normal course. All their attempts to send a header or data
+	// will be gathered and dumped into the `response`.
+	// We can then let the reverse-proxy send it (as it does by default) and we're done.
+	responseBoundWriter := httphelp.NewResponseBoundHttpWriter(response)
+	defer responseBoundWriter.Commit()
+
+	// We won't need to care about this execution result's `ResponseSent` field,
+	// because due to `responseBoundWriter` we never really send out a response,
+	// but rather just write it out into the `response` object.
+	result := handler(hookObj, responseBoundWriter, request, response, logger)
+
+	logger.Debugf("After-hook execution result: %#v\n", result)
+
+	if result.ProcessingError != nil {
+		logger = logger.WithField("error", result.ProcessingError)
+
+		logger.Errorf("After-hook HTTP modifier response: error\n")
+
+		// This gets sent to responseBoundWriter, so it ends up in the `response` object.
+		// It doesn't really get written out just yet.
+		httphelp.RespondWithMatrixError(
+			responseBoundWriter,
+			http.StatusServiceUnavailable,
+			matrix.ErrorUnknown,
+			"Afer-hook execution failed, cannot proceed",
+		)
 
-		return false, nil
+		return result, true, nil
 	}
 
-	return ExecutionResult{
-		ReverseProxyResponseModifiers: []HttpResponseModifierFunc{responseModifier},
+	if len(result.ReverseProxyResponseModifiers) != 0 {
+		for _, modifier := range result.ReverseProxyResponseModifiers {
+			logger.Debugln("Passing control to the action's response modifier")
+			skipNextModifiers, err := modifier(response)
+			logger.Debugln("Returned to the after-hook action's response modifier")
+
+			if err != nil {
+				return result, true, err
+			}
+
+			if skipNextModifiers {
+				// This embedded response modifier (spawned from the execution of the hook)
+				// asked that no one modifiers run.
+				// We should both "break" here and also prevent other top-level response modifiers from running.
+				return result, true, nil
+			}
+		}
+
+		// All response modifiers ran successfully
+		return result, false, nil
+	}
+
+	if result.SkipNextHooksInChain {
+		logger.Debugf("After-hook execution result requested that we skip execution of all other hooks in the chain: %#v\n", result)
+		return result, true, nil
 	}
+
+	// Ignoring `ResponseSent` here.
+
+	return result, false, nil
 }
 
 func (me *Executor) executeActionConsultRESTServiceURL(hookObj *Hook, w http.ResponseWriter, request *http.Request, response *http.Response, logger *logrus.Entry) ExecutionResult {
@@ -241,6 +276,98 @@ func (me *Executor) executeActionConsultRESTServiceURL(hookObj *Hook, w http.Res
 		return createProcessingErrorExecutionResult(hookObj, err)
 	}
 
+	err = prepareRESTServiceResultHook(hookObj, newHookObj, *hookObj.RESTServiceURL, logger)
+	if err != nil {
+		return createProcessingErrorExecutionResult(hookObj, err)
+	}
+
+	executionResult := me.Execute(newHookObj, w, request, logger)
+	executionResult.Hooks = []*Hook{hookObj}
+
+	return executionResult
+}
+
+func (me *Executor) executeActionConsultRESTServiceURLs(hookObj *Hook, w http.ResponseWriter, request *http.Request, response *http.Response, logger *logrus.Entry) ExecutionResult {
+	// Each REST service is consulted as if it were a separate ActionConsultRESTServiceURL hook.
+	// This lets each one of them have its own timeout, retries, contingency hook, etc.
+	//
+	// The hooks resulting from these consultations get combined according to the aggregation strategy.
+
+	if len(hookObj.RESTServices) == 0 {
+		return createProcessingErrorExecutionResult(hookObj, fmt.Errorf("at least one REST service is required"))
+	}
+
+	strategy := RESTServicesAggregationStrategyAllMustPass
+	if hookObj.RESTServicesAggregationStrategy != nil {
+		strategy = *hookObj.RESTServicesAggregationStrategy
+	}
+
+	serviceHooks := make([]Hook, 0, len(hookObj.RESTServices))
+	for idx, serviceDefinition := range hookObj.RESTServices {
+		if serviceDefinition.RESTServiceURL == nil {
+			return createProcessingErrorExecutionResult(hookObj, fmt.Errorf("a RESTServiceURL is required for REST service #%d", idx))
+		}
+
+		serviceHooks = append(serviceHooks, Hook{
+			ID:                    fmt.Sprintf("%s-%d", hookObj.ID, idx),
+			EventType:             hookObj.EventType,
+			Action:                ActionConsultRESTServiceURL,
+			restActionHookDetails: serviceDefinition.restActionHookDetails,
+		})
+	}
+
+	consultingCtx, cancelConsulting := context.WithCancel(context.Background())
+
+	results, err := me.restServiceConsultor.consultMultiple(consultingCtx, request, response, serviceHooks, logger)
+	if err != nil {
+		cancelConsulting()
+		return createProcessingErrorExecutionResult(hookObj, err)
+	}
+
+	aggregatedResults, err := aggregateRESTServiceConsultingResults(strategy, results, len(serviceHooks), logger)
+
+	// The outcome is decided, so REST services which haven't responded yet (if any) don't matter anymore.
+	cancelConsulting()
+
+	if err != nil {
+		return createProcessingErrorExecutionResult(hookObj, err)
+	}
+
+	executionResult := ExecutionResult{
+		Hooks:                         []*Hook{hookObj},
+		ReverseProxyResponseModifiers: []HttpResponseModifierFunc{},
+	}
+
+	for _, result := range aggregatedResults {
+		newHookObj := result.hook
+
+		err = prepareRESTServiceResultHook(hookObj, newHookObj, *hookObj.RESTServices[result.index].RESTServiceURL, logger)
+		if err != nil {
+			return createProcessingErrorExecutionResult(hookObj, err)
+		}
+
+		newHookExecutionResult := me.Execute(newHookObj, w, request, logger)
+
+		executionResult.ReverseProxyResponseModifiers = append(
+			executionResult.ReverseProxyResponseModifiers,
+			newHookExecutionResult.ReverseProxyResponseModifiers...,
+		)
+		executionResult.ResponseSent = newHookExecutionResult.ResponseSent
+		executionResult.SkipNextHooksInChain = newHookExecutionResult.SkipNextHooksInChain
+		executionResult.ProcessingError = newHookExecutionResult.ProcessingError
+
+		if !newHookExecutionResult.NextHooksInChainCanRun() {
+			break
+		}
+	}
+
+	return executionResult
+}
+
+// prepareRESTServiceResultHook sanity-checks and normalizes a hook returned by a REST service (see RESTServiceConsultor),
+// so that it can be executed on behalf of the hook (hookObj) which caused the REST service to be consulted.
+func prepareRESTServiceResultHook(hookObj *Hook, newHookObj *Hook, restServiceURL string, logger *logrus.Entry) error {
+
 	if newHookObj.ID == "" {
 		newHookObj.ID = fmt.Sprintf("%s-unnamed-response", hookObj.ID)
 	}
@@ -267,26 +394,23 @@ func (me *Executor) executeActionConsultRESTServiceURL(hookObj *Hook, w http.Res
 	}
 
 	if hookObj.IsAfterHook() && newHookObj.Action == ActionPassModifiedRequest {
-		return createProcessingErrorExecutionResult(hookObj, fmt.Errorf(
+		return fmt.Errorf(
 			"an after hook (%s) yielded a request-modification hook: %s. It makes no sense - it's already too late to modify the request",
 			hookObj,
 			newHookObj,
-		))
+		)
 	}
 
 	exportedHookJSON, err := json.Marshal(newHookObj)
 	if err != nil {
-		return createProcessingErrorExecutionResult(hookObj, fmt.Errorf("failed exporting hook: %s", err))
+		return fmt.Errorf("failed exporting hook: %s", err)
 	}
 
 	// It's important to be able to debug these network-related hook results easily,
 	// so we're dumping them into the debug log in detail.
-	logger.Debugf("Hook Executor: %s provided a new hook response %s", *hookObj.RESTServiceURL, string(exportedHookJSON))
-
-	executionResult := me.Execute(newHookObj, w, request, logger)
-	executionResult.Hooks = []*Hook{hookObj}
+	logger.Debugf("Hook Executor: %s provided a new hook response %s", restServiceURL, string(exportedHookJSON))
 
-	return executionResult
+	return nil
 }
 
 func executeActionReject(hookObj *Hook, w http.ResponseWriter, request *http.Request, response *http.Response, logger *logrus.Entry) ExecutionResult {
@@ -62,6 +62,25 @@ type restActionHookDetails struct {
 	RESTServiceContingencyHook *Hook `json:"RESTServiceContingencyHook,omitempty"`
 }
 
+// RESTServiceDefinition describes a single REST service to consult when Hook.Action = ActionConsultRESTServiceURLs
+//
+// It supports the same fields as a regular ActionConsultRESTServiceURL hook (timeout, retries, contingency hook, etc.)
+type RESTServiceDefinition struct {
+	restActionHookDetails
+}
+
+// multiRESTActionHookDetails contains some fields which are useful when Hook.Action = ActionConsultRESTServiceURLs
+type multiRESTActionHookDetails struct {
+	// RESTServices specifies the REST services to consult (concurrently).
+	// Required field.
+	RESTServices []*RESTServiceDefinition `json:"RESTServices,omitempty"`
+
+	// RESTServicesAggregationStrategy specifies how the hooks returned by the various RESTServices are combined.
+	// It's one of the `RESTServicesAggregationStrategy*` constants.
+	// If not specified, RESTServicesAggregationStrategyAllMustPass is used.
+	RESTServicesAggregationStrategy *string `json:"RESTServicesAggregationStrategy,omitempty"`
+}
+
 type respondActionHookDetails struct {
 	// Payload specifies the payload to respond with.
 	// This may be some key-value JSON thing (`map[string]interface{}`), a string, etc.
@@ -127,12 +146,20 @@ type Hook struct {
 	// See the various `Action*` constants.
 	Action string `json:"action"`
 
+	// Priority influences the order in which hooks get executed.
+	// Hooks with a higher priority run first. Hooks with the same priority run in the order they're defined in.
+	// Hooks defined in the policy come before those defined elsewhere (see Store), if their priority is the same.
+	// Defaults to 0.
+	Priority int `json:"priority,omitempty"`
+
 	// SkipNextHooksInChain tells whether all other hooks in the same execution chain should be skipped.
 	// Execution chain means "eligible hooks of this same event type".
 	SkipNextHooksInChain bool `json:"skipNextHooksInChain"`
 
 	restActionHookDetails
 
+	multiRESTActionHookDetails
+
 	respondActionHookDetails
 
 	rejectActionHookDetails
@@ -140,6 +167,8 @@ type Hook struct {
 	passModifiedRequestActionHookDetails
 
 	passModifiedResponseActionHookDetails
+
+	contentFilterActionHookDetails
 }
 
 func (me Hook) IsBeforeHook() bool {
@@ -150,7 +179,26 @@ func (me Hook) IsAfterHook() bool {
 	return strings.HasPrefix(me.EventType, "after")
 }
 
-func (me Hook) Validate() error {
+func (me Hook) IsReconciliationHook() bool {
+	return util.IsStringInArray(me.EventType, knownReconciliationEventTypes)
+}
+
+// IsNotificationHook tells whether this hook's only purpose is notifying a REST service about something (reconciliation events, etc.)
+func (me Hook) IsNotificationHook() bool {
+	return util.IsStringInArray(me.EventType, knownNotificationEventTypes)
+}
+
+// HasSideEffects tells whether executing this hook contacts REST services,
+// as opposed to merely deciding the fate of the request (or response) based on the hook's own definition.
+func (me Hook) HasSideEffects() bool {
+	if me.Action == ActionConsultRESTServiceURL || me.Action == ActionConsultRESTServiceURLs {
+		return true
+	}
+
+	return me.Action == ActionFilterContent && me.ContentFilterMode != nil && *me.ContentFilterMode == ContentFilterModeFlag
+}
+
+func (me *Hook) Validate() error {
 	if me.ID == "" {
 		return fmt.Errorf("Hook has no id")
 	}
@@ -170,6 +218,18 @@ func (me Hook) Validate() error {
 		return fmt.Errorf("action=%s cannot be combined with eventType=%s, found in hook #%s", me.Action, me.EventType, me.ID)
 	}
 
+	// Notification hooks (reconciliation ones, etc.) are not tied to HTTP requests, so there's nothing to match against or influence.
+	// All they can do is notify a REST service.
+	if me.IsNotificationHook() {
+		if me.Action != ActionConsultRESTServiceURL {
+			return fmt.Errorf("action=%s cannot be combined with eventType=%s, found in hook #%s", me.Action, me.EventType, me.ID)
+		}
+
+		if len(me.MatchRules) != 0 {
+			return fmt.Errorf("match rules cannot be combined with eventType=%s, found in hook #%s", me.EventType, me.ID)
+		}
+	}
+
 	for idx, matchRule := range me.MatchRules {
 		err := matchRule.validate()
 		if err != nil {
@@ -177,6 +237,20 @@ func (me Hook) Validate() error {
 		}
 	}
 
+	if me.Action == ActionConsultRESTServiceURLs {
+		err := me.validateMultiRESTActionHookDetails()
+		if err != nil {
+			return fmt.Errorf("error when validating hook #%s's REST services: %s", me.ID, err)
+		}
+	}
+
+	if me.Action == ActionFilterContent {
+		err := me.validateContentFilterActionHookDetails()
+		if err != nil {
+			return fmt.Errorf("error when validating hook #%s's content filter: %s", me.ID, err)
+		}
+	}
+
 	// TODO - additional validation logic would be nice to have.
 	// The Executor does some, but it might be helpful to catch problems early on (when loading the policy),
 	// not when actually executing a hook.
@@ -184,6 +258,24 @@ func (me Hook) Validate() error {
 	return nil
 }
 
+func (me Hook) validateMultiRESTActionHookDetails() error {
+	if len(me.RESTServices) == 0 {
+		return fmt.Errorf("at least one REST service is required")
+	}
+
+	for idx, serviceDefinition := range me.RESTServices {
+		if serviceDefinition == nil || serviceDefinition.RESTServiceURL == nil || *serviceDefinition.RESTServiceURL == "" {
+			return fmt.Errorf("REST service #%d has no RESTServiceURL", idx)
+		}
+	}
+
+	if me.RESTServicesAggregationStrategy != nil && !util.IsStringInArray(*me.RESTServicesAggregationStrategy, knownRESTServicesAggregationStrategies) {
+		return fmt.Errorf("%s is an invalid aggregation strategy", *me.RESTServicesAggregationStrategy)
+	}
+
+	return nil
+}
+
 func (me Hook) MatchesRequest(request *http.Request) bool {
 	for _, matchRule := range me.MatchRules {
 		if !matchRule.MatchesRequest(request) {
@@ -0,0 +1,61 @@
+package hook
+
+import (
+	"devture-matrix-corporal/corporal/httphelp"
+	"net/http"
+
+	"github.com/matrix-org/gomatrix"
+)
+
+const (
+	LoginOutcomeSuccess = "success"
+	LoginOutcomeFailure = "failure"
+)
+
+// LoginInformation contains information about a login request, which is made available to login-related hooks
+// (EventTypeBeforeLogin, EventTypeAfterLoginSuccess, EventTypeAfterLoginFailure).
+//
+// It's attached to the request's context (as `loginInformation`) and gets sent to REST services (see RESTServiceConsultor).
+type LoginInformation struct {
+	// UserId is the full Matrix user ID of the user trying to log in.
+	// It's nil if it could not be determined (e.g. for token-based logins).
+	UserId *string `json:"userId"`
+
+	// LoginType is the login type (e.g. `m.login.password`) requested by the client.
+	LoginType string `json:"loginType"`
+
+	// AuthType is the policy-defined authentication type for the user (e.g. `md5`, `rest`, `passthrough`).
+	// It's nil for users which are not managed by the policy.
+	AuthType *string `json:"authType"`
+
+	// Outcome is one of the LoginOutcome* constants.
+	// It's empty for EventTypeBeforeLogin hooks, as the outcome is not known yet.
+	Outcome string `json:"outcome,omitempty"`
+
+	// FailureErrorCode contains the Matrix error code (e.g. `M_FORBIDDEN`) explaining a LoginOutcomeFailure outcome (if available).
+	FailureErrorCode *string `json:"failureErrorCode,omitempty"`
+
+	// FailureErrorMessage contains the error message explaining a LoginOutcomeFailure outcome (if available).
+	FailureErrorMessage *string `json:"failureErrorMessage,omitempty"`
+}
+
+// RecordLoginOutcome determines the outcome of a login request based on the response it got,
+// records it in the login information and returns the event type of the hooks to run for it
+// (EventTypeAfterLoginSuccess or EventTypeAfterLoginFailure).
+func RecordLoginOutcome(loginInformation *LoginInformation, response *http.Response) string {
+	if response.StatusCode == http.StatusOK {
+		loginInformation.Outcome = LoginOutcomeSuccess
+		return EventTypeAfterLoginSuccess
+	}
+
+	loginInformation.Outcome = LoginOutcomeFailure
+
+	var matrixError gomatrix.RespError
+	err := httphelp.GetJsonFromResponseBody(response, &matrixError)
+	if err == nil {
+		loginInformation.FailureErrorCode = &matrixError.ErrCode
+		loginInformation.FailureErrorMessage = &matrixError.Err
+	}
+
+	return EventTypeAfterLoginFailure
+}
@@ -0,0 +1,48 @@
+package hook
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// restServiceEventNotificationRequest represents a request payload to be sent to a REST service
+// when notifying it about some event (see ReconciliationEvent, RoomUpgradeEvent).
+type restServiceEventNotificationRequest struct {
+	Meta restServiceEventNotificationRequestMetaInformation `json:"meta"`
+
+	Event interface{} `json:"event"`
+}
+
+type restServiceEventNotificationRequestMetaInformation struct {
+	// HookID contains the name of the hook that provoked this notification.
+	HookID string `json:"hookId"`
+}
+
+// notifyRESTServiceAboutEvent sends the given event to the specified (notification) hook's REST service.
+//
+// Notifying always happens asynchronously (regardless of RESTServiceAsync), because there's no request to influence.
+// Whatever the REST service responds with is ignored.
+// An error is only returned if the request to the REST service could not be prepared.
+func (me *RESTServiceConsultor) notifyRESTServiceAboutEvent(event interface{}, hook Hook, logger *logrus.Entry) error {
+	notificationPayload := restServiceEventNotificationRequest{
+		Meta: restServiceEventNotificationRequestMetaInformation{
+			HookID: hook.ID,
+		},
+		Event: event,
+	}
+
+	notificationHTTPRequestFactory, err := createConsultingHTTPRequestFactory(notificationPayload, hook, me.defaultTimeoutDuration)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		_, err := me.callRestServiceWithRetries(context.Background(), notificationHTTPRequestFactory, hook, logger)
+		if err != nil {
+			logger.Warnf("Event notification REST service suffered an error: %s", err)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// hooksFile represents the contents of a hooks file
+type hooksFile struct {
+	Hooks []*hook.Hook `json:"hooks"`
+}
+
+// FileProvider loads hooks from a file (or a directory of `*.json` files) into a hook.Store
+// and reloads them whenever they change.
+//
+// Each file is expected to contain a JSON object with a `hooks` key (a list of hooks), just like the policy.
+// When loading from a directory, files are loaded in alphabetical order.
+type FileProvider struct {
+	store  *hook.Store
+	path   string
+	logger *logrus.Logger
+
+	lockLoad sync.Mutex
+	watcher  *fsnotify.Watcher
+}
+
+func NewFileProvider(
+	path string,
+	store *hook.Store,
+	logger *logrus.Logger,
+) (*FileProvider, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed initializing inotify watcher: %s", err)
+	}
+
+	return &FileProvider{
+		store:  store,
+		path:   path,
+		logger: logger,
+
+		watcher: watcher,
+	}, nil
+}
+
+func (me *FileProvider) Start() error {
+	me.logger.Infof("Starting hook provider for: %s", me.path)
+
+	err := me.load()
+	if err != nil {
+		return err
+	}
+
+	go me.watch()
+
+	return nil
+}
+
+func (me *FileProvider) Stop() {
+	me.logger.Infof("Stopping hook provider for: %s", me.path)
+
+	err := me.watcher.Close()
+	if err != nil {
+		me.logger.Errorf("failed closing inotify watcher: %s", err)
+	}
+}
+
+func (me *FileProvider) Reload() {
+	me.logger.Infof("Reloading hooks from: %s", me.path)
+
+	err := me.load()
+	if err != nil {
+		me.logger.Warnf("Failed reloading hooks: %s", err)
+	}
+}
+
+func (me *FileProvider) load() error {
+	me.lockLoad.Lock()
+	defer me.lockLoad.Unlock()
+
+	filePaths, err := me.determineFilePaths()
+	if err != nil {
+		return err
+	}
+
+	hooks := make([]*hook.Hook, 0)
+
+	for _, filePath := range filePaths {
+		fileBytes, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		var file hooksFile
+		err = json.Unmarshal(fileBytes, &file)
+		if err != nil {
+			return fmt.Errorf("hooks load error for %s: %s", filePath, err)
+		}
+
+		hooks = append(hooks, file.Hooks...)
+	}
+
+	err = me.store.Set(hook.StoreSourceFile, hooks)
+	if err != nil {
+		return fmt.Errorf("hooks set error: %s", err)
+	}
+
+	return nil
+}
+
+func (me *FileProvider) determineFilePaths() ([]string, error) {
+	stat, err := os.Stat(me.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !stat.IsDir() {
+		return []string{me.path}, nil
+	}
+
+	entries, err := os.ReadDir(me.path)
+	if err != nil {
+		return nil, err
+	}
+
+	filePaths := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		filePaths = append(filePaths, filepath.Join(me.path, entry.Name()))
+	}
+
+	sort.Strings(filePaths)
+
+	return filePaths, nil
+}
+
+func (me *FileProvider) watch() {
+	go func() {
+		for ev := range me.watcher.Events {
+			// We handle remove events too, because editors like vim would swap the file atomically.
+			// For directories, we also care about files being created or renamed.
+			isWrite := ev.Op&fsnotify.Write == fsnotify.Write
+			isRemove := ev.Op&fsnotify.Remove == fsnotify.Remove
+			isCreate := ev.Op&fsnotify.Create == fsnotify.Create
+			isRename := ev.Op&fsnotify.Rename == fsnotify.Rename
+
+			if !isWrite && !isRemove && !isCreate && !isRename {
+				continue
+			}
+
+			time.AfterFunc(time.Duration(1*time.Second), func() {
+				err := me.load()
+
+				if err == nil {
+					me.logger.Infof("Reloaded hooks from %s", me.path)
+				} else {
+					me.logger.Warnf("Failed to reload hooks from %s: %s", me.path, err)
+				}
+			})
+
+			// If the file itself gets removed, we need to start watching it again.
+			if isRemove && ev.Name == me.path {
+				err := me.watcher.Add(me.path)
+				if err != nil {
+					me.logger.Errorf("failed re-adding watcher for path `%s`: %s", me.path, err)
+				}
+			}
+		}
+	}()
+
+	err := me.watcher.Add(me.path)
+	if err != nil {
+		me.logger.Errorf("failed adding watcher for path `%s`: %s", me.path, err)
+	}
+}
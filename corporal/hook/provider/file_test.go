@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func writeTestHooksFile(t *testing.T, path string, contents string) {
+	err := os.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Fatalf("failed writing %s: %s", path, err)
+	}
+}
+
+func getTestStoreHookIDs(store *hook.Store) []string {
+	ids := make([]string, 0)
+	for _, hookObj := range store.GetMerged() {
+		ids = append(ids, hookObj.ID)
+	}
+	return ids
+}
+
+func TestFileProviderReload(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	dirPath := t.TempDir()
+
+	writeTestHooksFile(t, filepath.Join(dirPath, "2.json"), `{"hooks": [
+		{"id": "second", "eventType": "beforeAnyRequest", "action": "pass.unmodified"}
+	]}`)
+	writeTestHooksFile(t, filepath.Join(dirPath, "1.json"), `{"hooks": [
+		{"id": "first", "eventType": "beforeAnyRequest", "action": "pass.unmodified"}
+	]}`)
+	writeTestHooksFile(t, filepath.Join(dirPath, "ignored.txt"), `not JSON`)
+
+	store := hook.NewStore()
+
+	err := store.Set(hook.StoreSourcePolicy, []*hook.Hook{
+		{ID: "policy", EventType: hook.EventTypeBeforeAnyRequest, Action: hook.ActionPassUnmodified},
+	})
+	if err != nil {
+		t.Fatalf("failed setting policy hooks: %s", err)
+	}
+
+	provider, err := NewFileProvider(dirPath, store, logger)
+	if err != nil {
+		t.Fatalf("failed creating provider: %s", err)
+	}
+
+	err = provider.Start()
+	if err != nil {
+		t.Fatalf("failed starting provider: %s", err)
+	}
+	defer provider.Stop()
+
+	// Files are loaded in alphabetical order
+	expectedIDs := []string{"policy", "first", "second"}
+	if ids := getTestStoreHookIDs(store); !reflect.DeepEqual(ids, expectedIDs) {
+		t.Fatalf("expected hooks %v after starting, got %v", expectedIDs, ids)
+	}
+
+	// Changes get picked up on reload (and get ordered by priority)
+	writeTestHooksFile(t, filepath.Join(dirPath, "2.json"), `{"hooks": [
+		{"id": "second", "eventType": "beforeAnyRequest", "action": "pass.unmodified", "priority": 5},
+		{"id": "third", "eventType": "beforeAnyRequest", "action": "pass.unmodified"}
+	]}`)
+	provider.Reload()
+
+	expectedIDs = []string{"second", "policy", "first", "third"}
+	if ids := getTestStoreHookIDs(store); !reflect.DeepEqual(ids, expectedIDs) {
+		t.Fatalf("expected hooks %v after reloading, got %v", expectedIDs, ids)
+	}
+
+	// Invalid files are not loaded and the previous hooks are kept
+	writeTestHooksFile(t, filepath.Join(dirPath, "1.json"), `{"hooks": [`)
+	provider.Reload()
+
+	if ids := getTestStoreHookIDs(store); !reflect.DeepEqual(ids, expectedIDs) {
+		t.Fatalf("expected hooks %v to be kept after a failed reload (invalid JSON), got %v", expectedIDs, ids)
+	}
+
+	// Hooks whose IDs conflict with the policy's hooks are not loaded either
+	writeTestHooksFile(t, filepath.Join(dirPath, "1.json"), `{"hooks": [
+		{"id": "policy", "eventType": "beforeAnyRequest", "action": "pass.unmodified"}
+	]}`)
+	provider.Reload()
+
+	if ids := getTestStoreHookIDs(store); !reflect.DeepEqual(ids, expectedIDs) {
+		t.Fatalf("expected hooks %v to be kept after a failed reload (conflicting IDs), got %v", expectedIDs, ids)
+	}
+
+	// Removed files are no longer loaded
+	err = os.Remove(filepath.Join(dirPath, "1.json"))
+	if err != nil {
+		t.Fatalf("failed removing file: %s", err)
+	}
+	provider.Reload()
+
+	expectedIDs = []string{"second", "policy", "third"}
+	if ids := getTestStoreHookIDs(store); !reflect.DeepEqual(ids, expectedIDs) {
+		t.Fatalf("expected hooks %v after removing a file, got %v", expectedIDs, ids)
+	}
+}
@@ -0,0 +1,38 @@
+package hook
+
+import (
+	"devture-matrix-corporal/corporal/matrix"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReconciliationEvent describes something that the reconciler did (successfully).
+//
+// It's sent to the REST service of hooks with one of the `EventTypeReconciliation*` event types.
+type ReconciliationEvent struct {
+	// EventType is one of the `EventTypeReconciliation*` constants
+	EventType string `json:"eventType"`
+
+	// ReconciliationAction is the reconciliation action (e.g. `user.create`) which caused this event
+	ReconciliationAction string `json:"reconciliationAction"`
+
+	// UserId is the full Matrix User ID (MXID) of the user this event is about (if any).
+	UserId *string `json:"userId,omitempty"`
+
+	// RoomId is the ID of the room this event is about (if any).
+	RoomId *string `json:"roomId,omitempty"`
+
+	// PowerLevels contains the new power levels (user ID -> power level) for EventTypeReconciliationRoomPowerLevelsChanged events.
+	PowerLevels map[string]int `json:"powerLevels,omitempty"`
+
+	// RoomPowerLevels contains the new room-wide power levels (kick, ban, etc.) for EventTypeReconciliationRoomPowerLevelsChanged events
+	// caused by ActionRoomSetPowerLevels.
+	RoomPowerLevels *matrix.RoomPowerLevelsContent `json:"roomPowerLevels,omitempty"`
+}
+
+// NotifyAboutReconciliationEvent sends the given reconciliation event to the specified hook's REST service.
+//
+// See notifyRESTServiceAboutEvent for details.
+func (me *RESTServiceConsultor) NotifyAboutReconciliationEvent(event ReconciliationEvent, hook Hook, logger *logrus.Entry) error {
+	return me.notifyRESTServiceAboutEvent(event, hook, logger)
+}
@@ -0,0 +1,112 @@
+package hook
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// RESTServicesAggregationStrategyFirstRejectWins is a strategy which waits for REST services to respond
+	// and uses the first rejecting (responding) hook as soon as it arrives, without waiting for the others.
+	//
+	// If none of the REST services reject, the hooks they returned are all executed (in the order the services were defined).
+	//
+	// Any REST service failure (not handled by a contingency hook) results in a processing error right away.
+	RESTServicesAggregationStrategyFirstRejectWins = "firstRejectWins"
+
+	// RESTServicesAggregationStrategyAllMustPass is a strategy which waits for all REST services to respond.
+	//
+	// If any of them failed (and had no contingency hook), a processing error is reported.
+	// If any of them rejected (responded), the rejecting hook of the first such service (in definition order) is used.
+	// Otherwise, the hooks they returned are all executed (in the order the services were defined).
+	//
+	// Unlike RESTServicesAggregationStrategyFirstRejectWins, the outcome does not depend on which service responds the fastest.
+	RESTServicesAggregationStrategyAllMustPass = "allMustPass"
+
+	// RESTServicesAggregationStrategyFirstResponse is a strategy which uses the hook returned by whichever REST service responds first.
+	//
+	// Failing services (without a contingency hook) are skipped. A processing error is only reported if all services fail.
+	RESTServicesAggregationStrategyFirstResponse = "firstResponse"
+)
+
+var knownRESTServicesAggregationStrategies = []string{
+	RESTServicesAggregationStrategyFirstRejectWins,
+	RESTServicesAggregationStrategyAllMustPass,
+	RESTServicesAggregationStrategyFirstResponse,
+}
+
+// isRespondingAction tells whether the given action results in a response being sent (as opposed to letting the request pass).
+func isRespondingAction(action string) bool {
+	return action == ActionReject || action == ActionRespond
+}
+
+// aggregateRESTServiceConsultingResults combines the results of consulting multiple REST services (see RESTServiceConsultor.consultMultiple)
+// into a list of results, the hooks of which need to be executed (in order).
+func aggregateRESTServiceConsultingResults(
+	strategy string,
+	results <-chan restServiceConsultingResult,
+	resultsCount int,
+	logger *logrus.Entry,
+) ([]restServiceConsultingResult, error) {
+	if strategy == RESTServicesAggregationStrategyFirstResponse {
+		var lastErr error
+
+		for i := 0; i < resultsCount; i++ {
+			result := <-results
+			if result.err != nil {
+				logger.Warnf("REST service #%d failed, waiting for others: %s", result.index, result.err)
+				lastErr = result.err
+				continue
+			}
+
+			return []restServiceConsultingResult{result}, nil
+		}
+
+		return nil, fmt.Errorf("all %d REST services failed. last error: %s", resultsCount, lastErr)
+	}
+
+	if strategy == RESTServicesAggregationStrategyFirstRejectWins {
+		resultsByIndex := make([]restServiceConsultingResult, resultsCount)
+
+		for i := 0; i < resultsCount; i++ {
+			result := <-results
+			if result.err != nil {
+				return nil, fmt.Errorf("REST service #%d failed: %s", result.index, result.err)
+			}
+
+			if isRespondingAction(result.hook.Action) {
+				return []restServiceConsultingResult{result}, nil
+			}
+
+			resultsByIndex[result.index] = result
+		}
+
+		return resultsByIndex, nil
+	}
+
+	if strategy == RESTServicesAggregationStrategyAllMustPass {
+		resultsByIndex := make([]restServiceConsultingResult, resultsCount)
+
+		for i := 0; i < resultsCount; i++ {
+			result := <-results
+			resultsByIndex[result.index] = result
+		}
+
+		for _, result := range resultsByIndex {
+			if result.err != nil {
+				return nil, fmt.Errorf("REST service #%d failed: %s", result.index, result.err)
+			}
+		}
+
+		for _, result := range resultsByIndex {
+			if isRespondingAction(result.hook.Action) {
+				return []restServiceConsultingResult{result}, nil
+			}
+		}
+
+		return resultsByIndex, nil
+	}
+
+	return nil, fmt.Errorf("unknown REST services aggregation strategy: %s", strategy)
+}
@@ -0,0 +1,328 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testRESTService describes how a fake REST service (see startTestRESTService) behaves
+type testRESTService struct {
+	// delay specifies how long the service takes to respond
+	delay time.Duration
+
+	// statusCode specifies the HTTP status code to respond with. Services responding with non-200 status codes fail.
+	statusCode int
+
+	// action specifies the action of the hook the service responds with
+	action string
+
+	// timeoutMilliseconds specifies the request timeout to use when consulting the service (0 means the default)
+	timeoutMilliseconds uint
+
+	// contingencyAction specifies the action of the contingency hook to use if the service fails ("" means no contingency hook)
+	contingencyAction string
+}
+
+func startTestRESTService(definition testRESTService) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(definition.delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		if definition.statusCode != http.StatusOK {
+			w.WriteHeader(definition.statusCode)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Hook{Action: definition.action})
+	}))
+}
+
+func TestRESTServicesAggregation(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	type testCase struct {
+		name     string
+		strategy string
+		services []testRESTService
+
+		// expectedIndexes contains the indexes of the services whose hooks are expected to be executed (in order)
+		expectedIndexes []int
+		expectedActions []string
+		expectedError   bool
+	}
+
+	testCases := []testCase{
+		{
+			name:     "firstRejectWins: rejection is used as soon as it arrives",
+			strategy: RESTServicesAggregationStrategyFirstRejectWins,
+			services: []testRESTService{
+				{delay: 2 * time.Second, statusCode: http.StatusOK, action: ActionPassUnmodified},
+				{statusCode: http.StatusOK, action: ActionReject},
+			},
+			expectedIndexes: []int{1},
+			expectedActions: []string{ActionReject},
+		},
+		{
+			name:     "firstRejectWins: all passing hooks are used in definition order",
+			strategy: RESTServicesAggregationStrategyFirstRejectWins,
+			services: []testRESTService{
+				{delay: 100 * time.Millisecond, statusCode: http.StatusOK, action: ActionPassUnmodified},
+				{statusCode: http.StatusOK, action: ActionPassModifiedRequest},
+			},
+			expectedIndexes: []int{0, 1},
+			expectedActions: []string{ActionPassUnmodified, ActionPassModifiedRequest},
+		},
+		{
+			name:     "firstRejectWins: failure is an error",
+			strategy: RESTServicesAggregationStrategyFirstRejectWins,
+			services: []testRESTService{
+				{statusCode: http.StatusOK, action: ActionPassUnmodified},
+				{statusCode: http.StatusInternalServerError},
+			},
+			expectedError: true,
+		},
+		{
+			name:     "allMustPass: first rejection in definition order wins, regardless of timing",
+			strategy: RESTServicesAggregationStrategyAllMustPass,
+			services: []testRESTService{
+				{statusCode: http.StatusOK, action: ActionPassUnmodified},
+				{delay: 100 * time.Millisecond, statusCode: http.StatusOK, action: ActionRespond},
+				{statusCode: http.StatusOK, action: ActionReject},
+			},
+			expectedIndexes: []int{1},
+			expectedActions: []string{ActionRespond},
+		},
+		{
+			name:     "allMustPass: all passing hooks are used in definition order",
+			strategy: RESTServicesAggregationStrategyAllMustPass,
+			services: []testRESTService{
+				{delay: 100 * time.Millisecond, statusCode: http.StatusOK, action: ActionPassUnmodified},
+				{statusCode: http.StatusOK, action: ActionPassModifiedRequest},
+			},
+			expectedIndexes: []int{0, 1},
+			expectedActions: []string{ActionPassUnmodified, ActionPassModifiedRequest},
+		},
+		{
+			name:     "allMustPass: partial failure is an error, even if another service rejects",
+			strategy: RESTServicesAggregationStrategyAllMustPass,
+			services: []testRESTService{
+				{statusCode: http.StatusOK, action: ActionReject},
+				{statusCode: http.StatusServiceUnavailable},
+			},
+			expectedError: true,
+		},
+		{
+			name:     "allMustPass: failure handled by a contingency hook is not an error",
+			strategy: RESTServicesAggregationStrategyAllMustPass,
+			services: []testRESTService{
+				{statusCode: http.StatusOK, action: ActionPassUnmodified},
+				{statusCode: http.StatusServiceUnavailable, contingencyAction: ActionPassUnmodified},
+			},
+			expectedIndexes: []int{0, 1},
+			expectedActions: []string{ActionPassUnmodified, ActionPassUnmodified},
+		},
+		{
+			name:     "allMustPass: timeout is an error",
+			strategy: RESTServicesAggregationStrategyAllMustPass,
+			services: []testRESTService{
+				{statusCode: http.StatusOK, action: ActionPassUnmodified},
+				{delay: 2 * time.Second, statusCode: http.StatusOK, action: ActionPassUnmodified, timeoutMilliseconds: 50},
+			},
+			expectedError: true,
+		},
+		{
+			name:     "firstResponse: fastest service wins",
+			strategy: RESTServicesAggregationStrategyFirstResponse,
+			services: []testRESTService{
+				{delay: 2 * time.Second, statusCode: http.StatusOK, action: ActionPassUnmodified},
+				{statusCode: http.StatusOK, action: ActionReject},
+			},
+			expectedIndexes: []int{1},
+			expectedActions: []string{ActionReject},
+		},
+		{
+			name:     "firstResponse: failing services are skipped",
+			strategy: RESTServicesAggregationStrategyFirstResponse,
+			services: []testRESTService{
+				{statusCode: http.StatusInternalServerError},
+				{delay: 100 * time.Millisecond, statusCode: http.StatusOK, action: ActionPassUnmodified},
+			},
+			expectedIndexes: []int{1},
+			expectedActions: []string{ActionPassUnmodified},
+		},
+		{
+			name:     "firstResponse: timed out services are skipped",
+			strategy: RESTServicesAggregationStrategyFirstResponse,
+			services: []testRESTService{
+				{delay: 2 * time.Second, statusCode: http.StatusOK, action: ActionReject, timeoutMilliseconds: 50},
+				{delay: 200 * time.Millisecond, statusCode: http.StatusOK, action: ActionPassUnmodified},
+			},
+			expectedIndexes: []int{1},
+			expectedActions: []string{ActionPassUnmodified},
+		},
+		{
+			name:     "firstResponse: all services failing is an error",
+			strategy: RESTServicesAggregationStrategyFirstResponse,
+			services: []testRESTService{
+				{statusCode: http.StatusInternalServerError},
+				{delay: 2 * time.Second, statusCode: http.StatusOK, action: ActionPassUnmodified, timeoutMilliseconds: 50},
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc //make local
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			hooks := make([]Hook, 0, len(tc.services))
+			for _, definition := range tc.services {
+				server := startTestRESTService(definition)
+				defer server.Close()
+
+				serviceURL := server.URL
+
+				hookObj := Hook{
+					ID:     tc.name,
+					Action: ActionConsultRESTServiceURL,
+				}
+				hookObj.RESTServiceURL = &serviceURL
+
+				if definition.timeoutMilliseconds != 0 {
+					timeoutMilliseconds := definition.timeoutMilliseconds
+					hookObj.RESTServiceRequestTimeoutMilliseconds = &timeoutMilliseconds
+				}
+
+				if definition.contingencyAction != "" {
+					hookObj.RESTServiceContingencyHook = &Hook{Action: definition.contingencyAction}
+				}
+
+				hooks = append(hooks, hookObj)
+			}
+
+			request := httptest.NewRequest(
+				"PUT",
+				"/_matrix/client/v3/rooms/!room:host/send/m.room.message/1",
+				strings.NewReader(`{"msgtype": "m.text", "body": "hello"}`),
+			)
+
+			consultor := NewRESTServiceConsultor(5 * time.Second)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			results, err := consultor.consultMultiple(ctx, request, nil, hooks, logrus.NewEntry(logger))
+			if err != nil {
+				t.Fatalf("failed consulting REST services: %s", err)
+			}
+
+			aggregatedResults, err := aggregateRESTServiceConsultingResults(tc.strategy, results, len(hooks), logrus.NewEntry(logger))
+			cancel()
+
+			if tc.expectedError {
+				if err == nil {
+					t.Fatalf("expected an error, but got %d results", len(aggregatedResults))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if len(aggregatedResults) != len(tc.expectedIndexes) {
+				t.Fatalf("expected %d results, got %d", len(tc.expectedIndexes), len(aggregatedResults))
+			}
+
+			for idx, result := range aggregatedResults {
+				if result.index != tc.expectedIndexes[idx] {
+					t.Errorf("expected result #%d to be for service #%d, got #%d", idx, tc.expectedIndexes[idx], result.index)
+				}
+
+				if result.hook.Action != tc.expectedActions[idx] {
+					t.Errorf("expected result #%d to have action %s, got %s", idx, tc.expectedActions[idx], result.hook.Action)
+				}
+			}
+		})
+	}
+}
+
+func TestRESTServicesConsultingCancellation(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	slowServiceCancelled := make(chan struct{})
+	slowService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client going away once the request body has been consumed
+		_, _ = io.ReadAll(r.Body)
+
+		select {
+		case <-time.After(10 * time.Second):
+		case <-r.Context().Done():
+			close(slowServiceCancelled)
+		}
+	}))
+	defer slowService.Close()
+
+	fastService := startTestRESTService(testRESTService{statusCode: http.StatusOK, action: ActionPassUnmodified})
+	defer fastService.Close()
+
+	hooks := make([]Hook, 0, 2)
+	for _, serviceURL := range []string{slowService.URL, fastService.URL} {
+		serviceURL := serviceURL
+
+		hookObj := Hook{
+			ID:     "cancellation",
+			Action: ActionConsultRESTServiceURL,
+		}
+		hookObj.RESTServiceURL = &serviceURL
+
+		hooks = append(hooks, hookObj)
+	}
+
+	request := httptest.NewRequest(
+		"PUT",
+		"/_matrix/client/v3/rooms/!room:host/send/m.room.message/1",
+		strings.NewReader(`{"msgtype": "m.text", "body": "hello"}`),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results, err := NewRESTServiceConsultor(30*time.Second).consultMultiple(ctx, request, nil, hooks, logrus.NewEntry(logger))
+	if err != nil {
+		t.Fatalf("failed consulting REST services: %s", err)
+	}
+
+	aggregatedResults, err := aggregateRESTServiceConsultingResults(RESTServicesAggregationStrategyFirstResponse, results, len(hooks), logrus.NewEntry(logger))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(aggregatedResults) != 1 || aggregatedResults[0].index != 1 {
+		t.Fatalf("expected the fast service to win, got: %v", aggregatedResults)
+	}
+
+	cancel()
+
+	select {
+	case <-slowServiceCancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the request to the slow service to be cancelled once the outcome was decided")
+	}
+}
@@ -14,7 +14,19 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-type httpRequestFactory func() (*http.Request, error)
+// httpRequestFactory creates a new HTTP request (with its own timeout), bound to the given (parent) context.
+// The returned cancel function releases the request's context and needs to be called once done with the request.
+type httpRequestFactory func(ctx context.Context) (*http.Request, context.CancelFunc, error)
+
+// restServiceConsultingResult represents the outcome of consulting one of many REST services (see consultMultiple).
+type restServiceConsultingResult struct {
+	// index is the index of the hook (in the list given to consultMultiple) that this result is for
+	index int
+
+	hook *Hook
+
+	err error
+}
 
 // restServiceConsultingRequest reprents as request payload to be sent to a REST service.
 //
@@ -30,6 +42,14 @@ type restServiceConsultingRequest struct {
 	// Response contains the upstream response information (if available).
 	// This is only available for `after*` hooks.
 	Response *restServiceConsultingRequestResponseInformation `json:"response"`
+
+	// Login contains information about the login request (if available).
+	// This is only available for login-related hooks (EventTypeBeforeLogin, EventTypeAfterLoginSuccess, EventTypeAfterLoginFailure).
+	Login *LoginInformation `json:"login,omitempty"`
+
+	// ContentFilter contains information about the content that matched (if available).
+	// This is only available for ActionFilterContent hooks notifying about flagged content.
+	ContentFilter *ContentFilterInformation `json:"contentFilter,omitempty"`
 }
 
 // restServiceConsultingRequestMetaInformation represents the meta information about an HTTP request we're consulting about.
@@ -104,11 +124,73 @@ func (me *RESTServiceConsultor) Consult(request *http.Request, response *http.Re
 		return nil, err
 	}
 
+	return me.consultUsingRequestFactory(context.Background(), consultingHTTPRequestFactory, hook, logger)
+}
+
+// consultMultiple consults the specified REST services concurrently.
+//
+// Results are delivered to the returned channel as they arrive (one per hook), so the order is not guaranteed.
+// Each result carries the index of the hook it belongs to.
+//
+// The channel is buffered, so callers are free to stop reading from it early (e.g. after finding a decisive result).
+// Callers are expected to cancel the given context once they're no longer interested in the remaining results,
+// so that requests to REST services which haven't responded yet get aborted.
+func (me *RESTServiceConsultor) consultMultiple(
+	ctx context.Context,
+	request *http.Request,
+	response *http.Response,
+	hooks []Hook,
+	logger *logrus.Entry,
+) (<-chan restServiceConsultingResult, error) {
+	// Request factories need to be prepared sequentially.
+	// Preparing them reads (and restores) the request and response bodies, which is not safe to do concurrently.
+	consultingHTTPRequestFactories := make([]httpRequestFactory, 0, len(hooks))
+	for _, hook := range hooks {
+		consultingHTTPRequestFactory, err := prepareConsultingHTTPRequestFactory(request, response, hook, me.defaultTimeoutDuration)
+		if err != nil {
+			return nil, err
+		}
+
+		consultingHTTPRequestFactories = append(consultingHTTPRequestFactories, consultingHTTPRequestFactory)
+	}
+
+	results := make(chan restServiceConsultingResult, len(hooks))
+
+	for idx, hook := range hooks {
+		go func(idx int, hook Hook, consultingHTTPRequestFactory httpRequestFactory) {
+			responseHook, err := me.consultUsingRequestFactory(
+				ctx,
+				consultingHTTPRequestFactory,
+				hook,
+				logger.WithField("RESTServiceIndex", idx),
+			)
+
+			results <- restServiceConsultingResult{
+				index: idx,
+				hook:  responseHook,
+				err:   err,
+			}
+		}(idx, hook, consultingHTTPRequestFactories[idx])
+	}
+
+	return results, nil
+}
+
+// consultUsingRequestFactory consults a REST service using requests created by the given factory.
+//
+// Synchronous requests get aborted when the given context is cancelled.
+// Asynchronous ones (see Hook.RESTServiceAsync) outlive whatever requested them, so they're not bound to it.
+func (me *RESTServiceConsultor) consultUsingRequestFactory(
+	ctx context.Context,
+	consultingHTTPRequestFactory httpRequestFactory,
+	hook Hook,
+	logger *logrus.Entry,
+) (*Hook, error) {
 	if hook.RESTServiceAsync {
 		// We do the same thing we do synchronously. We just do it in the background and don't care what happens.
 		// Still, logging, etc., is done.
 		go func() {
-			_, err := me.callRestServiceWithRetries(consultingHTTPRequestFactory, hook, logger)
+			_, err := me.callRestServiceWithRetries(context.Background(), consultingHTTPRequestFactory, hook, logger)
 			if err != nil {
 				logger.Warnf("Async REST service suffered an error: %s", err)
 			}
@@ -121,8 +203,13 @@ func (me *RESTServiceConsultor) Consult(request *http.Request, response *http.Re
 		return &Hook{Action: ActionPassUnmodified}, nil
 	}
 
-	responseHook, err := me.callRestServiceWithRetries(consultingHTTPRequestFactory, hook, logger)
+	responseHook, err := me.callRestServiceWithRetries(ctx, consultingHTTPRequestFactory, hook, logger)
 	if err != nil {
+		if ctx.Err() != nil {
+			// Nobody is interested in the result anymore, so there's no point in falling back to the contingency hook.
+			return nil, err
+		}
+
 		if hook.RESTServiceContingencyHook == nil {
 			// No contingency. We have no choice but to error-out.
 			return nil, err
@@ -136,7 +223,12 @@ func (me *RESTServiceConsultor) Consult(request *http.Request, response *http.Re
 	return responseHook, nil
 }
 
+// callRestServiceWithRetries calls the hook's REST service, retrying failed attempts (see Hook.RESTServiceRetryAttempts).
+//
+// Each attempt has its own timeout and releases its resources as soon as it's done.
+// Cancelling the given context aborts the current attempt (if any) and prevents new ones.
 func (me *RESTServiceConsultor) callRestServiceWithRetries(
+	ctx context.Context,
 	requestFactory httpRequestFactory,
 	hook Hook,
 	logger *logrus.Entry,
@@ -149,63 +241,49 @@ func (me *RESTServiceConsultor) callRestServiceWithRetries(
 	var restError error
 
 	for attemptNumber := uint(1); attemptNumber <= attemptsCount; attemptNumber++ {
-		requestToSend, err := requestFactory()
-		if err != nil {
-			logger.Errorf("RESTServiceConsultor: failed preparing HTTP Request: %s", err)
-			return nil, err
-		}
-
-		logger = logger.WithFields(logrus.Fields{
-			"RESTRrequestMethod": requestToSend.Method,
-			"RESTRrequestURL":    requestToSend.URL,
-			"RESTRequestAttempt": attemptNumber,
-		})
-
 		if attemptNumber > 1 {
 			// All attempts after the first one are potentially delayed.
+			// This happens before preparing the request, so that waiting does not eat into the attempt's timeout.
 			if hook.RESTServiceRetryWaitTimeMilliseconds != nil {
 				logger.Debugf("Waiting %d ms before retrying\n", *hook.RESTServiceRetryWaitTimeMilliseconds)
 
 				t := time.NewTimer(time.Duration(*hook.RESTServiceRetryWaitTimeMilliseconds) * time.Millisecond)
-				defer t.Stop()
-				<-t.C
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					t.Stop()
+				}
 			}
 		}
 
-		logger.Debugf("RESTServiceConsultor: making HTTP request")
-
-		resp, err := me.httpClient.Do(requestToSend)
-		if err != nil {
-			restError = fmt.Errorf("error fetching from URL: %s", err)
-			logger.Warnf("RESTServiceConsultor: failed: %s", restError)
-			continue
-		}
-
-		defer resp.Body.Close() //nolint:errcheck
-
-		if resp.StatusCode != 200 {
-			restError = fmt.Errorf("non-200 response: %d", resp.StatusCode)
-			logger.Warnf("RESTServiceConsultor: failed: %s", restError)
-			continue
+		if ctx.Err() != nil {
+			logger.Debugf("RESTServiceConsultor: giving up (no longer needed)")
+			return nil, fmt.Errorf("no longer needed: %s", ctx.Err())
 		}
 
-		bodyBytes, err := io.ReadAll(resp.Body)
+		requestToSend, cancel, err := requestFactory(ctx)
 		if err != nil {
-			// This is probably an error on our side, so retrying may be silly.
-			restError = fmt.Errorf("failed reading HTTP response body: %s", err)
-			logger.Warnf("RESTServiceConsultor: failed: %s", restError)
-			continue
+			logger.Errorf("RESTServiceConsultor: failed preparing HTTP Request: %s", err)
+			return nil, err
 		}
 
-		var responseHook Hook
-		err = json.Unmarshal(bodyBytes, &responseHook)
+		logger = logger.WithFields(logrus.Fields{
+			"RESTRrequestMethod": requestToSend.Method,
+			"RESTRrequestURL":    requestToSend.URL,
+			"RESTRequestAttempt": attemptNumber,
+		})
+
+		responseHook, err := me.callRestService(requestToSend, logger)
+		cancel()
 		if err != nil {
-			restError = fmt.Errorf("failed parsing JSON out of response: %s", err)
-			logger.Warnf("RESTServiceConsultor: failed: %s", restError)
+			restError = err
+			if ctx.Err() == nil {
+				logger.Warnf("RESTServiceConsultor: failed: %s", restError)
+			}
 			continue
 		}
 
-		return &responseHook, nil
+		return responseHook, nil
 	}
 
 	err := fmt.Errorf(
@@ -214,11 +292,44 @@ func (me *RESTServiceConsultor) callRestServiceWithRetries(
 		restError,
 	)
 
-	logger.Warnf("RESTServiceConsultor: ultimately failed: %s", restError)
+	if ctx.Err() == nil {
+		logger.Warnf("RESTServiceConsultor: ultimately failed: %s", restError)
+	}
 
 	return nil, err
 }
 
+// callRestService makes a single attempt at calling a REST service and interprets its response as a hook.
+// The response is fully read (and closed) before returning, so the caller can release the request's context right after.
+func (me *RESTServiceConsultor) callRestService(requestToSend *http.Request, logger *logrus.Entry) (*Hook, error) {
+	logger.Debugf("RESTServiceConsultor: making HTTP request")
+
+	resp, err := me.httpClient.Do(requestToSend)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching from URL: %s", err)
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("non-200 response: %d", resp.StatusCode)
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		// This is probably an error on our side, so retrying may be silly.
+		return nil, fmt.Errorf("failed reading HTTP response body: %s", err)
+	}
+
+	var responseHook Hook
+	err = json.Unmarshal(bodyBytes, &responseHook)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing JSON out of response: %s", err)
+	}
+
+	return &responseHook, nil
+}
+
 func prepareConsultingHTTPRequestFactory(
 	request *http.Request,
 	response *http.Response,
@@ -241,6 +352,19 @@ func prepareConsultingHTTPRequestFactory(
 		return nil, fmt.Errorf("could not prepare request payload to be sent to the REST service: %s", err)
 	}
 
+	return createConsultingHTTPRequestFactory(consultingRequestPayload, hook, defaultTimeoutDuration)
+}
+
+// createConsultingHTTPRequestFactory creates a factory for HTTP requests which send the given payload to the hook's REST service.
+func createConsultingHTTPRequestFactory(
+	consultingRequestPayload interface{},
+	hook Hook,
+	defaultTimeoutDuration time.Duration,
+) (httpRequestFactory, error) {
+	if hook.RESTServiceURL == nil || *hook.RESTServiceURL == "" {
+		return nil, fmt.Errorf("cannot use NewRESTServiceConsultor with an empty RESTServiceURL")
+	}
+
 	consultingRequestPayloadBytes, err := json.Marshal(consultingRequestPayload)
 	if err != nil {
 		return nil, fmt.Errorf("could not serialize request payload to be sent to the REST service: %s", err)
@@ -256,10 +380,11 @@ func prepareConsultingHTTPRequestFactory(
 		timeoutDuration = time.Duration(*hook.RESTServiceRequestTimeoutMilliseconds) * time.Millisecond
 	}
 
-	return func() (*http.Request, error) {
+	return func(parentCtx context.Context) (*http.Request, context.CancelFunc, error) {
 		// This needs to be done each time, because it uses absolute time inside.
-		ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
-		defer cancel()
+		// The context needs to remain alive while the request is being made (and its response read),
+		// so cancelling it is left to the caller.
+		ctx, cancel := context.WithTimeout(parentCtx, timeoutDuration)
 
 		consultingHTTPRequest, err := http.NewRequestWithContext(
 			ctx,
@@ -268,7 +393,8 @@ func prepareConsultingHTTPRequestFactory(
 			bytes.NewReader(consultingRequestPayloadBytes),
 		)
 		if err != nil {
-			return nil, err
+			cancel()
+			return nil, nil, err
 		}
 
 		consultingHTTPRequest.Header.Set("Content-Type", "application/json")
@@ -278,7 +404,7 @@ func prepareConsultingHTTPRequestFactory(
 			}
 		}
 
-		return consultingHTTPRequest, nil
+		return consultingHTTPRequest, cancel, nil
 	}, nil
 }
 
@@ -306,6 +432,16 @@ func prepareConsultingHTTPRequestPayload(request *http.Request, response *http.R
 		consultingRequest.Meta.AuthenticatedMatrixUserID = &matrixUserIDString
 	}
 
+	loginInformationInterface := request.Context().Value("loginInformation")
+	if loginInformationInterface != nil {
+		consultingRequest.Login = loginInformationInterface.(*LoginInformation)
+	}
+
+	contentFilterInformationInterface := request.Context().Value("contentFilterInformation")
+	if contentFilterInformationInterface != nil {
+		consultingRequest.ContentFilter = contentFilterInformationInterface.(*ContentFilterInformation)
+	}
+
 	if response != nil {
 		consultingRequest.Response = &restServiceConsultingRequestResponseInformation{
 			StatusCode: response.StatusCode,
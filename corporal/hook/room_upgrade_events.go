@@ -0,0 +1,29 @@
+package hook
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// RoomUpgradeEvent describes a managed room having been upgraded (replaced by a new room).
+//
+// It's sent to the REST service of hooks with the EventTypeManagedRoomUpgraded event type.
+type RoomUpgradeEvent struct {
+	// EventType is EventTypeManagedRoomUpgraded
+	EventType string `json:"eventType"`
+
+	// UserId is the full Matrix User ID (MXID) of the user who upgraded the room.
+	UserId string `json:"userId"`
+
+	// RoomId is the ID of the old (now tombstoned) room.
+	RoomId string `json:"roomId"`
+
+	// ReplacementRoomId is the ID of the new room, which replaces RoomId.
+	ReplacementRoomId string `json:"replacementRoomId"`
+}
+
+// NotifyAboutRoomUpgradeEvent sends the given room upgrade event to the specified hook's REST service.
+//
+// See notifyRESTServiceAboutEvent for details.
+func (me *RESTServiceConsultor) NotifyAboutRoomUpgradeEvent(event RoomUpgradeEvent, hook Hook, logger *logrus.Entry) error {
+	return me.notifyRESTServiceAboutEvent(event, hook, logger)
+}
@@ -0,0 +1,151 @@
+package hook
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	// StoreSourcePolicy is the Store source for hooks defined in the policy (Policy.Hooks).
+	// These are kept in sync with the policy by the policy store.
+	StoreSourcePolicy = "policy"
+
+	// StoreSourceFile is the Store source for hooks loaded from a file or directory (see provider.FileProvider)
+	StoreSourceFile = "file"
+
+	// StoreSourceApi is the Store source for hooks submitted via the HTTP API
+	StoreSourceApi = "api"
+)
+
+// storeSourcesOrdered specifies the order in which hooks from the various sources get merged
+var storeSourcesOrdered = []string{
+	StoreSourcePolicy,
+	StoreSourceFile,
+	StoreSourceApi,
+}
+
+// Store holds all hooks (those defined in the policy, as well as ones defined elsewhere), grouped by the source they come from.
+//
+// Hooks from all sources get merged and ordered by priority whenever some source's hooks change (see GetMerged).
+// Hook IDs are unique across all sources.
+// Unlike the policy, changing hooks from sources other than StoreSourcePolicy does not trigger reconciliation.
+type Store struct {
+	hooksBySource map[string][]*Hook
+	merged        []*Hook
+	lock          sync.RWMutex
+}
+
+func NewStore() *Store {
+	return &Store{
+		hooksBySource: map[string][]*Hook{},
+		merged:        []*Hook{},
+	}
+}
+
+// Get returns the hooks coming from the specified source (one of the `StoreSource*` constants)
+func (me *Store) Get(source string) []*Hook {
+	me.lock.RLock()
+	defer me.lock.RUnlock()
+
+	hooks, exists := me.hooksBySource[source]
+	if !exists {
+		return []*Hook{}
+	}
+
+	return hooks
+}
+
+// GetMerged returns the hooks from all sources, ordered by priority.
+//
+// Hooks of the same priority retain the order of their sources (see storeSourcesOrdered) and their order within a source.
+func (me *Store) GetMerged() []*Hook {
+	me.lock.RLock()
+	defer me.lock.RUnlock()
+
+	return me.merged
+}
+
+// CheckIDsAreUnique ensures that the given hooks (which are to replace all hooks coming from the specified source)
+// do not use the same IDs as hooks coming from other sources.
+func (me *Store) CheckIDsAreUnique(source string, hooks []*Hook) error {
+	me.lock.RLock()
+	defer me.lock.RUnlock()
+
+	return me.checkIDsAreUnique(source, hooks)
+}
+
+func (me *Store) checkIDsAreUnique(source string, hooks []*Hook) error {
+	hookIDToSourceMap := map[string]string{}
+	for otherSource, otherHooks := range me.hooksBySource {
+		if otherSource == source {
+			continue
+		}
+
+		for _, hookObj := range otherHooks {
+			hookIDToSourceMap[hookObj.ID] = otherSource
+		}
+	}
+
+	for idx, hookObj := range hooks {
+		if hookObj == nil {
+			return fmt.Errorf("hook at index `%d` is empty", idx)
+		}
+
+		existingSource, exists := hookIDToSourceMap[hookObj.ID]
+		if exists {
+			return fmt.Errorf(
+				"hook at index `%d` (ID = %s) has the same ID as another hook (from source: %s). Assign unique hook IDs to prevent confusion",
+				idx,
+				hookObj.ID,
+				existingSource,
+			)
+		}
+
+		hookIDToSourceMap[hookObj.ID] = source
+	}
+
+	return nil
+}
+
+// Set validates and replaces all hooks coming from the specified source (one of the `StoreSource*` constants)
+func (me *Store) Set(source string, hooks []*Hook) error {
+	me.lock.Lock()
+	defer me.lock.Unlock()
+
+	err := me.checkIDsAreUnique(source, hooks)
+	if err != nil {
+		return err
+	}
+
+	for idx, hookObj := range hooks {
+		err := hookObj.Validate()
+		if err != nil {
+			return fmt.Errorf(
+				"hook at index `%d` (ID = %s) is invalid: %s",
+				idx,
+				hookObj.ID,
+				err,
+			)
+		}
+	}
+
+	me.hooksBySource[source] = hooks
+	me.merged = me.merge()
+
+	return nil
+}
+
+func (me *Store) merge() []*Hook {
+	merged := make([]*Hook, 0)
+
+	for _, source := range storeSourcesOrdered {
+		merged = append(merged, me.hooksBySource[source]...)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Priority > merged[j].Priority
+	})
+
+	return merged
+}
@@ -0,0 +1,124 @@
+package hook
+
+import (
+	"reflect"
+	"testing"
+)
+
+func hookIDs(hooks []*Hook) []string {
+	ids := make([]string, 0, len(hooks))
+	for _, hookObj := range hooks {
+		ids = append(ids, hookObj.ID)
+	}
+	return ids
+}
+
+func createTestStoreHook(id string, priority int) *Hook {
+	return &Hook{
+		ID:        id,
+		EventType: EventTypeBeforeAnyRequest,
+		Action:    ActionPassUnmodified,
+		Priority:  priority,
+	}
+}
+
+func TestStoreMergesHooksByPriority(t *testing.T) {
+	store := NewStore()
+
+	// Sources are deliberately set in an order different than the one they get merged in.
+	err := store.Set(StoreSourceApi, []*Hook{
+		createTestStoreHook("api-1", 0),
+		createTestStoreHook("api-2", 10),
+	})
+	if err != nil {
+		t.Fatalf("failed setting api hooks: %s", err)
+	}
+
+	err = store.Set(StoreSourceFile, []*Hook{
+		createTestStoreHook("file-1", -5),
+		createTestStoreHook("file-2", 0),
+	})
+	if err != nil {
+		t.Fatalf("failed setting file hooks: %s", err)
+	}
+
+	err = store.Set(StoreSourcePolicy, []*Hook{
+		createTestStoreHook("policy-1", 0),
+		createTestStoreHook("policy-2", 10),
+		createTestStoreHook("policy-3", 0),
+	})
+	if err != nil {
+		t.Fatalf("failed setting policy hooks: %s", err)
+	}
+
+	// Higher priorities first. Equal priorities retain the source order (policy, file, api) and the order within each source.
+	expectedIDs := []string{"policy-2", "api-2", "policy-1", "policy-3", "file-2", "api-1", "file-1"}
+	if ids := hookIDs(store.GetMerged()); !reflect.DeepEqual(ids, expectedIDs) {
+		t.Errorf("expected merged hooks %v, got %v", expectedIDs, ids)
+	}
+
+	// Replacing a source's hooks re-merges them.
+	err = store.Set(StoreSourcePolicy, []*Hook{
+		createTestStoreHook("policy-4", 20),
+	})
+	if err != nil {
+		t.Fatalf("failed replacing policy hooks: %s", err)
+	}
+
+	expectedIDs = []string{"policy-4", "api-2", "file-2", "api-1", "file-1"}
+	if ids := hookIDs(store.GetMerged()); !reflect.DeepEqual(ids, expectedIDs) {
+		t.Errorf("expected merged hooks %v, got %v", expectedIDs, ids)
+	}
+}
+
+func TestStoreRejectsDuplicateHookIDs(t *testing.T) {
+	store := NewStore()
+
+	err := store.Set(StoreSourcePolicy, []*Hook{createTestStoreHook("a", 0)})
+	if err != nil {
+		t.Fatalf("failed setting policy hooks: %s", err)
+	}
+
+	err = store.Set(StoreSourceFile, []*Hook{createTestStoreHook("b", 0)})
+	if err != nil {
+		t.Fatalf("failed setting file hooks: %s", err)
+	}
+
+	type testCase struct {
+		source        string
+		hooks         []*Hook
+		expectedError bool
+	}
+
+	testCases := []testCase{
+		// Conflicts with a policy hook
+		{StoreSourceApi, []*Hook{createTestStoreHook("a", 0)}, true},
+		// Conflicts with a file hook
+		{StoreSourceApi, []*Hook{createTestStoreHook("b", 0)}, true},
+		// Conflicts with another hook from the same source
+		{StoreSourceApi, []*Hook{createTestStoreHook("c", 0), createTestStoreHook("c", 0)}, true},
+		// Conflicts with a file hook
+		{StoreSourcePolicy, []*Hook{createTestStoreHook("b", 0)}, true},
+		// Replacing a source's own hooks is fine
+		{StoreSourcePolicy, []*Hook{createTestStoreHook("a", 0)}, false},
+		{StoreSourceApi, []*Hook{createTestStoreHook("c", 0)}, false},
+	}
+
+	for idx, tc := range testCases {
+		err := store.CheckIDsAreUnique(tc.source, tc.hooks)
+		if tc.expectedError != (err != nil) {
+			t.Errorf("test case #%d: expected error = %t, got: %v", idx, tc.expectedError, err)
+		}
+
+		err = store.Set(tc.source, tc.hooks)
+		if tc.expectedError != (err != nil) {
+			t.Errorf("test case #%d: expected error = %t when setting, got: %v", idx, tc.expectedError, err)
+		}
+	}
+
+	// Failed attempts must not have changed anything.
+	expectedIDs := []string{"a", "b", "c"}
+	if ids := hookIDs(store.GetMerged()); !reflect.DeepEqual(ids, expectedIDs) {
+		t.Errorf("expected merged hooks %v, got %v", expectedIDs, ids)
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/httpgateway/hookrunner"
+	"devture-matrix-corporal/corporal/httphelp"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// apiHooksPayload is a request payload for `PUT /_matrix/corporal/hooks` and a response for `GET /_matrix/corporal/hooks`
+type apiHooksPayload struct {
+	Hooks []*hook.Hook `json:"hooks"`
+}
+
+type HookApiHandlerRegistrator struct {
+	hookStore     *hook.Store
+	hookSimulator *hookrunner.Simulator
+	logger        *logrus.Logger
+}
+
+func NewHookApiHandlerRegistrator(
+	hookStore *hook.Store,
+	hookSimulator *hookrunner.Simulator,
+	logger *logrus.Logger,
+) *HookApiHandlerRegistrator {
+	return &HookApiHandlerRegistrator{
+		hookStore:     hookStore,
+		hookSimulator: hookSimulator,
+		logger:        logger,
+	}
+}
+
+func (me *HookApiHandlerRegistrator) RegisterRoutesWithRouter(router *mux.Router) {
+	router.HandleFunc("/_matrix/corporal/hooks", me.actionHooksGet).Methods("GET")
+	router.HandleFunc("/_matrix/corporal/hooks", me.actionHooksPut).Methods("PUT")
+	router.HandleFunc("/_matrix/corporal/hook/simulate", me.actionHookSimulate).Methods("POST")
+}
+
+func (me *HookApiHandlerRegistrator) actionHooksGet(w http.ResponseWriter, r *http.Request) {
+	Respond(w, http.StatusOK, apiHooksPayload{
+		Hooks: me.hookStore.Get(hook.StoreSourceApi),
+	})
+}
+
+func (me *HookApiHandlerRegistrator) actionHooksPut(w http.ResponseWriter, r *http.Request) {
+	var payload apiHooksPayload
+
+	err := httphelp.GetJsonFromRequestBody(r, &payload)
+	if err != nil {
+		Respond(w, http.StatusBadRequest, ApiResponseError{
+			ErrorCode:    ErrorCodeBadJson,
+			ErrorMessage: "Bad body payload",
+		})
+		return
+	}
+
+	if payload.Hooks == nil {
+		payload.Hooks = []*hook.Hook{}
+	}
+
+	err = me.hookStore.Set(hook.StoreSourceApi, payload.Hooks)
+	if err != nil {
+		Respond(w, http.StatusBadRequest, ApiResponseError{
+			ErrorCode:    ErrorCodeUnknown,
+			ErrorMessage: fmt.Sprintf("Failed to set hooks: %s", err),
+		})
+		return
+	}
+
+	Respond(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (me *HookApiHandlerRegistrator) actionHookSimulate(w http.ResponseWriter, r *http.Request) {
+	var payload hookrunner.SimulationRequest
+
+	err := httphelp.GetJsonFromRequestBody(r, &payload)
+	if err != nil {
+		Respond(w, http.StatusBadRequest, ApiResponseError{
+			ErrorCode:    ErrorCodeBadJson,
+			ErrorMessage: "Bad body payload",
+		})
+		return
+	}
+
+	result, err := me.hookSimulator.Simulate(payload, me.logger.WithField("handler", "hook.simulate"))
+	if err != nil {
+		Respond(w, http.StatusBadRequest, ApiResponseError{
+			ErrorCode:    ErrorCodeUnknown,
+			ErrorMessage: fmt.Sprintf("Failed to simulate: %s", err),
+		})
+		return
+	}
+
+	Respond(w, http.StatusOK, result)
+}
+
+// Ensure interface is implemented
+var _ httphelp.HandlerRegistrator = &HookApiHandlerRegistrator{}
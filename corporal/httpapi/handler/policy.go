@@ -33,7 +33,7 @@ func (me *PolicyApiHandlerRegistrator) RegisterRoutesWithRouter(router *mux.Rout
 
 func (me *PolicyApiHandlerRegistrator) actionPolicyGet(w http.ResponseWriter, r *http.Request) {
 	// May be nil
-	policy := me.policyStore.Get()
+	policy := me.policyStore.GetUnresolved()
 
 	Respond(w, http.StatusOK, map[string]interface{}{
 		"policy": policy,
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/roomupgrade"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type RoomApiHandlerRegistrator struct {
+	roomUpgradeTracker *roomupgrade.Tracker
+}
+
+func NewRoomApiHandlerRegistrator(
+	roomUpgradeTracker *roomupgrade.Tracker,
+) *RoomApiHandlerRegistrator {
+	return &RoomApiHandlerRegistrator{
+		roomUpgradeTracker: roomUpgradeTracker,
+	}
+}
+
+func (me *RoomApiHandlerRegistrator) RegisterRoutesWithRouter(router *mux.Router) {
+	router.HandleFunc("/_matrix/corporal/room/upgrades", me.actionRoomUpgradesGet).Methods("GET")
+}
+
+func (me *RoomApiHandlerRegistrator) actionRoomUpgradesGet(w http.ResponseWriter, r *http.Request) {
+	Respond(w, http.StatusOK, map[string]interface{}{
+		"upgrades": me.roomUpgradeTracker.List(),
+	})
+}
+
+// Ensure interface is implemented
+var _ httphelp.HandlerRegistrator = &RoomApiHandlerRegistrator{}
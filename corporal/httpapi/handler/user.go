@@ -27,6 +27,11 @@ type apiAccessTokenReleaseRequestPayload struct {
 	AccessToken string `json:"accessToken"`
 }
 
+// apiDevicesResponse is a response for: GET /_matrix/corporal/user/{userId}/devices
+type apiDevicesResponse struct {
+	Devices []connector.CurrentUserDeviceState `json:"devices"`
+}
+
 type UserApiHandlerRegistrator struct {
 	homeserverDomainName string
 	connector            connector.MatrixConnector
@@ -45,6 +50,8 @@ func NewUserApiHandlerRegistrator(
 func (me *UserApiHandlerRegistrator) RegisterRoutesWithRouter(router *mux.Router) {
 	router.HandleFunc("/_matrix/corporal/user/{userId}/access-token", me.actionAccessTokenRelease).Methods("DELETE")
 	router.HandleFunc("/_matrix/corporal/user/{userId}/access-token/new", me.actionAccessTokenObtain).Methods("POST")
+	router.HandleFunc("/_matrix/corporal/user/{userId}/devices", me.actionDevicesList).Methods("GET")
+	router.HandleFunc("/_matrix/corporal/user/{userId}/devices/{deviceId}", me.actionDeviceRevoke).Methods("DELETE")
 }
 
 func (me *UserApiHandlerRegistrator) actionAccessTokenObtain(w http.ResponseWriter, r *http.Request) {
@@ -148,5 +155,63 @@ func (me *UserApiHandlerRegistrator) actionAccessTokenRelease(w http.ResponseWri
 	Respond(w, http.StatusOK, map[string]interface{}{})
 }
 
+func (me *UserApiHandlerRegistrator) actionDevicesList(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+
+	if !matrix.IsFullUserIdOfDomain(userId, me.homeserverDomainName) {
+		Respond(w, http.StatusBadRequest, ApiResponseError{
+			ErrorCode: ErrorInvalidUsername,
+			ErrorMessage: fmt.Sprintf(
+				"Bad user id (%s) - not part of the homeserver domain (%s)",
+				userId,
+				me.homeserverDomainName,
+			),
+		})
+		return
+	}
+
+	devices, err := me.connector.GetUserDevices(userId)
+	if err != nil {
+		Respond(w, http.StatusOK, ApiResponseError{
+			ErrorCode:    ErrorCodeUnknown,
+			ErrorMessage: fmt.Sprintf("Could not list devices: %s", err),
+		})
+		return
+	}
+
+	Respond(w, http.StatusOK, apiDevicesResponse{
+		Devices: devices,
+	})
+}
+
+func (me *UserApiHandlerRegistrator) actionDeviceRevoke(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+	deviceId := mux.Vars(r)["deviceId"]
+
+	if !matrix.IsFullUserIdOfDomain(userId, me.homeserverDomainName) {
+		Respond(w, http.StatusBadRequest, ApiResponseError{
+			ErrorCode: ErrorInvalidUsername,
+			ErrorMessage: fmt.Sprintf(
+				"Bad user id (%s) - not part of the homeserver domain (%s)",
+				userId,
+				me.homeserverDomainName,
+			),
+		})
+		return
+	}
+
+	// This is idempotent. Deleting a device which does not exist is not an error.
+	err := me.connector.DeleteUserDevices(userId, []string{deviceId})
+	if err != nil {
+		Respond(w, http.StatusOK, ApiResponseError{
+			ErrorCode:    ErrorCodeUnknown,
+			ErrorMessage: fmt.Sprintf("Could not revoke device: %s", err),
+		})
+		return
+	}
+
+	Respond(w, http.StatusOK, map[string]interface{}{})
+}
+
 // Ensure interface is implemented
 var _ httphelp.HandlerRegistrator = &UserApiHandlerRegistrator{}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"devture-matrix-corporal/corporal/connector"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// testDeviceConnector is a connector which only knows about devices.
+// Calling any other method panics.
+type testDeviceConnector struct {
+	connector.MatrixConnector
+
+	devicesByUserId  map[string][]connector.CurrentUserDeviceState
+	deletedDeviceIds map[string][]string
+	err              error
+}
+
+func (me *testDeviceConnector) GetUserDevices(userId string) ([]connector.CurrentUserDeviceState, error) {
+	if me.err != nil {
+		return nil, me.err
+	}
+	return me.devicesByUserId[userId], nil
+}
+
+func (me *testDeviceConnector) DeleteUserDevices(userId string, deviceIds []string) error {
+	if me.err != nil {
+		return me.err
+	}
+	me.deletedDeviceIds[userId] = append(me.deletedDeviceIds[userId], deviceIds...)
+	return nil
+}
+
+func TestUserDevicesApi(t *testing.T) {
+	type testCase struct {
+		name          string
+		method        string
+		path          string
+		connectorFail bool
+
+		expectedStatusCode       int
+		expectedErrorCode        string
+		expectedDeviceIds        []string
+		expectedDeletedDeviceIds map[string][]string
+	}
+
+	testCases := []testCase{
+		{
+			name:               "listing devices",
+			method:             "GET",
+			path:               "/_matrix/corporal/user/@a:host/devices",
+			expectedStatusCode: http.StatusOK,
+			expectedDeviceIds:  []string{"DEVICE1", "DEVICE2"},
+		},
+		{
+			name:               "listing devices of a user without any",
+			method:             "GET",
+			path:               "/_matrix/corporal/user/@b:host/devices",
+			expectedStatusCode: http.StatusOK,
+			expectedDeviceIds:  []string{},
+		},
+		{
+			name:               "listing devices of a foreign user",
+			method:             "GET",
+			path:               "/_matrix/corporal/user/@a:another/devices",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErrorCode:  ErrorInvalidUsername,
+		},
+		{
+			name:               "listing devices fails",
+			method:             "GET",
+			path:               "/_matrix/corporal/user/@a:host/devices",
+			connectorFail:      true,
+			expectedStatusCode: http.StatusOK,
+			expectedErrorCode:  ErrorCodeUnknown,
+		},
+		{
+			name:                     "revoking a device",
+			method:                   "DELETE",
+			path:                     "/_matrix/corporal/user/@a:host/devices/DEVICE2",
+			expectedStatusCode:       http.StatusOK,
+			expectedDeletedDeviceIds: map[string][]string{"@a:host": {"DEVICE2"}},
+		},
+		{
+			name:                     "revoking a device of a foreign user",
+			method:                   "DELETE",
+			path:                     "/_matrix/corporal/user/@a:another/devices/DEVICE2",
+			expectedStatusCode:       http.StatusBadRequest,
+			expectedErrorCode:        ErrorInvalidUsername,
+			expectedDeletedDeviceIds: map[string][]string{},
+		},
+		{
+			name:                     "revoking a device fails",
+			method:                   "DELETE",
+			path:                     "/_matrix/corporal/user/@a:host/devices/DEVICE2",
+			connectorFail:            true,
+			expectedStatusCode:       http.StatusOK,
+			expectedErrorCode:        ErrorCodeUnknown,
+			expectedDeletedDeviceIds: map[string][]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deviceConnector := &testDeviceConnector{
+				devicesByUserId: map[string][]connector.CurrentUserDeviceState{
+					"@a:host": {
+						{DeviceId: "DEVICE1", DisplayName: "Element Web", LastSeenTs: 1000},
+						{DeviceId: "DEVICE2", DisplayName: "Element Android"},
+					},
+				},
+				deletedDeviceIds: map[string][]string{},
+			}
+			if tc.connectorFail {
+				deviceConnector.err = fmt.Errorf("homeserver unavailable")
+			}
+
+			router := mux.NewRouter()
+			NewUserApiHandlerRegistrator("host", deviceConnector).RegisterRoutesWithRouter(router)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if recorder.Code != tc.expectedStatusCode {
+				t.Fatalf("expected status code %d, got %d", tc.expectedStatusCode, recorder.Code)
+			}
+
+			var payload struct {
+				ErrorCode string                             `json:"errcode"`
+				Devices   []connector.CurrentUserDeviceState `json:"devices"`
+			}
+			err := json.Unmarshal(recorder.Body.Bytes(), &payload)
+			if err != nil {
+				t.Fatalf("failed parsing response: %s", err)
+			}
+
+			if payload.ErrorCode != tc.expectedErrorCode {
+				t.Errorf("expected error code `%s`, got `%s`", tc.expectedErrorCode, payload.ErrorCode)
+			}
+
+			if tc.expectedDeviceIds != nil {
+				deviceIds := make([]string, 0, len(payload.Devices))
+				for _, device := range payload.Devices {
+					deviceIds = append(deviceIds, device.DeviceId)
+				}
+
+				if !reflect.DeepEqual(deviceIds, tc.expectedDeviceIds) {
+					t.Errorf("expected devices %v, got %v", tc.expectedDeviceIds, deviceIds)
+				}
+			}
+
+			if tc.expectedDeletedDeviceIds != nil && !reflect.DeepEqual(deviceConnector.deletedDeviceIds, tc.expectedDeletedDeviceIds) {
+				t.Errorf("expected deleted devices %v, got %v", tc.expectedDeletedDeviceIds, deviceConnector.deletedDeviceIds)
+			}
+		})
+	}
+}
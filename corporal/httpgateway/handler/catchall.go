@@ -38,6 +38,11 @@ func (me *catchAllHandler) RegisterRoutesWithRouter(router *mux.Router) {
 	router.PathPrefix("/").HandlerFunc(me.actionCatchAll)
 }
 
+// GetSimulationRouteType implements hookrunner.SimulatableHandlerRegistrator
+func (me *catchAllHandler) GetSimulationRouteType() string {
+	return hookrunner.SimulationRouteTypeCatchAll
+}
+
 func (me *catchAllHandler) actionCatchAll(w http.ResponseWriter, r *http.Request) {
 	logger := me.logger.WithField("method", r.Method)
 	logger = logger.WithField("uri", r.RequestURI)
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -107,7 +108,7 @@ func (me *internalRestAuthHandler) actionCheckCredentials(w http.ResponseWriter,
 		return
 	}
 
-	if !userPolicy.Active {
+	if !userPolicy.IsActiveAt(time.Now()) {
 		logger.Debug("Refusing to authenticate deactivated user")
 		httphelp.RespondWithJSON(w, http.StatusOK, userauth.NewUnsuccessfulRestAuthResponse())
 		return
@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"devture-matrix-corporal/corporal/hook"
 	"devture-matrix-corporal/corporal/httpgateway/hookrunner"
 	"devture-matrix-corporal/corporal/httpgateway/interceptor"
 	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/matrix"
+	"io"
 	"net/http"
 	"net/http/httputil"
 
@@ -48,6 +52,11 @@ func (me *loginHandler) RegisterRoutesWithRouter(router *mux.Router) {
 	).Methods("POST")
 }
 
+// GetSimulationRouteType implements hookrunner.SimulatableHandlerRegistrator
+func (me *loginHandler) GetSimulationRouteType() string {
+	return hookrunner.SimulationRouteTypeLogin
+}
+
 func (me *loginHandler) createInterceptorHandler(name string, interceptorObj interceptor.Interceptor) http.HandlerFunc {
 	hooksToRun := []string{
 		hook.EventTypeBeforeAnyRequest,
@@ -76,6 +85,11 @@ func (me *loginHandler) createInterceptorHandler(name string, interceptorObj int
 
 		logger = logger.WithFields(interceptorResult.LoggingContextFields)
 
+		// This will be read by login-related hooks (and sent to REST services they may consult).
+		loginInformation := createLoginInformation(interceptorResult)
+		// We don't care that this fails the SA1029 static check
+		r = r.WithContext(context.WithValue(r.Context(), "loginInformation", loginInformation)) //nolint:staticcheck
+
 		if interceptorResult.Result == interceptor.InterceptorResultDeny {
 			logger.Infof(
 				"HTTP gateway (intercepted): denying (%s: %s)",
@@ -83,29 +97,63 @@ func (me *loginHandler) createInterceptorHandler(name string, interceptorObj int
 				interceptorResult.ErrorMessage,
 			)
 
+			// We prepare the response ourselves (instead of sending it right away),
+			// so that the `after*` hooks scheduled above and EventTypeAfterLoginFailure hooks get a chance to work with it.
+			response := &http.Response{
+				StatusCode: http.StatusForbidden,
+				Header:     http.Header{},
+				Body:       io.NopCloser(bytes.NewReader([]byte{})),
+			}
+
+			responseBoundWriter := httphelp.NewResponseBoundHttpWriter(response)
 			httphelp.RespondWithMatrixError(
-				w,
+				responseBoundWriter,
 				http.StatusForbidden,
 				interceptorResult.ErrorCode,
 				interceptorResult.ErrorMessage,
 			)
+			responseBoundWriter.Commit()
+
+			err := me.createLoginResponseModifier(r, loginInformation, httpResponseModifierFuncs, nil, logger)(response)
+			if err != nil {
+				logger.Errorf("HTTP gateway (intercepted): login outcome hooks failed: %s", err)
+
+				httphelp.RespondWithMatrixError(
+					w,
+					http.StatusServiceUnavailable,
+					matrix.ErrorUnknown,
+					"Hook execution failed, cannot proceed",
+				)
+				return
+			}
+
+			writeResponse(w, response, logger)
 
 			return
 		}
 
 		if interceptorResult.Result == interceptor.InterceptorResultProxy {
-			reverseProxyToUse := me.reverseProxy
-
-			if len(httpResponseModifierFuncs) == 0 {
-				logger.Debugf("HTTP gateway (intercepted): proxying")
-			} else {
-				logger.Debugf("HTTP gateway (intercepted): proxying (with response modification)")
+			if !runHooks(me.hookRunner, hook.EventTypeBeforeLogin, w, r, logger, &httpResponseModifierFuncs) {
+				return
+			}
 
-				reverseProxyCopy := *reverseProxyToUse
-				reverseProxyCopy.ModifyResponse = hook.CreateChainedHttpResponseModifierFunc(httpResponseModifierFuncs)
-				reverseProxyToUse = &reverseProxyCopy
+			if interceptorResult.OnLoginSuccess != nil {
+				// Post-login work may need to read the response, so we'd like it to be plain JSON, not whatever compressed version the homeserver may decide to send.
+				// If we don't ask for a specific encoding, the reverse-proxy's transport transparently handles compression for us.
+				r.Header.Del("Accept-Encoding")
 			}
 
+			reverseProxyToUse := *me.reverseProxy
+			reverseProxyToUse.ModifyResponse = me.createLoginResponseModifier(
+				r,
+				loginInformation,
+				httpResponseModifierFuncs,
+				interceptorResult.OnLoginSuccess,
+				logger,
+			)
+
+			logger.Debugf("HTTP gateway (intercepted): proxying (with response modification)")
+
 			reverseProxyToUse.ServeHTTP(w, r)
 
 			return
@@ -115,5 +163,95 @@ func (me *loginHandler) createInterceptorHandler(name string, interceptorObj int
 	}
 }
 
+// createLoginResponseModifier returns a response modifier which runs the given (scheduled) response modifiers
+// and then determines the outcome of the login (see createLoginOutcomeResponseModifier).
+//
+// The login outcome decides which login hooks run, so it needs to be determined after all other response modifiers had run.
+// It's not part of their chain though, so it's determined even if some modifier asks for the next ones to be skipped.
+func (me *loginHandler) createLoginResponseModifier(
+	r *http.Request,
+	loginInformation *hook.LoginInformation,
+	httpResponseModifierFuncs []hook.HttpResponseModifierFunc,
+	onLoginSuccess func(loginResponse *http.Response) error,
+	logger *logrus.Entry,
+) func(*http.Response) error {
+	chainedModifier := hook.CreateChainedHttpResponseModifierFunc(httpResponseModifierFuncs)
+	loginOutcomeModifier := me.createLoginOutcomeResponseModifier(r, loginInformation, onLoginSuccess, logger)
+
+	return func(response *http.Response) error {
+		err := chainedModifier(response)
+		if err != nil {
+			return err
+		}
+
+		_, err = loginOutcomeModifier(response)
+		return err
+	}
+}
+
+// createLoginOutcomeResponseModifier returns a response modifier which determines the outcome of a login request (based on the response)
+// and runs the EventTypeAfterLoginSuccess or EventTypeAfterLoginFailure hooks accordingly.
+//
+// onLoginSuccess (if not nil) gets called for successful login responses, before the outcome is determined.
+// It may turn the response into an error one, in which case the login is considered failed.
+func (me *loginHandler) createLoginOutcomeResponseModifier(
+	r *http.Request,
+	loginInformation *hook.LoginInformation,
+	onLoginSuccess func(loginResponse *http.Response) error,
+	logger *logrus.Entry,
+) hook.HttpResponseModifierFunc {
+	return func(response *http.Response) ( /* skipNextModifiers */ bool, error) {
+		if response.StatusCode == http.StatusOK && onLoginSuccess != nil {
+			err := onLoginSuccess(response)
+			if err != nil {
+				// Any denial is already reflected in the response, so there's nothing more to do here.
+				// Whatever didn't get done (e.g. logging out devices) will be taken care of by reconciliation.
+				logger.Warnf("HTTP gateway (intercepted): post-login work failed: %s", err)
+			}
+		}
+
+		eventType := hook.RecordLoginOutcome(loginInformation, response)
+
+		logger = logger.WithField("loginOutcome", loginInformation.Outcome)
+
+		// `after*` hooks merely get scheduled (as response modifiers) by the hook runner.
+		// The hook runner only writes something on its own (to this response-bound writer) if it fails.
+		responseBoundWriter := httphelp.NewResponseBoundHttpWriter(response)
+		defer responseBoundWriter.Commit()
+
+		hookResult := me.hookRunner.RunAllMatchingType(eventType, responseBoundWriter, r, logger)
+		if hookResult.ResponseSent {
+			return true, nil
+		}
+
+		return false, hook.CreateChainedHttpResponseModifierFunc(hookResult.ReverseProxyResponseModifiers)(response)
+	}
+}
+
+func createLoginInformation(interceptorResult interceptor.InterceptorResponse) *hook.LoginInformation {
+	loginInformation := &hook.LoginInformation{
+		LoginType: interceptorResult.LoginType,
+	}
+
+	if interceptorResult.UserId != "" {
+		userId := interceptorResult.UserId
+		loginInformation.UserId = &userId
+	}
+
+	if interceptorResult.AuthType != "" {
+		authType := interceptorResult.AuthType
+		loginInformation.AuthType = &authType
+	}
+
+	if interceptorResult.Result == interceptor.InterceptorResultDeny {
+		errorCode := interceptorResult.ErrorCode
+		errorMessage := interceptorResult.ErrorMessage
+		loginInformation.FailureErrorCode = &errorCode
+		loginInformation.FailureErrorMessage = &errorMessage
+	}
+
+	return loginInformation
+}
+
 // Ensure interface is implemented
 var _ httphelp.HandlerRegistrator = &loginHandler{}
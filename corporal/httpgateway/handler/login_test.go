@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/httpgateway/hookrunner"
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/policy"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testLoginHooksRESTService is a REST service which records the login information it gets consulted with (by hook ID)
+type testLoginHooksRESTService struct {
+	loginInformationByHookId map[string]hook.LoginInformation
+	lock                     sync.Mutex
+}
+
+func (me *testLoginHooksRESTService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Meta struct {
+			HookID string `json:"hookId"`
+		} `json:"meta"`
+		Login *hook.LoginInformation `json:"login"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.Login == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	me.lock.Lock()
+	me.loginInformationByHookId[payload.Meta.HookID] = *payload.Login
+	me.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"action": "pass.unmodified"}`))
+}
+
+func TestLoginOutcomeResponseModifier(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	restService := &testLoginHooksRESTService{}
+	server := httptest.NewServer(restService)
+	defer server.Close()
+
+	hooksJson := fmt.Sprintf(`[
+		{"id": "notify-success", "eventType": "afterLoginSuccess", "action": "consult.RESTServiceURL", "RESTServiceURL": "%s"},
+		{"id": "notify-failure", "eventType": "afterLoginFailure", "action": "consult.RESTServiceURL", "RESTServiceURL": "%s"},
+		{"id": "explain-failure", "eventType": "afterLoginFailure", "action": "pass.modifiedResponse", "injectJSONIntoResponse": {"hint": "Contact support"}}
+	]`, server.URL, server.URL)
+
+	var hooks []*hook.Hook
+	err := json.Unmarshal([]byte(hooksJson), &hooks)
+	if err != nil {
+		t.Fatalf("failed parsing hooks: %s", err)
+	}
+
+	hookStore := hook.NewStore()
+	roomAliasResolver := policy.NewRoomAliasResolver(logger, nil, time.Minute)
+	policyStore := policy.NewStore(
+		logger,
+		policy.NewValidator("host", hookStore, roomAliasResolver),
+		roomAliasResolver,
+		hookStore,
+	)
+	err = policyStore.Set(&policy.Policy{SchemaVersion: 2, Hooks: hooks})
+	if err != nil {
+		t.Fatalf("failed setting policy: %s", err)
+	}
+
+	handler := NewLoginHandler(
+		nil,
+		hookrunner.NewHookRunner(policyStore, hookStore, hook.NewExecutor(hook.NewRESTServiceConsultor(5*time.Second))),
+		nil,
+		logger,
+	)
+
+	userId := "@a:host"
+	errorCode := "M_FORBIDDEN"
+	errorMessage := "Invalid password"
+	deviceLimitErrorMessage := "Device limit reached"
+
+	type testCase struct {
+		name                 string
+		responseStatusCode   int
+		responseBody         string
+		onLoginSuccessFailed bool
+		onLoginSuccessDenies bool
+		skippingModifier     bool
+
+		expectedResponseStatusCode   int
+		expectedNotifiedHookId       string
+		expectedLoginInformation     hook.LoginInformation
+		expectedResponseBody         map[string]interface{}
+		expectedOnLoginSuccessCalled bool
+	}
+
+	testCases := []testCase{
+		{
+			name:                       "success",
+			responseStatusCode:         http.StatusOK,
+			responseBody:               `{"user_id": "@a:host"}`,
+			expectedResponseStatusCode: http.StatusOK,
+			expectedNotifiedHookId:     "notify-success",
+			expectedLoginInformation: hook.LoginInformation{
+				UserId:    &userId,
+				LoginType: "m.login.password",
+				Outcome:   hook.LoginOutcomeSuccess,
+			},
+			expectedResponseBody:         map[string]interface{}{"user_id": "@a:host"},
+			expectedOnLoginSuccessCalled: true,
+		},
+		{
+			name:                       "success, even if post-login work fails",
+			responseStatusCode:         http.StatusOK,
+			responseBody:               `{"user_id": "@a:host"}`,
+			onLoginSuccessFailed:       true,
+			expectedResponseStatusCode: http.StatusOK,
+			expectedNotifiedHookId:     "notify-success",
+			expectedLoginInformation: hook.LoginInformation{
+				UserId:    &userId,
+				LoginType: "m.login.password",
+				Outcome:   hook.LoginOutcomeSuccess,
+			},
+			expectedResponseBody:         map[string]interface{}{"user_id": "@a:host"},
+			expectedOnLoginSuccessCalled: true,
+		},
+		{
+			name:                       "success, even if an earlier response modifier skips the next ones",
+			responseStatusCode:         http.StatusOK,
+			responseBody:               `{"user_id": "@a:host"}`,
+			skippingModifier:           true,
+			expectedResponseStatusCode: http.StatusOK,
+			expectedNotifiedHookId:     "notify-success",
+			expectedLoginInformation: hook.LoginInformation{
+				UserId:    &userId,
+				LoginType: "m.login.password",
+				Outcome:   hook.LoginOutcomeSuccess,
+			},
+			expectedResponseBody:         map[string]interface{}{"user_id": "@a:host"},
+			expectedOnLoginSuccessCalled: true,
+		},
+		{
+			name:                       "failure",
+			responseStatusCode:         http.StatusForbidden,
+			responseBody:               `{"errcode": "M_FORBIDDEN", "error": "Invalid password"}`,
+			expectedResponseStatusCode: http.StatusForbidden,
+			expectedNotifiedHookId:     "notify-failure",
+			expectedLoginInformation: hook.LoginInformation{
+				UserId:              &userId,
+				LoginType:           "m.login.password",
+				Outcome:             hook.LoginOutcomeFailure,
+				FailureErrorCode:    &errorCode,
+				FailureErrorMessage: &errorMessage,
+			},
+			expectedResponseBody: map[string]interface{}{
+				"errcode": "M_FORBIDDEN",
+				"error":   "Invalid password",
+				"hint":    "Contact support",
+			},
+		},
+		{
+			name:                       "failure, if post-login work denies the login",
+			responseStatusCode:         http.StatusOK,
+			responseBody:               `{"user_id": "@a:host"}`,
+			onLoginSuccessDenies:       true,
+			expectedResponseStatusCode: http.StatusForbidden,
+			expectedNotifiedHookId:     "notify-failure",
+			expectedLoginInformation: hook.LoginInformation{
+				UserId:              &userId,
+				LoginType:           "m.login.password",
+				Outcome:             hook.LoginOutcomeFailure,
+				FailureErrorCode:    &errorCode,
+				FailureErrorMessage: &deviceLimitErrorMessage,
+			},
+			expectedResponseBody: map[string]interface{}{
+				"errcode": "M_FORBIDDEN",
+				"error":   "Device limit reached",
+				"hint":    "Contact support",
+			},
+			expectedOnLoginSuccessCalled: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			restService.loginInformationByHookId = map[string]hook.LoginInformation{}
+
+			loginUserId := userId
+			loginInformation := &hook.LoginInformation{
+				UserId:    &loginUserId,
+				LoginType: "m.login.password",
+			}
+
+			request := httptest.NewRequest("POST", "/_matrix/client/v3/login", bytes.NewReader([]byte(`{}`)))
+			// We don't care that this fails the SA1029 static check
+			request = request.WithContext(context.WithValue(request.Context(), "loginInformation", loginInformation)) //nolint:staticcheck
+
+			onLoginSuccessCalled := false
+			onLoginSuccess := func(loginResponse *http.Response) error {
+				onLoginSuccessCalled = true
+				if tc.onLoginSuccessFailed {
+					return fmt.Errorf("failed evicting devices")
+				}
+				if tc.onLoginSuccessDenies {
+					responseBoundWriter := httphelp.NewResponseBoundHttpWriter(loginResponse)
+					httphelp.RespondWithMatrixError(responseBoundWriter, http.StatusForbidden, errorCode, deviceLimitErrorMessage)
+					responseBoundWriter.Commit()
+				}
+				return nil
+			}
+
+			response := &http.Response{
+				StatusCode: tc.responseStatusCode,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(bytes.NewReader([]byte(tc.responseBody))),
+			}
+
+			httpResponseModifierFuncs := []hook.HttpResponseModifierFunc{}
+			if tc.skippingModifier {
+				// This is what `after*` hooks having `skipNextHooksInChain` do
+				httpResponseModifierFuncs = append(httpResponseModifierFuncs, func(response *http.Response) (bool, error) {
+					return true, nil
+				})
+			}
+
+			modifier := handler.createLoginResponseModifier(request, loginInformation, httpResponseModifierFuncs, onLoginSuccess, logrus.NewEntry(logger))
+
+			err := modifier(response)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if response.StatusCode != tc.expectedResponseStatusCode {
+				t.Errorf("expected status code %d, got %d", tc.expectedResponseStatusCode, response.StatusCode)
+			}
+
+			if onLoginSuccessCalled != tc.expectedOnLoginSuccessCalled {
+				t.Errorf("expected onLoginSuccess called = %t, got %t", tc.expectedOnLoginSuccessCalled, onLoginSuccessCalled)
+			}
+
+			if len(restService.loginInformationByHookId) != 1 {
+				t.Fatalf("expected exactly 1 REST service notification, got: %v", restService.loginInformationByHookId)
+			}
+
+			notifiedLoginInformation, exists := restService.loginInformationByHookId[tc.expectedNotifiedHookId]
+			if !exists {
+				t.Fatalf("expected hook %s to notify the REST service, got: %v", tc.expectedNotifiedHookId, restService.loginInformationByHookId)
+			}
+
+			if !reflect.DeepEqual(notifiedLoginInformation, tc.expectedLoginInformation) {
+				t.Errorf("expected login information %#v, got %#v", tc.expectedLoginInformation, notifiedLoginInformation)
+			}
+
+			var responseBody map[string]interface{}
+			err = httphelp.GetJsonFromResponseBody(response, &responseBody)
+			if err != nil {
+				t.Fatalf("failed parsing response body: %s", err)
+			}
+
+			if !reflect.DeepEqual(responseBody, tc.expectedResponseBody) {
+				t.Errorf("expected response body %v, got %v", tc.expectedResponseBody, responseBody)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"devture-matrix-corporal/corporal/httphelp"
 	"devture-matrix-corporal/corporal/matrix"
 	"devture-matrix-corporal/corporal/policy"
+	"devture-matrix-corporal/corporal/roomupgrade"
 	"net/http"
 	"net/http/httputil"
 
@@ -15,12 +16,17 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// responseObserverFactoryFunc creates a response modifier, which observes the upstream's response to an allowed policy-checked request.
+// It may return nil, if there's nothing to observe.
+type responseObserverFactoryFunc func(r *http.Request, policy policy.Policy, logger *logrus.Entry) hook.HttpResponseModifierFunc
+
 type policyCheckedRoutesHandler struct {
 	reverseProxy        *httputil.ReverseProxy
 	policyStore         *policy.Store
 	policyChecker       *policy.Checker
 	hookRunner          *hookrunner.HookRunner
 	userMappingResolver *matrix.UserMappingResolver
+	roomUpgradeTracker  *roomupgrade.Tracker
 	logger              *logrus.Logger
 }
 
@@ -30,6 +36,7 @@ func NewPolicyCheckedRoutesHandler(
 	policyChecker *policy.Checker,
 	hookRunner *hookrunner.HookRunner,
 	userMappingResolver *matrix.UserMappingResolver,
+	roomUpgradeTracker *roomupgrade.Tracker,
 	logger *logrus.Logger,
 ) *policyCheckedRoutesHandler {
 	return &policyCheckedRoutesHandler{
@@ -38,6 +45,7 @@ func NewPolicyCheckedRoutesHandler(
 		policyChecker:       policyChecker,
 		hookRunner:          hookRunner,
 		userMappingResolver: userMappingResolver,
+		roomUpgradeTracker:  roomUpgradeTracker,
 		logger:              logger,
 	}
 }
@@ -89,6 +97,54 @@ func (me *policyCheckedRoutesHandler) RegisterRoutesWithRouter(router *mux.Route
 		me.createPolicyCheckingHandler("room.subsequenly_enabling_encryption", policycheck.CheckRoomEncryptionStateChange, false),
 	).Methods("PUT")
 
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/state/m.room.power_levels{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("room.set_power_levels", policycheck.CheckRoomPowerLevelsStateChange, false),
+	).Methods("PUT")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/join/{roomIdOrAlias}{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("room.join", policycheck.CheckRoomJoin, false),
+	).Methods("POST")
+
+	// Another way to join a room is to use the room-specific join endpoint (only works with room ids).
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/join{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("room.join", policycheck.CheckRoomJoin, false),
+	).Methods("POST")
+
+	// Knocking is a request to join, so it's subject to the same restrictions.
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/knock/{roomIdOrAlias}{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("room.knock", policycheck.CheckRoomJoin, false),
+	).Methods("POST")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/invite{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("room.invite", policycheck.CheckRoomInvite, false),
+	).Methods("POST")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/upgrade{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandlerWithResponseObserver(
+			"room.upgrade",
+			policycheck.CheckRoomUpgrade,
+			false,
+			me.createRoomUpgradeResponseObserver,
+		),
+	).Methods("POST")
+
+	// Another way to replace a room is by sending a tombstone event manually.
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/state/m.room.tombstone{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandlerWithResponseObserver(
+			"room.tombstone",
+			policycheck.CheckRoomTombstoneStateChange,
+			false,
+			me.createRoomTombstoneResponseObserver,
+		),
+	).Methods("PUT")
+
 	router.HandleFunc(
 		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/createRoom{optionalTrailingSlash:[/]?}`,
 		me.createPolicyCheckingHandler("room.create", policycheck.CheckRoomCreate, false),
@@ -99,6 +155,16 @@ func (me *policyCheckedRoutesHandler) RegisterRoutesWithRouter(router *mux.Route
 		me.createPolicyCheckingHandler("room.send_event", policycheck.CheckRoomSendEvent, false),
 	).Methods("PUT")
 
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/directory/list/room/{roomId}{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("directory.room_visibility.set", policycheck.CheckDirectoryRoomVisibilityChange, false),
+	).Methods("PUT")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/directory/room/{roomAlias}{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("directory.alias.create", policycheck.CheckDirectoryAliasCreate, false),
+	).Methods("PUT")
+
 	router.HandleFunc(
 		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/profile/{targetUserId}/displayname{optionalTrailingSlash:[/]?}`,
 		me.createPolicyCheckingHandler("user.set_display_name", policycheck.CheckProfileSetDisplayName, false),
@@ -114,6 +180,51 @@ func (me *policyCheckedRoutesHandler) RegisterRoutesWithRouter(router *mux.Route
 		me.createPolicyCheckingHandler("user.deactivate", policycheck.CheckUserDeactivate, false),
 	).Methods("POST")
 
+	// Adding a 3pid (email address, phone number) starts with requesting a validation token
+	// and finishes with submitting it, so we check both steps.
+	// Binding/unbinding 3pids (on an identity server) does not add or remove them from the account, so it's not checked.
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/account/3pid/{medium:(?:email|msisdn)}/requestToken{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("user.3pid.request_token", policycheck.CheckUser3pidChange, false),
+	).Methods("POST")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/account/3pid{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("user.3pid.add", policycheck.CheckUser3pidChange, false),
+	).Methods("POST")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/account/3pid/add{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("user.3pid.add", policycheck.CheckUser3pidChange, false),
+	).Methods("POST")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/account/3pid/delete{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("user.3pid.delete", policycheck.CheckUser3pidChange, false),
+	).Methods("POST")
+
+	// Push rules enforced by the policy cannot be replaced, deleted, disabled or have their actions changed.
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/pushrules/global/{kind}/{ruleId}{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("user.push_rules.set", policycheck.CheckUserPushRuleChange, false),
+	).Methods("PUT", "DELETE")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/pushrules/global/{kind}/{ruleId}/{attribute:(?:actions|enabled)}{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("user.push_rules.set", policycheck.CheckUserPushRuleChange, false),
+	).Methods("PUT")
+
+	router.HandleFunc(
+		`/_matrix/media/{apiVersion:(?:r0|v\d+)}/upload{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("media.upload", policycheck.CheckMediaUpload, false),
+	).Methods("POST")
+
+	// Media can also be uploaded asynchronously, to a media id created beforehand.
+	router.HandleFunc(
+		`/_matrix/media/{apiVersion:(?:r0|v\d+)}/upload/{serverName}/{mediaId}{optionalTrailingSlash:[/]?}`,
+		me.createPolicyCheckingHandler("media.upload", policycheck.CheckMediaUpload, false),
+	).Methods("PUT")
+
 	// This Client-Server API is used for 2 things:
 	// - setting new passwords for authenticated users (requests having an access token)
 	// - a "forgotten password" flow for unauthenticated users (they authenticate by verifying some 3pid)
@@ -125,10 +236,26 @@ func (me *policyCheckedRoutesHandler) RegisterRoutesWithRouter(router *mux.Route
 	).Methods("POST")
 }
 
+// GetSimulationRouteType implements hookrunner.SimulatableHandlerRegistrator
+func (me *policyCheckedRoutesHandler) GetSimulationRouteType() string {
+	return hookrunner.SimulationRouteTypePolicyChecked
+}
+
 func (me *policyCheckedRoutesHandler) createPolicyCheckingHandler(
 	name string,
 	policyCheckingCallback policycheck.PolicyCheckFunc,
 	allowUnauthenticatedAccess bool,
+) http.HandlerFunc {
+	return me.createPolicyCheckingHandlerWithResponseObserver(name, policyCheckingCallback, allowUnauthenticatedAccess, nil)
+}
+
+// createPolicyCheckingHandlerWithResponseObserver is like createPolicyCheckingHandler,
+// but also lets the upstream's response be observed (if the request is allowed to go through).
+func (me *policyCheckedRoutesHandler) createPolicyCheckingHandlerWithResponseObserver(
+	name string,
+	policyCheckingCallback policycheck.PolicyCheckFunc,
+	allowUnauthenticatedAccess bool,
+	responseObserverFactory responseObserverFactoryFunc,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := me.logger.WithField("method", r.Method)
@@ -217,15 +344,28 @@ func (me *policyCheckedRoutesHandler) createPolicyCheckingHandler(
 				policyResponse.ErrorMessage,
 			)
 
+			httpStatusCode := http.StatusForbidden
+			if policyResponse.ErrorCode == matrix.ErrorTooLarge {
+				httpStatusCode = http.StatusRequestEntityTooLarge
+			}
+
 			httphelp.RespondWithMatrixError(
 				w,
-				http.StatusForbidden,
+				httpStatusCode,
 				policyResponse.ErrorCode,
 				policyResponse.ErrorMessage,
 			)
 			return
 		}
 
+		if responseObserverFactory != nil {
+			responseObserver := responseObserverFactory(r, *policy, logger)
+			if responseObserver != nil {
+				// Observers need to see the upstream response as it is, before `after*` hooks get a chance to modify (or replace) it.
+				httpResponseModifierFuncs = append(httpResponseModifierFuncs, responseObserver)
+			}
+		}
+
 		if !runHooks(me.hookRunner, hook.EventTypeAfterAnyRequest, w, r, logger, &httpResponseModifierFuncs) {
 			return
 		}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/policy"
+	"devture-matrix-corporal/corporal/roomupgrade"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// createRoomUpgradeResponseObserver creates a response observer for `/rooms/{roomId}/upgrade` requests,
+// which records successful upgrades of managed rooms.
+func (me *policyCheckedRoutesHandler) createRoomUpgradeResponseObserver(r *http.Request, policy policy.Policy, logger *logrus.Entry) hook.HttpResponseModifierFunc {
+	roomId := mux.Vars(r)["roomId"]
+	if !me.policyChecker.IsRoomManaged(policy, roomId) {
+		return nil
+	}
+
+	userId := r.Context().Value("userId").(string)
+
+	return func(response *http.Response) ( /* skipNextModifiers */ bool, error) {
+		if response.StatusCode != http.StatusOK {
+			return false, nil
+		}
+
+		var payload struct {
+			ReplacementRoom string `json:"replacement_room"`
+		}
+		err := httphelp.GetJsonFromResponseBody(response, &payload)
+		if err != nil || payload.ReplacementRoom == "" {
+			// The upgrade happened, but we can't tell what the new room is.
+			// This is no reason to fail the request though.
+			logger.Warnf("Failed determining the replacement room of upgraded managed room %s", roomId)
+			return false, nil
+		}
+
+		me.roomUpgradeTracker.Record(roomupgrade.Upgrade{
+			RoomId:            roomId,
+			ReplacementRoomId: payload.ReplacementRoom,
+			UserId:            userId,
+			UpgradedAt:        time.Now(),
+		})
+
+		return false, nil
+	}
+}
+
+// createRoomTombstoneResponseObserver creates a response observer for `/rooms/{roomId}/state/m.room.tombstone` requests,
+// which records managed rooms being replaced by sending a tombstone event manually.
+func (me *policyCheckedRoutesHandler) createRoomTombstoneResponseObserver(r *http.Request, policy policy.Policy, logger *logrus.Entry) hook.HttpResponseModifierFunc {
+	roomId := mux.Vars(r)["roomId"]
+	if !me.policyChecker.IsRoomManaged(policy, roomId) {
+		return nil
+	}
+
+	userId := r.Context().Value("userId").(string)
+
+	var payload struct {
+		ReplacementRoom string `json:"replacement_room"`
+	}
+	err := httphelp.GetJsonFromRequestBody(r, &payload)
+	if err != nil || payload.ReplacementRoom == "" {
+		// Not a valid tombstone event. The upstream will likely reject it anyway.
+		return nil
+	}
+
+	return func(response *http.Response) ( /* skipNextModifiers */ bool, error) {
+		if response.StatusCode != http.StatusOK {
+			return false, nil
+		}
+
+		me.roomUpgradeTracker.Record(roomupgrade.Upgrade{
+			RoomId:            roomId,
+			ReplacementRoomId: payload.ReplacementRoom,
+			UserId:            userId,
+			UpgradedAt:        time.Now(),
+		})
+
+		return false, nil
+	}
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"bytes"
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/httpgateway/hookrunner"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/policy"
+	"devture-matrix-corporal/corporal/userauth"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func TestPolicyCheckedRoutesJoinRestrictions(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	// The homeserver knows the access token of a single user and accepts everything else
+	homeserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/account/whoami") {
+			_, _ = w.Write([]byte(`{"user_id": "@a:host"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer homeserver.Close()
+
+	homeserverURL, err := url.Parse(homeserver.URL)
+	if err != nil {
+		t.Fatalf("failed parsing homeserver URL: %s", err)
+	}
+
+	hookStore := hook.NewStore()
+	roomAliasResolver := policy.NewRoomAliasResolver(logger, nil, time.Minute)
+	policyStore := policy.NewStore(
+		logger,
+		policy.NewValidator("host", hookStore, roomAliasResolver),
+		roomAliasResolver,
+		hookStore,
+	)
+	err = policyStore.Set(&policy.Policy{
+		SchemaVersion: 2,
+		Flags: policy.PolicyFlags{
+			JoinAllowedDomains: []string{"host"},
+		},
+		User: []*policy.UserPolicy{
+			{
+				Id:       "@a:host",
+				Active:   true,
+				AuthType: userauth.UserAuthTypePassthrough,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed setting policy: %s", err)
+	}
+
+	cache, err := lru.New2Q[string, matrix.AccessTokenResolvingResult](10)
+	if err != nil {
+		t.Fatalf("failed creating cache: %s", err)
+	}
+
+	router := mux.NewRouter()
+	NewPolicyCheckedRoutesHandler(
+		httputil.NewSingleHostReverseProxy(homeserverURL),
+		policyStore,
+		policy.NewChecker(),
+		hookrunner.NewHookRunner(policyStore, hookStore, hook.NewExecutor(hook.NewRESTServiceConsultor(5*time.Second))),
+		matrix.NewUserMappingResolver(logger, homeserver.URL, cache, 60000),
+		nil,
+		logger,
+	).RegisterRoutesWithRouter(router)
+
+	type testCase struct {
+		name               string
+		method             string
+		path               string
+		body               string
+		expectedStatusCode int
+	}
+
+	testCases := []testCase{
+		{
+			name:               "joining a local room",
+			method:             "POST",
+			path:               "/_matrix/client/v3/join/!room:host",
+			body:               `{}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "joining a remote room",
+			method:             "POST",
+			path:               "/_matrix/client/v3/join/!room:another",
+			body:               `{}`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "knocking on a local room",
+			method:             "POST",
+			path:               "/_matrix/client/v3/knock/!room:host",
+			body:               `{}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "knocking on a remote room",
+			method:             "POST",
+			path:               "/_matrix/client/v3/knock/!room:another",
+			body:               `{}`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "knocking on a local room via a remote server",
+			method:             "POST",
+			path:               "/_matrix/client/v3/knock/!room:host?via=another",
+			body:               `{}`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "joining a remote room via own membership state",
+			method:             "PUT",
+			path:               "/_matrix/client/v3/rooms/!room:another/state/m.room.member/@a:host",
+			body:               `{"membership": "join"}`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "knocking on a remote room via own membership state",
+			method:             "PUT",
+			path:               "/_matrix/client/v3/rooms/!room:another/state/m.room.member/@a:host",
+			body:               `{"membership": "knock"}`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "joining a local room via own membership state",
+			method:             "PUT",
+			path:               "/_matrix/client/v3/rooms/!room:host/state/m.room.member/@a:host",
+			body:               `{"membership": "join"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "leaving a remote room via own membership state",
+			method:             "PUT",
+			path:               "/_matrix/client/v3/rooms/!room:another/state/m.room.member/@a:host",
+			body:               `{"membership": "leave"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, tc.path, bytes.NewReader([]byte(tc.body)))
+			request.Header.Set("Authorization", "Bearer token")
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.expectedStatusCode {
+				t.Errorf("expected status code %d, got %d: %s", tc.expectedStatusCode, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"context"
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/httpgateway/hookrunner"
+	"devture-matrix-corporal/corporal/httpgateway/responsefilter"
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/policy"
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// responseFilteredRoutesHandler handles routes whose responses may contain rooms or events that are hidden by the policy.
+// Such content is removed from the response before it's delivered to the user.
+//
+// Requests which can't be affected by the policy (no access token or no visibility restrictions for the user)
+// are handled by catchAllHandler, as if these routes were not special.
+// Until a policy is loaded, requests are proxied as they are.
+type responseFilteredRoutesHandler struct {
+	reverseProxy        *httputil.ReverseProxy
+	policyStore         *policy.Store
+	policyChecker       *policy.Checker
+	hookRunner          *hookrunner.HookRunner
+	userMappingResolver *matrix.UserMappingResolver
+	logger              *logrus.Logger
+
+	catchAllHandler *catchAllHandler
+}
+
+func NewResponseFilteredRoutesHandler(
+	reverseProxy *httputil.ReverseProxy,
+	policyStore *policy.Store,
+	policyChecker *policy.Checker,
+	hookRunner *hookrunner.HookRunner,
+	userMappingResolver *matrix.UserMappingResolver,
+	logger *logrus.Logger,
+) *responseFilteredRoutesHandler {
+	return &responseFilteredRoutesHandler{
+		reverseProxy:        reverseProxy,
+		policyStore:         policyStore,
+		policyChecker:       policyChecker,
+		hookRunner:          hookRunner,
+		userMappingResolver: userMappingResolver,
+		logger:              logger,
+
+		catchAllHandler: NewCatchAllHandler(reverseProxy, userMappingResolver, hookRunner, logger),
+	}
+}
+
+func (me *responseFilteredRoutesHandler) RegisterRoutesWithRouter(router *mux.Router) {
+	// Routes below define an optional trailing slash for the same reasons described in policyCheckedRoutesHandler.
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/sync{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("sync", responsefilter.FilterSyncResponse, false),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/messages{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.messages", responsefilter.FilterRoomMessagesResponse, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/context/{eventId}{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.context", responsefilter.FilterRoomContextResponse, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/state{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.state", responsefilter.FilterRoomStateResponse, true),
+	).Methods("GET")
+
+	// Hidden event types are rejected based on the `eventType` route variable, so there's nothing to filter.
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/state/{eventType}{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.state.get", nil, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/state/{eventType}/{stateKey}{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.state.get", nil, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/event/{eventId}{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.event", responsefilter.FilterRoomEventResponse, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/members{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.members", responsefilter.FilterRoomChunkResponse, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/relations/{relationPath:.+}`,
+		me.createResponseFilteringHandler("room.relations", responsefilter.FilterRoomChunkResponse, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/threads{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.threads", responsefilter.FilterRoomChunkResponse, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/initialSync{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.initial_sync", responsefilter.FilterRoomInitialSyncResponse, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/hierarchy{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.hierarchy", responsefilter.FilterRoomHierarchyResponse, true),
+	).Methods("GET")
+
+	// These room APIs only need the room visibility check.
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/joined_members{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.joined_members", nil, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/aliases{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.aliases", nil, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/rooms/{roomId}/timestamp_to_event{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("room.timestamp_to_event", nil, true),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/search{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("search", responsefilter.FilterSearchResponse, false),
+	).Methods("POST")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/notifications{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("notifications", responsefilter.FilterNotificationsResponse, false),
+	).Methods("GET")
+
+	// The responses of these APIs are not worth (or not possible to reliably) filter,
+	// so they're unavailable to users with visibility restrictions:
+	// - the deprecated global `/initialSync` and `/events` APIs
+	// - sliding sync (MSC3575 and its simplified MSC4186 variant)
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/initialSync{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("initial_sync", responsefilter.HideResponse, false),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/{apiVersion:(?:r0|v\d+)}/events{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("events", responsefilter.HideResponse, false),
+	).Methods("GET")
+
+	router.HandleFunc(
+		`/_matrix/client/unstable/{slidingSyncVersion:(?:org\.matrix\.msc3575|org\.matrix\.simplified_msc3575)}/sync{optionalTrailingSlash:[/]?}`,
+		me.createResponseFilteringHandler("sliding_sync", responsefilter.HideResponse, false),
+	).Methods("POST")
+}
+
+// GetSimulationRouteType implements hookrunner.SimulatableHandlerRegistrator
+func (me *responseFilteredRoutesHandler) GetSimulationRouteType() string {
+	return hookrunner.SimulationRouteTypeResponseFiltered
+}
+
+// createResponseFilteringHandler creates a handler which filters responses using the given response filter function.
+//
+// For room routes (isRoomRoute), requests for rooms hidden from the user are rejected upfront.
+// Likewise, requests for routes having an `eventType` variable are rejected upfront if the event type is hidden.
+// A nil responseFilterFunc means that these upfront checks are enough and responses do not need filtering.
+func (me *responseFilteredRoutesHandler) createResponseFilteringHandler(
+	name string,
+	responseFilterFunc responsefilter.ResponseFilterFunc,
+	isRoomRoute bool,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := me.logger.WithField("method", r.Method)
+		logger = logger.WithField("uri", r.RequestURI)
+		logger = logger.WithField("handler", name)
+
+		policyObj := me.policyStore.Get()
+		if policyObj == nil {
+			// Without a policy, nothing is hidden from anyone (and no hooks can run either),
+			// so we'd rather not break clients (e.g. `/sync`) while waiting for a policy to load.
+			logger.Debugf("HTTP gateway (response-filtered): proxying (missing policy)")
+			me.reverseProxy.ServeHTTP(w, r)
+			return
+		}
+
+		if !me.policyChecker.HasAnyVisibilityRestrictions(*policyObj) {
+			// Nothing is hidden from anyone, so there's no need to figure out who the request is for.
+			me.catchAllHandler.actionCatchAll(w, r)
+			return
+		}
+
+		accessToken := httphelp.GetAccessTokenFromRequest(r)
+		if accessToken == "" {
+			// There's nothing to hide from unauthenticated requests. The homeserver will reject them if necessary.
+			me.catchAllHandler.actionCatchAll(w, r)
+			return
+		}
+
+		userId, err := me.userMappingResolver.ResolveByAccessToken(accessToken)
+		if err == nil && !me.policyChecker.HasVisibilityRestrictions(*policyObj, userId) {
+			me.catchAllHandler.actionCatchAll(w, r)
+			return
+		}
+
+		httpResponseModifierFuncs := make([]hook.HttpResponseModifierFunc, 0)
+
+		if !runHooks(me.hookRunner, hook.EventTypeBeforeAnyRequest, w, r, logger, &httpResponseModifierFuncs) {
+			return
+		}
+
+		if err != nil {
+			// We can't tell whether anything needs to be hidden from this user, so we fail closed.
+			logger.Debugf("HTTP gateway (response-filtered): rejecting (failed to map access token)")
+
+			httphelp.RespondWithMatrixError(
+				w,
+				http.StatusForbidden,
+				matrix.ErrorUnknownToken,
+				"Failed mapping access token to user id",
+			)
+			return
+		}
+		logger = logger.WithField("userId", userId)
+
+		// We don't care that these fail the SA1029 static check
+		r = r.WithContext(context.WithValue(r.Context(), "accessToken", accessToken)) //nolint:staticcheck
+		r = r.WithContext(context.WithValue(r.Context(), "userId", userId))           //nolint:staticcheck
+
+		if !runHooks(me.hookRunner, hook.EventTypeBeforeAuthenticatedRequest, w, r, logger, &httpResponseModifierFuncs) {
+			return
+		}
+
+		isFilteringRequired := responseFilterFunc != nil
+
+		if isRoomRoute {
+			roomId := mux.Vars(r)["roomId"]
+
+			if !me.policyChecker.CanUserSeeRoom(*policyObj, userId, roomId) {
+				logger.Infof("HTTP gateway (response-filtered): denying (room %s is hidden)", roomId)
+
+				httphelp.RespondWithMatrixError(
+					w,
+					http.StatusForbidden,
+					matrix.ErrorForbidden,
+					"Denied by policy (room is hidden)",
+				)
+				return
+			}
+		}
+
+		if eventType, exists := mux.Vars(r)["eventType"]; exists {
+			if !me.policyChecker.CanUserSeeEventType(*policyObj, userId, eventType) {
+				logger.Infof("HTTP gateway (response-filtered): denying (event type %s is hidden)", eventType)
+
+				httphelp.RespondWithMatrixError(
+					w,
+					http.StatusForbidden,
+					matrix.ErrorForbidden,
+					"Denied by policy (event type is hidden)",
+				)
+				return
+			}
+		}
+
+		if !runHooks(me.hookRunner, hook.EventTypeAfterAnyRequest, w, r, logger, &httpResponseModifierFuncs) {
+			return
+		}
+
+		if !runHooks(me.hookRunner, hook.EventTypeAfterAuthenticatedRequest, w, r, logger, &httpResponseModifierFuncs) {
+			return
+		}
+
+		reverseProxyToUse := me.reverseProxy
+
+		if !isFilteringRequired && len(httpResponseModifierFuncs) == 0 {
+			logger.Debugf("HTTP gateway (response-filtered): proxying")
+		} else {
+			logger.Debugf("HTTP gateway (response-filtered): proxying (with response modification)")
+
+			hookResponseModifier := hook.CreateChainedHttpResponseModifierFunc(httpResponseModifierFuncs)
+
+			if isFilteringRequired {
+				// We'd like to work with plain JSON, not with whatever compressed version the homeserver may decide to send.
+				// If we don't ask for a specific encoding, the reverse-proxy's transport transparently handles compression for us.
+				r.Header.Del("Accept-Encoding")
+			}
+
+			policyCopy := *policyObj
+
+			reverseProxyCopy := *reverseProxyToUse
+			reverseProxyCopy.ModifyResponse = func(response *http.Response) error {
+				err := hookResponseModifier(response)
+				if err != nil || !isFilteringRequired {
+					return err
+				}
+
+				// Filtering runs last (and regardless of whether hooks asked to skip other modifiers),
+				// so that nothing `after*` hooks do can make hidden content reappear.
+				err = responsefilter.FilterResponse(response, responseFilterFunc, policyCopy, *me.policyChecker, userId)
+				if err != nil {
+					logger.Errorf("failed to filter response: %s", err)
+				}
+				return err
+			}
+			reverseProxyToUse = &reverseProxyCopy
+		}
+
+		reverseProxyToUse.ServeHTTP(w, r)
+	}
+}
+
+// Ensure interface is implemented
+var _ httphelp.HandlerRegistrator = &responseFilteredRoutesHandler{}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/httpgateway/hookrunner"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/policy"
+	"devture-matrix-corporal/corporal/userauth"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func TestResponseFilteredRoutesVisibility(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	userIdsByAccessToken := map[string]string{
+		"token-restricted":   "@restricted:host",
+		"token-unrestricted": "@unrestricted:host",
+	}
+
+	// The homeserver knows the access tokens above and accepts everything else
+	homeserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/account/whoami") {
+			userId, exists := userIdsByAccessToken[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+			if !exists {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"errcode": "M_UNKNOWN_TOKEN", "error": "Unknown token"}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"user_id": userId})
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer homeserver.Close()
+
+	homeserverURL, err := url.Parse(homeserver.URL)
+	if err != nil {
+		t.Fatalf("failed parsing homeserver URL: %s", err)
+	}
+
+	unauthenticatedHook := &hook.Hook{
+		ID:         "unauthenticated",
+		EventType:  hook.EventTypeBeforeUnauthenticatedRequest,
+		MatchRules: []*hook.HookMatchRule{},
+		Action:     hook.ActionRespond,
+	}
+	responseStatusCode := http.StatusTeapot
+	unauthenticatedHook.ResponseStatusCode = &responseStatusCode
+
+	hookStore := hook.NewStore()
+	err = hookStore.Set(hook.StoreSourceFile, []*hook.Hook{unauthenticatedHook})
+	if err != nil {
+		t.Fatalf("failed setting hooks: %s", err)
+	}
+
+	roomAliasResolver := policy.NewRoomAliasResolver(logger, nil, time.Minute)
+	policyStore := policy.NewStore(
+		logger,
+		policy.NewValidator("host", hookStore, roomAliasResolver),
+		roomAliasResolver,
+		hookStore,
+	)
+
+	cache, err := lru.New2Q[string, matrix.AccessTokenResolvingResult](10)
+	if err != nil {
+		t.Fatalf("failed creating cache: %s", err)
+	}
+
+	router := mux.NewRouter()
+	NewResponseFilteredRoutesHandler(
+		httputil.NewSingleHostReverseProxy(homeserverURL),
+		policyStore,
+		policy.NewChecker(),
+		hookrunner.NewHookRunner(policyStore, hookStore, hook.NewExecutor(hook.NewRESTServiceConsultor(5*time.Second))),
+		matrix.NewUserMappingResolver(logger, homeserver.URL, cache, 60000),
+		logger,
+	).RegisterRoutesWithRouter(router)
+
+	type testCase struct {
+		name               string
+		accessToken        string
+		path               string
+		expectedStatusCode int
+	}
+
+	serve := func(t *testing.T, tc testCase) {
+		request := httptest.NewRequest("GET", tc.path, nil)
+		if tc.accessToken != "" {
+			request.Header.Set("Authorization", "Bearer "+tc.accessToken)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != tc.expectedStatusCode {
+			t.Errorf("expected status code %d, got %d: %s", tc.expectedStatusCode, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	// Until there's a policy, nothing can be hidden, so requests are proxied as they are.
+	serve(t, testCase{
+		accessToken:        "token-restricted",
+		path:               "/_matrix/client/v3/rooms/!hidden:host/messages",
+		expectedStatusCode: http.StatusOK,
+	})
+
+	err = policyStore.Set(&policy.Policy{
+		SchemaVersion: 2,
+		User: []*policy.UserPolicy{
+			{
+				Id:            "@restricted:host",
+				Active:        true,
+				AuthType:      userauth.UserAuthTypePassthrough,
+				HiddenRoomIds: []string{"!hidden:host"},
+			},
+			{
+				Id:       "@unrestricted:host",
+				Active:   true,
+				AuthType: userauth.UserAuthTypePassthrough,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed setting policy: %s", err)
+	}
+
+	testCases := []testCase{
+		{
+			name:               "restricted user requesting a hidden room",
+			accessToken:        "token-restricted",
+			path:               "/_matrix/client/v3/rooms/!hidden:host/messages",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "restricted user requesting the members of a hidden room",
+			accessToken:        "token-restricted",
+			path:               "/_matrix/client/v3/rooms/!hidden:host/joined_members",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "restricted user requesting a visible room",
+			accessToken:        "token-restricted",
+			path:               "/_matrix/client/v3/rooms/!visible:host/joined_members",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "unrestricted user requesting a room hidden from others",
+			accessToken:        "token-unrestricted",
+			path:               "/_matrix/client/v3/rooms/!hidden:host/messages",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "unknown access token",
+			accessToken:        "token-unknown",
+			path:               "/_matrix/client/v3/sync",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "unauthenticated request runs beforeUnauthenticatedRequest hooks",
+			path:               "/_matrix/client/v3/sync",
+			expectedStatusCode: http.StatusTeapot,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			serve(t, tc)
+		})
+	}
+}
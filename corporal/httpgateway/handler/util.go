@@ -3,6 +3,7 @@ package handler
 import (
 	"devture-matrix-corporal/corporal/hook"
 	"devture-matrix-corporal/corporal/httpgateway/hookrunner"
+	"io"
 	"net/http"
 
 	"github.com/sirupsen/logrus"
@@ -29,3 +30,19 @@ func runHooks(
 
 	return true
 }
+
+// writeResponse writes out a response we've prepared ourselves (instead of one coming from the reverse-proxy)
+func writeResponse(w http.ResponseWriter, response *http.Response, logger *logrus.Entry) {
+	for headerName, headerValues := range response.Header {
+		for _, headerValue := range headerValues {
+			w.Header().Add(headerName, headerValue)
+		}
+	}
+
+	w.WriteHeader(response.StatusCode)
+
+	_, err := io.Copy(w, response.Body)
+	if err != nil {
+		logger.Warnf("failed writing response: %s", err)
+	}
+}
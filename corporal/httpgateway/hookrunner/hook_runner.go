@@ -12,12 +12,14 @@ import (
 
 type HookRunner struct {
 	policyStore *policy.Store
+	hookStore   *hook.Store
 	executor    *hook.Executor
 }
 
-func NewHookRunner(policyStore *policy.Store, executor *hook.Executor) *HookRunner {
+func NewHookRunner(policyStore *policy.Store, hookStore *hook.Store, executor *hook.Executor) *HookRunner {
 	return &HookRunner{
 		policyStore: policyStore,
+		hookStore:   hookStore,
 		executor:    executor,
 	}
 }
@@ -39,12 +41,30 @@ func (me *HookRunner) RunAllMatchingType(eventType string, w http.ResponseWriter
 		}
 	}
 
+	runHook := func(hookObj *hook.Hook, logger *logrus.Entry) hook.ExecutionResult {
+		return executeHook(me.executor, hookObj, w, request, logger)
+	}
+
+	return runMatchingHooks(me.hookStore.GetMerged(), eventType, request, logger, runHook)
+}
+
+// runMatchingHooks runs (via runHook) the hooks of the given event type which match the request, in order,
+// until one of them sends a response, hits an error, or requests that the next hooks in the chain be skipped.
+//
+// It's shared by the HookRunner and the Simulator, so that both of them build hook chains the same way.
+func runMatchingHooks(
+	hooks []*hook.Hook,
+	eventType string,
+	request *http.Request,
+	logger *logrus.Entry,
+	runHook func(hookObj *hook.Hook, logger *logrus.Entry) hook.ExecutionResult,
+) hook.ExecutionResult {
 	executedHooks := make([]*hook.Hook, 0)
 	httpResponseModifierFuncs := make([]hook.HttpResponseModifierFunc, 0)
 
 	logger = logger.WithField("hookEventType", eventType)
 
-	for _, hookObj := range policyObj.Hooks {
+	for _, hookObj := range hooks {
 		if hookObj.EventType != eventType || !hookObj.MatchesRequest(request) {
 			continue
 		}
@@ -56,7 +76,7 @@ func (me *HookRunner) RunAllMatchingType(eventType string, w http.ResponseWriter
 		// The chain also includes the current hook
 		logger = logger.WithField("hookChain", hook.ListToChain(executedHooks))
 
-		executionResult := me.runHook(hookObj, w, request, logger)
+		executionResult := runHook(hookObj, logger)
 
 		httpResponseModifierFuncs = append(httpResponseModifierFuncs, executionResult.ReverseProxyResponseModifiers...)
 
@@ -85,10 +105,11 @@ func (me *HookRunner) RunAllMatchingType(eventType string, w http.ResponseWriter
 	}
 }
 
-func (me *HookRunner) runHook(hookObj *hook.Hook, w http.ResponseWriter, request *http.Request, logger *logrus.Entry) hook.ExecutionResult {
+// executeHook executes a single hook and responds with an error if that fails.
+func executeHook(executor *hook.Executor, hookObj *hook.Hook, w http.ResponseWriter, request *http.Request, logger *logrus.Entry) hook.ExecutionResult {
 	logger.Infof("Executing hook")
 
-	result := me.executor.Execute(hookObj, w, request, logger)
+	result := executor.Execute(hookObj, w, request, logger)
 
 	logger.Debugf("Hook execution result: %#v\n", result)
 
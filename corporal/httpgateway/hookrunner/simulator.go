@@ -0,0 +1,421 @@
+package hookrunner
+
+import (
+	"bytes"
+	"context"
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/policy"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SimulationResponseSourceHook indicates that the final response was generated by a hook (or a hook processing failure).
+	SimulationResponseSourceHook = "hook"
+
+	// SimulationResponseSourceUpstream indicates that the final response is the (simulated) upstream response,
+	// possibly modified by `after*` hooks.
+	SimulationResponseSourceUpstream = "upstream"
+)
+
+// SimulationRequest describes a synthetic request which hooks get tested against.
+type SimulationRequest struct {
+	Method string `json:"method"`
+
+	// Path is the request URI (path, optionally followed by a query string), e.g. `/_matrix/client/r0/createRoom`
+	Path string `json:"path"`
+
+	Headers map[string]string `json:"headers"`
+
+	Body string `json:"body"`
+
+	// AuthenticatedUserId is the full Matrix user ID of the user making the request.
+	// Leaving it empty simulates an unauthenticated request.
+	AuthenticatedUserId string `json:"authenticatedUserId"`
+
+	// UpstreamResponse is the response that the homeserver is pretending to have returned.
+	// If not specified, a `200 OK` response with an empty JSON object payload is used.
+	UpstreamResponse *SimulationResponse `json:"upstreamResponse"`
+
+	// DryRun specifies whether hooks with side effects (consulting REST services, flagging content, etc.)
+	// are only reported, instead of being executed.
+	// Such hooks are then treated as if they had let the request pass unmodified.
+	DryRun bool `json:"dryRun"`
+}
+
+type SimulationResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+}
+
+type SimulationResult struct {
+	// RouteType is the type of route (one of the SimulationRouteType* constants) that the request matched.
+	// It determines which hook event types get executed.
+	RouteType string `json:"routeType"`
+
+	EventTypes []*SimulationEventTypeResult `json:"eventTypes"`
+
+	// ResponseSource is one of the SimulationResponseSource* constants
+	ResponseSource string `json:"responseSource"`
+
+	Response SimulationResponse `json:"response"`
+}
+
+type SimulationEventTypeResult struct {
+	EventType string `json:"eventType"`
+
+	// HookChain is the chain of hooks (see hook.ListToChain) which matched the request and got executed for this event type.
+	HookChain string `json:"hookChain"`
+
+	Hooks []*SimulationHookResult `json:"hooks"`
+}
+
+type SimulationHookResult struct {
+	HookId string `json:"hookId"`
+	Action string `json:"action"`
+
+	ResponseSent         bool   `json:"responseSent"`
+	SkipNextHooksInChain bool   `json:"skipNextHooksInChain"`
+	ProcessingError      string `json:"processingError,omitempty"`
+
+	// DryRunSkipped indicates that the hook has side effects (see hook.Hook.HasSideEffects),
+	// so it was not executed, because the simulation is a dry run (see SimulationRequest.DryRun).
+	DryRunSkipped bool `json:"dryRunSkipped,omitempty"`
+}
+
+// Simulator runs a synthetic request through the hooks defined in the current policy,
+// the same way the HTTP gateway would, but without contacting the homeserver.
+//
+// This is meant for debugging hook chains.
+// Hooks which consult REST services do contact these services for real, unless the simulation is a dry run.
+//
+// Requests are matched against the routes of the HTTP gateway's handler registrators,
+// to figure out which hook event types the gateway would run for them.
+type Simulator struct {
+	policyStore   *policy.Store
+	hookStore     *hook.Store
+	executor      *hook.Executor
+	routeMatchers []simulationRouteMatcher
+}
+
+func NewSimulator(
+	policyStore *policy.Store,
+	hookStore *hook.Store,
+	executor *hook.Executor,
+	handlerRegistrators []httphelp.HandlerRegistrator,
+) *Simulator {
+	return &Simulator{
+		policyStore:   policyStore,
+		hookStore:     hookStore,
+		executor:      executor,
+		routeMatchers: createSimulationRouteMatchers(handlerRegistrators),
+	}
+}
+
+func (me *Simulator) Simulate(simulationRequest SimulationRequest, logger *logrus.Entry) (*SimulationResult, error) {
+	policyObj := me.policyStore.Get()
+	if policyObj == nil {
+		return nil, fmt.Errorf("policy does not exist (yet)")
+	}
+
+	request, err := createSimulationHttpRequest(simulationRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	routeType := determineSimulationRouteType(me.routeMatchers, request)
+	if routeType == SimulationRouteTypeInternal {
+		return nil, fmt.Errorf("%s %s is served by matrix-corporal itself, without running any hooks", request.Method, request.URL.Path)
+	}
+
+	logger = logger.WithField("method", request.Method)
+	logger = logger.WithField("uri", request.RequestURI)
+	logger = logger.WithField("simulation", true)
+	logger = logger.WithField("routeType", routeType)
+
+	result := &SimulationResult{
+		RouteType:  routeType,
+		EventTypes: make([]*SimulationEventTypeResult, 0),
+	}
+
+	// Hooks write their responses (if any) here, instead of to a real client.
+	hookResponse := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader([]byte{})),
+	}
+	hookResponseWriter := httphelp.NewResponseBoundHttpWriter(hookResponse)
+
+	hooks := me.hookStore.GetMerged()
+
+	isAuthenticated := simulationRequest.AuthenticatedUserId != ""
+
+	var loginInformation *hook.LoginInformation
+	if routeType == SimulationRouteTypeLogin {
+		// Interceptors (which would normally figure out these details) are not simulated.
+		loginInformation = &hook.LoginInformation{
+			LoginType: determineSimulationLoginType(simulationRequest.Body),
+		}
+
+		// We don't care that this fails the SA1029 static check
+		request = request.WithContext(context.WithValue(request.Context(), "loginInformation", loginInformation)) //nolint:staticcheck
+	}
+
+	var scheduledAfterHooks []simulationScheduledAfterHook
+
+	for _, eventType := range orderedSimulationEventTypes(routeType, isAuthenticated) {
+		eventTypeResult, eventTypeScheduledAfterHooks, responseSent := me.simulateEventType(eventType, hooks, request, hookResponseWriter, simulationRequest.DryRun, logger)
+		result.EventTypes = append(result.EventTypes, eventTypeResult)
+		scheduledAfterHooks = append(scheduledAfterHooks, eventTypeScheduledAfterHooks...)
+
+		if responseSent {
+			hookResponseWriter.Commit()
+
+			result.ResponseSource = SimulationResponseSourceHook
+			result.Response, err = createSimulationResponse(hookResponse)
+			return result, err
+		}
+	}
+
+	// If we're here, no `before*` hook responded, so the request would have been reverse-proxied to the upstream.
+	// Instead of doing that, we pretend the upstream returned the response we were given.
+	// `after*` hooks can then do their work on that response.
+	upstreamResponse := createSimulationUpstreamResponse(simulationRequest.UpstreamResponse)
+
+	isDone, err := me.simulateAfterHooks(scheduledAfterHooks, request, upstreamResponse, result)
+	if isDone || err != nil {
+		return result, err
+	}
+
+	if loginInformation != nil {
+		// The login outcome is determined based on the (possibly modified) response, after all other `after*` hooks had run.
+		eventType := hook.RecordLoginOutcome(loginInformation, upstreamResponse)
+
+		eventTypeResult, eventTypeScheduledAfterHooks, _ := me.simulateEventType(eventType, hooks, request, hookResponseWriter, simulationRequest.DryRun, logger)
+		result.EventTypes = append(result.EventTypes, eventTypeResult)
+
+		isDone, err := me.simulateAfterHooks(eventTypeScheduledAfterHooks, request, upstreamResponse, result)
+		if isDone || err != nil {
+			return result, err
+		}
+	}
+
+	result.ResponseSource = SimulationResponseSourceUpstream
+	result.Response, err = createSimulationResponse(upstreamResponse)
+
+	return result, err
+}
+
+type simulationScheduledAfterHook struct {
+	hookObj    *hook.Hook
+	hookResult *SimulationHookResult
+	logger     *logrus.Entry
+}
+
+// simulateEventType runs the `before*` hooks of the given event type and schedules its `after*` hooks (to be run by simulateAfterHooks).
+// It also reports whether a response was sent (which includes hook processing failures), in which case the simulation is over.
+//
+// In dry-run mode, hooks with side effects (see hook.Hook.HasSideEffects) are reported, but not executed.
+func (me *Simulator) simulateEventType(
+	eventType string,
+	hooks []*hook.Hook,
+	request *http.Request,
+	hookResponseWriter httphelp.ResponseBoundHttpWriter,
+	dryRun bool,
+	logger *logrus.Entry,
+) (*SimulationEventTypeResult, []simulationScheduledAfterHook, bool) {
+	eventTypeResult := &SimulationEventTypeResult{
+		EventType: eventType,
+		Hooks:     make([]*SimulationHookResult, 0),
+	}
+
+	var scheduledAfterHooks []simulationScheduledAfterHook
+
+	runHook := func(hookObj *hook.Hook, hookLogger *logrus.Entry) hook.ExecutionResult {
+		hookResult := &SimulationHookResult{
+			HookId: hookObj.ID,
+			Action: hookObj.Action,
+		}
+		eventTypeResult.Hooks = append(eventTypeResult.Hooks, hookResult)
+
+		if dryRun && hookObj.HasSideEffects() {
+			// Skipped hooks are treated as if they had let the request pass unmodified.
+			hookLogger.Infof("Not executing hook with side effects (dry run)")
+			hookResult.DryRunSkipped = true
+			return hook.ExecutionResult{}
+		}
+
+		if hookObj.IsAfterHook() {
+			// After-hooks only get scheduled now and will actually run against the upstream response later.
+			scheduledAfterHooks = append(scheduledAfterHooks, simulationScheduledAfterHook{
+				hookObj:    hookObj,
+				hookResult: hookResult,
+				logger:     hookLogger,
+			})
+			return hook.ExecutionResult{}
+		}
+
+		executionResult := executeHook(me.executor, hookObj, hookResponseWriter, request, hookLogger)
+
+		hookResult.ResponseSent = executionResult.ResponseSent
+		hookResult.SkipNextHooksInChain = executionResult.SkipNextHooksInChain
+		if executionResult.ProcessingError != nil {
+			hookResult.ProcessingError = executionResult.ProcessingError.Error()
+		}
+
+		return executionResult
+	}
+
+	chainResult := runMatchingHooks(hooks, eventType, request, logger, runHook)
+
+	eventTypeResult.HookChain = hook.ListToChain(chainResult.Hooks)
+
+	return eventTypeResult, scheduledAfterHooks, chainResult.ResponseSent
+}
+
+// simulateAfterHooks runs the given (scheduled) `after*` hooks against the upstream response.
+// If the simulation is over (a hook failed or requested that no more hooks run), the result's response is populated and true is returned.
+func (me *Simulator) simulateAfterHooks(
+	scheduledAfterHooks []simulationScheduledAfterHook,
+	request *http.Request,
+	upstreamResponse *http.Response,
+	result *SimulationResult,
+) (bool, error) {
+	for _, scheduled := range scheduledAfterHooks {
+		executionResult, skipNextModifiers, err := me.executor.ExecuteAfterHookAgainstResponse(
+			scheduled.hookObj,
+			request,
+			upstreamResponse,
+			scheduled.logger,
+		)
+
+		scheduled.hookResult.ResponseSent = executionResult.ResponseSent
+		scheduled.hookResult.SkipNextHooksInChain = skipNextModifiers
+		if executionResult.ProcessingError != nil {
+			scheduled.hookResult.ProcessingError = executionResult.ProcessingError.Error()
+
+			// The after-hook has already replaced the upstream response with an error response.
+			result.ResponseSource = SimulationResponseSourceHook
+			result.Response, err = createSimulationResponse(upstreamResponse)
+			return true, err
+		}
+
+		if err != nil {
+			scheduled.hookResult.ProcessingError = err.Error()
+
+			// The reverse-proxy fails like this when a response modifier returns an error.
+			result.ResponseSource = SimulationResponseSourceHook
+			result.Response = SimulationResponse{
+				StatusCode: http.StatusBadGateway,
+				Headers:    map[string]string{},
+			}
+			return true, nil
+		}
+
+		if skipNextModifiers {
+			// No other scheduled response modifiers run after this.
+			// The login outcome still gets determined though, as it's not part of their chain.
+			return false, nil
+		}
+	}
+
+	return false, nil
+}
+
+// determineSimulationLoginType extracts the login type (e.g. `m.login.password`) out of a login request body (if possible)
+func determineSimulationLoginType(body string) string {
+	var payload struct {
+		Type string `json:"type"`
+	}
+
+	_ = json.Unmarshal([]byte(body), &payload)
+
+	return payload.Type
+}
+
+func createSimulationHttpRequest(simulationRequest SimulationRequest) (*http.Request, error) {
+	if simulationRequest.Method == "" {
+		return nil, fmt.Errorf("a request method is required")
+	}
+
+	if !strings.HasPrefix(simulationRequest.Path, "/") {
+		return nil, fmt.Errorf("a request path (starting with /) is required")
+	}
+
+	request, err := http.NewRequest(
+		simulationRequest.Method,
+		simulationRequest.Path,
+		bytes.NewReader([]byte(simulationRequest.Body)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %s", err)
+	}
+
+	// Hooks (and the REST services they consult) work with the request URI, like they would for a real incoming request.
+	request.RequestURI = simulationRequest.Path
+
+	for name, value := range simulationRequest.Headers {
+		request.Header.Set(name, value)
+	}
+
+	if simulationRequest.AuthenticatedUserId != "" {
+		// This is what the HTTP gateway does for authenticated requests.
+		// We don't care that this fails the SA1029 static check
+		request = request.WithContext(context.WithValue(request.Context(), "userId", simulationRequest.AuthenticatedUserId)) //nolint:staticcheck
+	}
+
+	return request, nil
+}
+
+func createSimulationUpstreamResponse(simulationResponse *SimulationResponse) *http.Response {
+	if simulationResponse == nil {
+		simulationResponse = &SimulationResponse{
+			StatusCode: http.StatusOK,
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			Body: "{}",
+		}
+	}
+
+	response := &http.Response{
+		StatusCode: simulationResponse.StatusCode,
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader([]byte(simulationResponse.Body))),
+	}
+
+	if response.StatusCode == 0 {
+		response.StatusCode = http.StatusOK
+	}
+
+	for name, value := range simulationResponse.Headers {
+		response.Header.Set(name, value)
+	}
+
+	return response
+}
+
+func createSimulationResponse(response *http.Response) (SimulationResponse, error) {
+	bodyBytes, err := httphelp.GetResponseBody(response)
+	if err != nil {
+		return SimulationResponse{}, err
+	}
+
+	headers := map[string]string{}
+	for name := range response.Header {
+		headers[name] = response.Header.Get(name)
+	}
+
+	return SimulationResponse{
+		StatusCode: response.StatusCode,
+		Headers:    headers,
+		Body:       string(bodyBytes),
+	}, nil
+}
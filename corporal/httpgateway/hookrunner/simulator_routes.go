@@ -0,0 +1,143 @@
+package hookrunner
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/httphelp"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// SimulationRouteTypePolicyChecked is the route type of policy-checked routes (see the policy-checked routes handler)
+	SimulationRouteTypePolicyChecked = "policyChecked"
+
+	// SimulationRouteTypeResponseFiltered is the route type of response-filtered routes (see the response-filtered routes handler)
+	SimulationRouteTypeResponseFiltered = "responseFiltered"
+
+	// SimulationRouteTypeLogin is the route type of login routes (see the login handler)
+	SimulationRouteTypeLogin = "login"
+
+	// SimulationRouteTypeCatchAll is the route type of all other routes, which are simply reverse-proxied to the homeserver
+	SimulationRouteTypeCatchAll = "catchAll"
+
+	// SimulationRouteTypeInternal is the route type of routes served by matrix-corporal itself, without running any hooks
+	SimulationRouteTypeInternal = "internal"
+)
+
+// SimulatableHandlerRegistrator is an HTTP gateway handler registrator, which tells how the routes it registers run hooks.
+//
+// Registrators which don't implement this are considered to register SimulationRouteTypeInternal routes.
+type SimulatableHandlerRegistrator interface {
+	httphelp.HandlerRegistrator
+
+	// GetSimulationRouteType returns one of the SimulationRouteType* constants
+	GetSimulationRouteType() string
+}
+
+// simulationRouteMatcher matches requests against the routes of a single HTTP gateway handler registrator
+type simulationRouteMatcher struct {
+	routeType string
+	router    *mux.Router
+}
+
+// createSimulationRouteMatchers registers the routes of each handler registrator with a router of its own,
+// so that we can tell which registrator's routes a request matches.
+//
+// The handler registrators need to be the same (and in the same order) as the ones used by the HTTP gateway.
+func createSimulationRouteMatchers(handlerRegistrators []httphelp.HandlerRegistrator) []simulationRouteMatcher {
+	routeMatchers := make([]simulationRouteMatcher, 0, len(handlerRegistrators))
+
+	for _, handlerRegistrator := range handlerRegistrators {
+		routeType := SimulationRouteTypeInternal
+		if simulatableHandlerRegistrator, ok := handlerRegistrator.(SimulatableHandlerRegistrator); ok {
+			routeType = simulatableHandlerRegistrator.GetSimulationRouteType()
+		}
+
+		router := mux.NewRouter()
+		handlerRegistrator.RegisterRoutesWithRouter(router)
+
+		routeMatchers = append(routeMatchers, simulationRouteMatcher{
+			routeType: routeType,
+			router:    router,
+		})
+	}
+
+	return routeMatchers
+}
+
+// determineSimulationRouteType returns the route type (one of the SimulationRouteType* constants) of the first route matching the request
+func determineSimulationRouteType(routeMatchers []simulationRouteMatcher, request *http.Request) string {
+	for _, routeMatcher := range routeMatchers {
+		var routeMatch mux.RouteMatch
+		if !routeMatcher.router.Match(request, &routeMatch) || routeMatch.MatchErr != nil {
+			// A path matching with a different method (mux.ErrMethodMismatch) is not a match either.
+			// The HTTP gateway tries the next routes in such cases.
+			continue
+		}
+
+		if routeMatcher.routeType == SimulationRouteTypeCatchAll && request.Method == http.MethodOptions {
+			// The catch-all handler responds to these by itself.
+			return SimulationRouteTypeInternal
+		}
+
+		return routeMatcher.routeType
+	}
+
+	return SimulationRouteTypeInternal
+}
+
+// orderedSimulationEventTypes returns the hook event types, in the order in which the HTTP gateway would run them for such a request.
+//
+// Login routes run additional event types (see the login handler), which are not included here.
+func orderedSimulationEventTypes(routeType string, isAuthenticated bool) []string {
+	if routeType == SimulationRouteTypePolicyChecked {
+		if isAuthenticated {
+			return []string{
+				hook.EventTypeBeforeAnyRequest,
+				hook.EventTypeBeforeAuthenticatedRequest,
+				hook.EventTypeBeforeAuthenticatedPolicyCheckedRequest,
+				hook.EventTypeAfterAnyRequest,
+				hook.EventTypeAfterAuthenticatedRequest,
+				hook.EventTypeAfterAuthenticatedPolicyCheckedRequest,
+			}
+		}
+
+		return []string{
+			hook.EventTypeBeforeAnyRequest,
+			hook.EventTypeAfterAnyRequest,
+		}
+	}
+
+	if routeType == SimulationRouteTypeLogin {
+		// Login requests are always treated as unauthenticated ones.
+		return []string{
+			hook.EventTypeBeforeAnyRequest,
+			hook.EventTypeBeforeUnauthenticatedRequest,
+			hook.EventTypeAfterAnyRequest,
+			hook.EventTypeAfterUnauthenticatedRequest,
+			hook.EventTypeBeforeLogin,
+		}
+	}
+
+	// Response-filtered routes are handled like catch-all ones, except for the filtering itself (which is not simulated).
+	if routeType == SimulationRouteTypeResponseFiltered || routeType == SimulationRouteTypeCatchAll {
+		if isAuthenticated {
+			return []string{
+				hook.EventTypeBeforeAnyRequest,
+				hook.EventTypeBeforeAuthenticatedRequest,
+				hook.EventTypeAfterAnyRequest,
+				hook.EventTypeAfterAuthenticatedRequest,
+			}
+		}
+
+		return []string{
+			hook.EventTypeBeforeAnyRequest,
+			hook.EventTypeBeforeUnauthenticatedRequest,
+			hook.EventTypeAfterAnyRequest,
+			hook.EventTypeAfterUnauthenticatedRequest,
+		}
+	}
+
+	return []string{}
+}
@@ -0,0 +1,334 @@
+package hookrunner
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/policy"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// testHandlerRegistrator mimics an HTTP gateway handler registrator, by registering the given routes (method + path template)
+type testHandlerRegistrator struct {
+	routes [][2]string
+}
+
+func (me testHandlerRegistrator) RegisterRoutesWithRouter(router *mux.Router) {
+	for _, route := range me.routes {
+		router.HandleFunc(route[1], func(w http.ResponseWriter, r *http.Request) {}).Methods(route[0])
+	}
+}
+
+type testSimulatableHandlerRegistrator struct {
+	testHandlerRegistrator
+
+	routeType string
+}
+
+func (me testSimulatableHandlerRegistrator) GetSimulationRouteType() string {
+	return me.routeType
+}
+
+type testCatchAllHandlerRegistrator struct{}
+
+func (me testCatchAllHandlerRegistrator) RegisterRoutesWithRouter(router *mux.Router) {
+	router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+}
+
+func (me testCatchAllHandlerRegistrator) GetSimulationRouteType() string {
+	return SimulationRouteTypeCatchAll
+}
+
+// createTestHandlerRegistrators returns handler registrators which are ordered (and register routes) like the HTTP gateway's ones
+func createTestHandlerRegistrators() []httphelp.HandlerRegistrator {
+	return []httphelp.HandlerRegistrator{
+		testHandlerRegistrator{
+			routes: [][2]string{{"GET", `/_matrix/corporal/user/{userId}/rest-auth`}},
+		},
+		testSimulatableHandlerRegistrator{
+			testHandlerRegistrator: testHandlerRegistrator{
+				routes: [][2]string{{"POST", `/_matrix/client/{apiVersion:(?:r0|v\d+)}/createRoom{optionalTrailingSlash:[/]?}`}},
+			},
+			routeType: SimulationRouteTypePolicyChecked,
+		},
+		testSimulatableHandlerRegistrator{
+			testHandlerRegistrator: testHandlerRegistrator{
+				routes: [][2]string{{"GET", `/_matrix/client/{apiVersion:(?:r0|v\d+)}/sync{optionalTrailingSlash:[/]?}`}},
+			},
+			routeType: SimulationRouteTypeResponseFiltered,
+		},
+		testSimulatableHandlerRegistrator{
+			testHandlerRegistrator: testHandlerRegistrator{
+				routes: [][2]string{{"POST", `/_matrix/client/{apiVersion:(?:r0|v\d+)}/login{optionalTrailingSlash:[/]?}`}},
+			},
+			routeType: SimulationRouteTypeLogin,
+		},
+		testCatchAllHandlerRegistrator{},
+	}
+}
+
+func TestDetermineSimulationRouteType(t *testing.T) {
+	type testCase struct {
+		method            string
+		path              string
+		expectedRouteType string
+	}
+
+	testCases := []testCase{
+		{"POST", "/_matrix/client/v3/createRoom", SimulationRouteTypePolicyChecked},
+		{"POST", "/_matrix/client/r0/createRoom/", SimulationRouteTypePolicyChecked},
+		// Same path, but a method that the policy-checked route does not handle
+		{"GET", "/_matrix/client/v3/createRoom", SimulationRouteTypeCatchAll},
+		{"GET", "/_matrix/client/v3/sync?since=s123", SimulationRouteTypeResponseFiltered},
+		{"POST", "/_matrix/client/v3/login", SimulationRouteTypeLogin},
+		{"GET", "/_matrix/client/v3/login", SimulationRouteTypeCatchAll},
+		{"GET", "/_matrix/client/v3/profile/@a:host", SimulationRouteTypeCatchAll},
+		{"OPTIONS", "/_matrix/client/v3/createRoom", SimulationRouteTypeInternal},
+		{"GET", "/_matrix/corporal/user/@a:host/rest-auth", SimulationRouteTypeInternal},
+	}
+
+	routeMatchers := createSimulationRouteMatchers(createTestHandlerRegistrators())
+
+	for _, tc := range testCases {
+		request, err := createSimulationHttpRequest(SimulationRequest{Method: tc.method, Path: tc.path})
+		if err != nil {
+			t.Fatalf("failed creating request for %s %s: %s", tc.method, tc.path, err)
+		}
+
+		routeType := determineSimulationRouteType(routeMatchers, request)
+		if routeType != tc.expectedRouteType {
+			t.Errorf("expected %s %s to be of route type %s, got %s", tc.method, tc.path, tc.expectedRouteType, routeType)
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	type testCase struct {
+		name              string
+		hooksJson         string
+		simulationRequest SimulationRequest
+
+		expectedError              bool
+		expectedRouteType          string
+		expectedEventTypes         []string
+		expectedHookIds            []string
+		expectedResponseSource     string
+		expectedResponseStatusCode int
+	}
+
+	testCases := []testCase{
+		{
+			name:              "authenticated policy-checked request",
+			hooksJson:         `[]`,
+			simulationRequest: SimulationRequest{Method: "POST", Path: "/_matrix/client/v3/createRoom", AuthenticatedUserId: "@a:host"},
+			expectedRouteType: SimulationRouteTypePolicyChecked,
+			expectedEventTypes: []string{
+				hook.EventTypeBeforeAnyRequest,
+				hook.EventTypeBeforeAuthenticatedRequest,
+				hook.EventTypeBeforeAuthenticatedPolicyCheckedRequest,
+				hook.EventTypeAfterAnyRequest,
+				hook.EventTypeAfterAuthenticatedRequest,
+				hook.EventTypeAfterAuthenticatedPolicyCheckedRequest,
+			},
+			expectedResponseSource:     SimulationResponseSourceUpstream,
+			expectedResponseStatusCode: http.StatusOK,
+		},
+		{
+			name:              "authenticated catch-all request",
+			hooksJson:         `[]`,
+			simulationRequest: SimulationRequest{Method: "GET", Path: "/_matrix/client/v3/createRoom", AuthenticatedUserId: "@a:host"},
+			expectedRouteType: SimulationRouteTypeCatchAll,
+			expectedEventTypes: []string{
+				hook.EventTypeBeforeAnyRequest,
+				hook.EventTypeBeforeAuthenticatedRequest,
+				hook.EventTypeAfterAnyRequest,
+				hook.EventTypeAfterAuthenticatedRequest,
+			},
+			expectedResponseSource:     SimulationResponseSourceUpstream,
+			expectedResponseStatusCode: http.StatusOK,
+		},
+		{
+			name:              "unauthenticated response-filtered request is handled like a catch-all one",
+			hooksJson:         `[]`,
+			simulationRequest: SimulationRequest{Method: "GET", Path: "/_matrix/client/v3/sync"},
+			expectedRouteType: SimulationRouteTypeResponseFiltered,
+			expectedEventTypes: []string{
+				hook.EventTypeBeforeAnyRequest,
+				hook.EventTypeBeforeUnauthenticatedRequest,
+				hook.EventTypeAfterAnyRequest,
+				hook.EventTypeAfterUnauthenticatedRequest,
+			},
+			expectedResponseSource:     SimulationResponseSourceUpstream,
+			expectedResponseStatusCode: http.StatusOK,
+		},
+		{
+			name:              "successful login",
+			hooksJson:         `[]`,
+			simulationRequest: SimulationRequest{Method: "POST", Path: "/_matrix/client/v3/login", Body: `{"type": "m.login.password"}`},
+			expectedRouteType: SimulationRouteTypeLogin,
+			expectedEventTypes: []string{
+				hook.EventTypeBeforeAnyRequest,
+				hook.EventTypeBeforeUnauthenticatedRequest,
+				hook.EventTypeAfterAnyRequest,
+				hook.EventTypeAfterUnauthenticatedRequest,
+				hook.EventTypeBeforeLogin,
+				hook.EventTypeAfterLoginSuccess,
+			},
+			expectedResponseSource:     SimulationResponseSourceUpstream,
+			expectedResponseStatusCode: http.StatusOK,
+		},
+		{
+			name: "failed login runs afterLoginFailure hooks",
+			hooksJson: `[
+				{"id": "after-login-success", "eventType": "afterLoginSuccess", "action": "pass.unmodified"},
+				{"id": "after-login-failure", "eventType": "afterLoginFailure", "action": "pass.modifiedResponse", "injectJSONIntoResponse": {"retry": false}}
+			]`,
+			simulationRequest: SimulationRequest{
+				Method:              "POST",
+				Path:                "/_matrix/client/v3/login",
+				AuthenticatedUserId: "@ignored:host",
+				UpstreamResponse: &SimulationResponse{
+					StatusCode: http.StatusForbidden,
+					Body:       `{"errcode": "M_FORBIDDEN", "error": "Invalid password"}`,
+				},
+			},
+			expectedRouteType: SimulationRouteTypeLogin,
+			expectedEventTypes: []string{
+				hook.EventTypeBeforeAnyRequest,
+				hook.EventTypeBeforeUnauthenticatedRequest,
+				hook.EventTypeAfterAnyRequest,
+				hook.EventTypeAfterUnauthenticatedRequest,
+				hook.EventTypeBeforeLogin,
+				hook.EventTypeAfterLoginFailure,
+			},
+			expectedHookIds:            []string{"after-login-failure"},
+			expectedResponseSource:     SimulationResponseSourceUpstream,
+			expectedResponseStatusCode: http.StatusForbidden,
+		},
+		{
+			name: "rejecting beforeLogin hook",
+			hooksJson: `[
+				{"id": "reject-login", "eventType": "beforeLogin", "action": "reject", "rejectionErrorCode": "M_FORBIDDEN", "rejectionErrorMessage": "No"}
+			]`,
+			simulationRequest: SimulationRequest{Method: "POST", Path: "/_matrix/client/v3/login"},
+			expectedRouteType: SimulationRouteTypeLogin,
+			expectedEventTypes: []string{
+				hook.EventTypeBeforeAnyRequest,
+				hook.EventTypeBeforeUnauthenticatedRequest,
+				hook.EventTypeAfterAnyRequest,
+				hook.EventTypeAfterUnauthenticatedRequest,
+				hook.EventTypeBeforeLogin,
+			},
+			expectedHookIds:            []string{"reject-login"},
+			expectedResponseSource:     SimulationResponseSourceHook,
+			expectedResponseStatusCode: http.StatusForbidden,
+		},
+		{
+			name: "dry run does not consult REST services",
+			hooksJson: `[
+				{"id": "consult", "eventType": "beforeAnyRequest", "action": "consult.RESTServiceURL", "RESTServiceURL": "http://127.0.0.1:1/unreachable"},
+				{"id": "reject", "eventType": "beforeAnyRequest", "action": "reject", "rejectionErrorCode": "M_FORBIDDEN", "rejectionErrorMessage": "No"}
+			]`,
+			simulationRequest: SimulationRequest{Method: "GET", Path: "/_matrix/client/v3/sync", DryRun: true},
+			expectedRouteType: SimulationRouteTypeResponseFiltered,
+			expectedEventTypes: []string{
+				hook.EventTypeBeforeAnyRequest,
+			},
+			expectedHookIds:            []string{"consult", "reject"},
+			expectedResponseSource:     SimulationResponseSourceHook,
+			expectedResponseStatusCode: http.StatusForbidden,
+		},
+		{
+			name:              "internal route",
+			hooksJson:         `[]`,
+			simulationRequest: SimulationRequest{Method: "GET", Path: "/_matrix/corporal/user/@a:host/rest-auth"},
+			expectedError:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var hooks []*hook.Hook
+			err := json.Unmarshal([]byte(tc.hooksJson), &hooks)
+			if err != nil {
+				t.Fatalf("failed parsing hooks: %s", err)
+			}
+
+			hookStore := hook.NewStore()
+
+			roomAliasResolver := policy.NewRoomAliasResolver(logger, nil, time.Minute)
+			policyStore := policy.NewStore(
+				logger,
+				policy.NewValidator("host", hookStore, roomAliasResolver),
+				roomAliasResolver,
+				hookStore,
+			)
+			err = policyStore.Set(&policy.Policy{SchemaVersion: 2, Hooks: hooks})
+			if err != nil {
+				t.Fatalf("failed setting policy: %s", err)
+			}
+
+			simulator := NewSimulator(
+				policyStore,
+				hookStore,
+				hook.NewExecutor(hook.NewRESTServiceConsultor(time.Second)),
+				createTestHandlerRegistrators(),
+			)
+
+			result, err := simulator.Simulate(tc.simulationRequest, logrus.NewEntry(logger))
+
+			if tc.expectedError {
+				if err == nil {
+					t.Fatalf("expected an error, but got a result")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if result.RouteType != tc.expectedRouteType {
+				t.Errorf("expected route type %s, got %s", tc.expectedRouteType, result.RouteType)
+			}
+
+			eventTypes := make([]string, 0, len(result.EventTypes))
+			hookIds := make([]string, 0)
+			for _, eventTypeResult := range result.EventTypes {
+				eventTypes = append(eventTypes, eventTypeResult.EventType)
+				for _, hookResult := range eventTypeResult.Hooks {
+					hookIds = append(hookIds, hookResult.HookId)
+				}
+			}
+			if !reflect.DeepEqual(eventTypes, tc.expectedEventTypes) {
+				t.Errorf("expected event types %v, got %v", tc.expectedEventTypes, eventTypes)
+			}
+
+			expectedHookIds := tc.expectedHookIds
+			if expectedHookIds == nil {
+				expectedHookIds = []string{}
+			}
+			if !reflect.DeepEqual(hookIds, expectedHookIds) {
+				t.Errorf("expected hooks %v to run, got %v", expectedHookIds, hookIds)
+			}
+
+			if result.ResponseSource != tc.expectedResponseSource {
+				t.Errorf("expected response source %s, got %s", tc.expectedResponseSource, result.ResponseSource)
+			}
+
+			if result.Response.StatusCode != tc.expectedResponseStatusCode {
+				t.Errorf("expected response status code %d, got %d (%s)", tc.expectedResponseStatusCode, result.Response.StatusCode, result.Response.Body)
+			}
+		})
+	}
+}
@@ -2,15 +2,18 @@ package interceptor
 
 import (
 	"bytes"
+	"devture-matrix-corporal/corporal/connector"
 	"devture-matrix-corporal/corporal/httphelp"
 	"devture-matrix-corporal/corporal/matrix"
 	"devture-matrix-corporal/corporal/policy"
 	"devture-matrix-corporal/corporal/userauth"
 	"devture-matrix-corporal/corporal/util"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -33,11 +36,15 @@ import (
 // our fake passwords and grant access.
 // Those passwords are verified and trusted through the `matrix-shared-secret-auth` plugin for Synapse
 // and are generated to match via SharedSecretAuthPasswordGenerator.
+//
+// Logins by managed users are also subject to the device restrictions in their policy
+// (`maxDevices`, `allowedDeviceDisplayNamePatterns`).
 type LoginInterceptor struct {
 	policyStore                       *policy.Store
 	homeserverDomainName              string
 	userAuthChecker                   *userauth.Checker
 	sharedSecretAuthPasswordGenerator *matrix.SharedSecretAuthPasswordGenerator
+	connector                         connector.MatrixConnector
 }
 
 func NewLoginInterceptor(
@@ -45,16 +52,38 @@ func NewLoginInterceptor(
 	homeserverDomainName string,
 	userAuthChecker *userauth.Checker,
 	sharedSecretAuthPasswordGenerator *matrix.SharedSecretAuthPasswordGenerator,
+	connector connector.MatrixConnector,
 ) *LoginInterceptor {
 	return &LoginInterceptor{
 		policyStore:                       policyStore,
 		homeserverDomainName:              homeserverDomainName,
 		userAuthChecker:                   userAuthChecker,
 		sharedSecretAuthPasswordGenerator: sharedSecretAuthPasswordGenerator,
+		connector:                         connector,
 	}
 }
 
+// interceptedLoginDetails contains information about the login request being intercepted,
+// which gets gradually discovered while intercepting.
+type interceptedLoginDetails struct {
+	userId    string
+	loginType string
+	authType  string
+}
+
 func (me *LoginInterceptor) Intercept(r *http.Request) InterceptorResponse {
+	details := &interceptedLoginDetails{}
+
+	response := me.intercept(r, details)
+
+	response.UserId = details.userId
+	response.LoginType = details.loginType
+	response.AuthType = details.authType
+
+	return response
+}
+
+func (me *LoginInterceptor) intercept(r *http.Request, details *interceptedLoginDetails) InterceptorResponse {
 	loggingContextFields := logrus.Fields{}
 
 	var payload matrix.ApiLoginRequestPayload
@@ -66,6 +95,7 @@ func (me *LoginInterceptor) Intercept(r *http.Request) InterceptorResponse {
 	}
 
 	loggingContextFields["type"] = payload.Type
+	details.loginType = payload.Type
 
 	if payload.Type == matrix.LoginTypeToken {
 		// This is a Token Authentication request related to SSO (CAS or SAML).
@@ -124,6 +154,7 @@ func (me *LoginInterceptor) Intercept(r *http.Request) InterceptorResponse {
 
 	// Replace the logging field with a (potentially) better one
 	loggingContextFields["userId"] = userIdFull
+	details.userId = userIdFull
 
 	if !matrix.IsFullUserIdOfDomain(userIdFull, me.homeserverDomainName) {
 		return createInterceptorErrorResponse(loggingContextFields, matrix.ErrorForbidden, "Rejecting non-own domains")
@@ -139,7 +170,9 @@ func (me *LoginInterceptor) Intercept(r *http.Request) InterceptorResponse {
 		}
 	}
 
-	if !userPolicy.Active {
+	details.authType = userPolicy.AuthType
+
+	if !userPolicy.IsActiveAt(time.Now()) {
 		return createInterceptorErrorResponse(loggingContextFields, matrix.ErrorUserDeactivated, "Deactivated in policy")
 	}
 
@@ -148,10 +181,21 @@ func (me *LoginInterceptor) Intercept(r *http.Request) InterceptorResponse {
 		// Users are created with an initial password as defined in userPolicy.AuthCredential,
 		// but password-management is then potentially left to the homeserver (depending on policyObj.Flags.AllowCustomPassthroughUserPasswords).
 		// Authentication always happens at the homeserver.
-		return InterceptorResponse{
+		//
+		// Since we don't know whether the credentials are valid, device restrictions can only be enforced once the login succeeds.
+		response := InterceptorResponse{
 			Result:               InterceptorResultProxy,
 			LoggingContextFields: loggingContextFields,
 		}
+
+		if userPolicy.HasDeviceRestrictions() {
+			userPolicyCopy := *userPolicy
+			response.OnLoginSuccess = func(loginResponse *http.Response) error {
+				return me.enforceDeviceRestrictionsAfterLogin(userIdFull, userPolicyCopy, loginResponse)
+			}
+		}
+
+		return response
 	}
 
 	// Authentication for all other auth types is handled by us (below)
@@ -173,6 +217,11 @@ func (me *LoginInterceptor) Intercept(r *http.Request) InterceptorResponse {
 		return createInterceptorErrorResponse(loggingContextFields, matrix.ErrorForbidden, "Failed authentication")
 	}
 
+	response := me.enforceDeviceRestrictions(userIdFull, *userPolicy, payload, loggingContextFields)
+	if response.Result == InterceptorResultDeny {
+		return response
+	}
+
 	// We don't need to do it, but let's ensure the payload uses the full user id.
 	payload.User = userIdFull
 	payload.Password = me.sharedSecretAuthPasswordGenerator.GenerateForUserId(userIdFull)
@@ -185,8 +234,168 @@ func (me *LoginInterceptor) Intercept(r *http.Request) InterceptorResponse {
 	r.Body = io.NopCloser(bytes.NewReader(newBodyBytes))
 	r.ContentLength = int64(len(newBodyBytes))
 
-	return InterceptorResponse{
+	return response
+}
+
+// enforceDeviceRestrictions ensures that the login would not create a device that the user policy does not allow.
+//
+// If the device limit would be exceeded and the user policy says so, the least recently seen devices get logged out
+// to make room for the new one. This only happens after the login succeeds (see InterceptorResponse.OnLoginSuccess),
+// so that failed logins can't be used to log out somebody's devices.
+//
+// This is only meant for logins which we've already authenticated.
+// For others, see enforceDeviceRestrictionsAfterLogin.
+//
+// The returned response is either a denial or one which lets the request be proxied.
+func (me *LoginInterceptor) enforceDeviceRestrictions(
+	userId string,
+	userPolicy policy.UserPolicy,
+	payload matrix.ApiLoginRequestPayload,
+	loggingContextFields logrus.Fields,
+) InterceptorResponse {
+	proxyResponse := InterceptorResponse{
 		Result:               InterceptorResultProxy,
 		LoggingContextFields: loggingContextFields,
 	}
+
+	if !userPolicy.HasDeviceRestrictions() {
+		return proxyResponse
+	}
+
+	devices, err := me.connector.GetUserDevices(userId)
+	if err != nil {
+		loggingContextFields["err"] = err.Error()
+		return createInterceptorErrorResponse(loggingContextFields, matrix.ErrorUnknown, "Internal error while checking devices")
+	}
+
+	if payload.DeviceID != "" {
+		for _, device := range devices {
+			if device.DeviceId == payload.DeviceID {
+				// Logging into an existing device does not create a new one, so there's nothing to check.
+				return proxyResponse
+			}
+		}
+	}
+
+	denialReason, deviceIdsToEvict := checkNewDevice(userPolicy, payload.InitialDeviceDisplayName, devices)
+	if denialReason != "" {
+		loggingContextFields["deviceDisplayName"] = payload.InitialDeviceDisplayName
+		return createInterceptorErrorResponse(loggingContextFields, matrix.ErrorForbidden, denialReason)
+	}
+
+	if len(deviceIdsToEvict) == 0 {
+		return proxyResponse
+	}
+
+	loggingContextFields["deviceIdsToEvict"] = deviceIdsToEvict
+
+	proxyResponse.OnLoginSuccess = func(loginResponse *http.Response) error {
+		return me.connector.DeleteUserDevices(userId, deviceIdsToEvict)
+	}
+
+	return proxyResponse
+}
+
+// enforceDeviceRestrictionsAfterLogin enforces the user policy's device restrictions on a login which the homeserver has already let through.
+//
+// It's used for users authenticated by the homeserver (see userauth.UserAuthTypePassthrough),
+// because checking their devices any earlier would reveal things to people who don't know the password.
+//
+// The device that the login created (or logged into) is checked against the user's other devices.
+// If the login is to be denied, this device gets logged out and the login response gets turned into an error response.
+// Otherwise, devices may get logged out to make room for it (depending on the user policy).
+func (me *LoginInterceptor) enforceDeviceRestrictionsAfterLogin(
+	userId string,
+	userPolicy policy.UserPolicy,
+	loginResponse *http.Response,
+) error {
+	var loginResponsePayload matrix.ApiLoginResponsePayload
+	err := httphelp.GetJsonFromResponseBody(loginResponse, &loginResponsePayload)
+	if err != nil || loginResponsePayload.DeviceId == "" {
+		denyLoginResponse(loginResponse, matrix.ErrorUnknown, "Internal error while checking devices")
+		return fmt.Errorf("could not determine the login's device: %v", err)
+	}
+
+	loginDeviceId := loginResponsePayload.DeviceId
+
+	devices, err := me.connector.GetUserDevices(userId)
+	if err != nil {
+		denyLoginResponse(loginResponse, matrix.ErrorUnknown, "Internal error while checking devices")
+		return me.logOutLoginDevice(userId, loginDeviceId, fmt.Errorf("failed getting devices: %s", err))
+	}
+
+	loginDeviceDisplayName := ""
+	otherDevices := make([]connector.CurrentUserDeviceState, 0, len(devices))
+	for _, device := range devices {
+		if device.DeviceId == loginDeviceId {
+			loginDeviceDisplayName = device.DisplayName
+			continue
+		}
+		otherDevices = append(otherDevices, device)
+	}
+
+	denialReason, deviceIdsToEvict := checkNewDevice(userPolicy, loginDeviceDisplayName, otherDevices)
+	if denialReason != "" {
+		denyLoginResponse(loginResponse, matrix.ErrorForbidden, denialReason)
+		return me.logOutLoginDevice(userId, loginDeviceId, nil)
+	}
+
+	if len(deviceIdsToEvict) == 0 {
+		return nil
+	}
+
+	return me.connector.DeleteUserDevices(userId, deviceIdsToEvict)
+}
+
+// logOutLoginDevice logs out the device created by a login that got denied after the fact.
+// The returned error combines the reason for denying (if any) with the logout failure (if any).
+func (me *LoginInterceptor) logOutLoginDevice(userId string, deviceId string, denialErr error) error {
+	err := me.connector.DeleteUserDevices(userId, []string{deviceId})
+	if err != nil {
+		return errors.Join(denialErr, fmt.Errorf("failed logging out device %s of the denied login: %s", deviceId, err))
+	}
+
+	return denialErr
+}
+
+// checkNewDevice tells whether a new device (having the given display name) can be added to the given devices of a user.
+//
+// It returns the reason for denying the device (empty if it's allowed) and the IDs of the devices
+// which need to be logged out to make room for it (if the device limit is reached and the user policy says so).
+func checkNewDevice(
+	userPolicy policy.UserPolicy,
+	deviceDisplayName string,
+	devices []connector.CurrentUserDeviceState,
+) (string, []string) {
+	if !userPolicy.IsDeviceDisplayNameAllowed(deviceDisplayName) {
+		return "Device display name not allowed", nil
+	}
+
+	if userPolicy.MaxDevices == 0 || len(devices) < userPolicy.MaxDevices {
+		return "", nil
+	}
+
+	if !userPolicy.ShouldEvictOldestDevices() {
+		return "Device limit reached", nil
+	}
+
+	devicesToEvict := connector.DetermineLeastRecentlySeenDevices(devices, len(devices)-userPolicy.MaxDevices+1)
+
+	deviceIdsToEvict := make([]string, 0, len(devicesToEvict))
+	for _, device := range devicesToEvict {
+		deviceIdsToEvict = append(deviceIdsToEvict, device.DeviceId)
+	}
+
+	return "", deviceIdsToEvict
+}
+
+// denyLoginResponse turns a (successful) login response into a Matrix error response.
+func denyLoginResponse(response *http.Response, errorCode string, errorMessage string) {
+	responseBoundWriter := httphelp.NewResponseBoundHttpWriter(response)
+	httphelp.RespondWithMatrixError(responseBoundWriter, http.StatusForbidden, errorCode, errorMessage)
+	responseBoundWriter.Commit()
+
+	// The size of the new body differs from the original one, so it will be sent using chunked transfer encoding.
+	response.ContentLength = -1
+	response.Header.Del("Content-Length")
 }
@@ -0,0 +1,276 @@
+package interceptor
+
+import (
+	"bytes"
+	"devture-matrix-corporal/corporal/connector"
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/policy"
+	"devture-matrix-corporal/corporal/userauth"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testDeviceConnector is a connector which only knows about devices.
+// Calling any other method panics.
+type testDeviceConnector struct {
+	connector.MatrixConnector
+
+	devices               []connector.CurrentUserDeviceState
+	deletedDeviceIds      []string
+	getUserDevicesCounter int
+}
+
+func (me *testDeviceConnector) GetUserDevices(userId string) ([]connector.CurrentUserDeviceState, error) {
+	me.getUserDevicesCounter++
+	return me.devices, nil
+}
+
+func (me *testDeviceConnector) DeleteUserDevices(userId string, deviceIds []string) error {
+	me.deletedDeviceIds = append(me.deletedDeviceIds, deviceIds...)
+	return nil
+}
+
+func TestLoginInterceptorDeviceRestrictions(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	devices := []connector.CurrentUserDeviceState{
+		{DeviceId: "NEWEST", DisplayName: "Element Web", LastSeenTs: 3000},
+		{DeviceId: "OLDEST", DisplayName: "Element Web", LastSeenTs: 1000},
+		{DeviceId: "MIDDLE", DisplayName: "Element Web", LastSeenTs: 2000},
+	}
+
+	type testCase struct {
+		name         string
+		userPolicy   policy.UserPolicy
+		loginPayload matrix.ApiLoginRequestPayload
+
+		expectedResult       InterceptorResult
+		expectedErrorMessage string
+
+		// loginDevice is the device that the homeserver creates once it lets the login through (only relevant for OnLoginSuccess callbacks)
+		loginDevice connector.CurrentUserDeviceState
+
+		// expectedEvictedDeviceIds contains the devices expected to be logged out once the login succeeds (nil means no OnLoginSuccess callback)
+		expectedEvictedDeviceIds []string
+
+		// expectedLoginErrorMessage is the error message that the successful login response is expected to be turned into (if any)
+		expectedLoginErrorMessage string
+	}
+
+	managedUserPolicy := func(authType string, maxDevices int, deviceLimitAction string) policy.UserPolicy {
+		return policy.UserPolicy{
+			Id:                               "@a:host",
+			Active:                           true,
+			AuthType:                         authType,
+			AuthCredential:                   "password",
+			MaxDevices:                       maxDevices,
+			DeviceLimitAction:                deviceLimitAction,
+			AllowedDeviceDisplayNamePatterns: []string{"Element .*"},
+		}
+	}
+
+	loginPayload := func(password string, deviceId string, deviceDisplayName string) matrix.ApiLoginRequestPayload {
+		return matrix.ApiLoginRequestPayload{
+			Type:                     matrix.LoginTypePassword,
+			User:                     "@a:host",
+			Password:                 password,
+			DeviceID:                 deviceId,
+			InitialDeviceDisplayName: deviceDisplayName,
+		}
+	}
+
+	testCases := []testCase{
+		{
+			name:           "below the limit",
+			userPolicy:     managedUserPolicy(userauth.UserAuthTypePlain, 4, policy.DeviceLimitActionDeny),
+			loginPayload:   loginPayload("password", "", "Element Web"),
+			expectedResult: InterceptorResultProxy,
+		},
+		{
+			name:           "existing device",
+			userPolicy:     managedUserPolicy(userauth.UserAuthTypePlain, 3, policy.DeviceLimitActionDeny),
+			loginPayload:   loginPayload("password", "MIDDLE", "Anything"),
+			expectedResult: InterceptorResultProxy,
+		},
+		{
+			name:                 "invalid credentials",
+			userPolicy:           managedUserPolicy(userauth.UserAuthTypePlain, 3, policy.DeviceLimitActionEvictOldest),
+			loginPayload:         loginPayload("wrong", "", "Element Web"),
+			expectedResult:       InterceptorResultDeny,
+			expectedErrorMessage: "Failed authentication",
+		},
+		{
+			name:                 "limit reached (deny)",
+			userPolicy:           managedUserPolicy(userauth.UserAuthTypePlain, 3, policy.DeviceLimitActionDeny),
+			loginPayload:         loginPayload("password", "", "Element Web"),
+			expectedResult:       InterceptorResultDeny,
+			expectedErrorMessage: "Device limit reached",
+		},
+		{
+			name:                     "limit reached (evictOldest)",
+			userPolicy:               managedUserPolicy(userauth.UserAuthTypePlain, 2, policy.DeviceLimitActionEvictOldest),
+			loginPayload:             loginPayload("password", "", "Element Web"),
+			expectedResult:           InterceptorResultProxy,
+			expectedEvictedDeviceIds: []string{"OLDEST", "MIDDLE"},
+		},
+		{
+			name:                 "disallowed device display name",
+			userPolicy:           managedUserPolicy(userauth.UserAuthTypePlain, 0, ""),
+			loginPayload:         loginPayload("password", "", "Some client"),
+			expectedResult:       InterceptorResultDeny,
+			expectedErrorMessage: "Device display name not allowed",
+		},
+		{
+			name:                     "passthrough: below the limit",
+			userPolicy:               managedUserPolicy(userauth.UserAuthTypePassthrough, 4, policy.DeviceLimitActionDeny),
+			loginPayload:             loginPayload("unknown", "", "Element Web"),
+			expectedResult:           InterceptorResultProxy,
+			loginDevice:              connector.CurrentUserDeviceState{DeviceId: "NEW", DisplayName: "Element Web"},
+			expectedEvictedDeviceIds: []string{},
+		},
+		{
+			name:                      "passthrough: limit reached (deny)",
+			userPolicy:                managedUserPolicy(userauth.UserAuthTypePassthrough, 3, policy.DeviceLimitActionDeny),
+			loginPayload:              loginPayload("unknown", "", "Element Web"),
+			expectedResult:            InterceptorResultProxy,
+			loginDevice:               connector.CurrentUserDeviceState{DeviceId: "NEW", DisplayName: "Element Web"},
+			expectedEvictedDeviceIds:  []string{"NEW"},
+			expectedLoginErrorMessage: "Device limit reached",
+		},
+		{
+			name:                      "passthrough: disallowed device display name",
+			userPolicy:                managedUserPolicy(userauth.UserAuthTypePassthrough, 0, ""),
+			loginPayload:              loginPayload("unknown", "", "Some client"),
+			expectedResult:            InterceptorResultProxy,
+			loginDevice:               connector.CurrentUserDeviceState{DeviceId: "NEW", DisplayName: "Some client"},
+			expectedEvictedDeviceIds:  []string{"NEW"},
+			expectedLoginErrorMessage: "Device display name not allowed",
+		},
+		{
+			name:                     "passthrough: limit reached (evictOldest)",
+			userPolicy:               managedUserPolicy(userauth.UserAuthTypePassthrough, 3, policy.DeviceLimitActionEvictOldest),
+			loginPayload:             loginPayload("unknown", "", "Element Web"),
+			expectedResult:           InterceptorResultProxy,
+			loginDevice:              connector.CurrentUserDeviceState{DeviceId: "NEW", DisplayName: "Element Web"},
+			expectedEvictedDeviceIds: []string{"OLDEST"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hookStore := hook.NewStore()
+
+			roomAliasResolver := policy.NewRoomAliasResolver(logger, nil, time.Minute)
+			policyStore := policy.NewStore(
+				logger,
+				policy.NewValidator("host", hookStore, roomAliasResolver),
+				roomAliasResolver,
+				hookStore,
+			)
+
+			userPolicy := tc.userPolicy
+			err := policyStore.Set(&policy.Policy{SchemaVersion: 2, User: []*policy.UserPolicy{&userPolicy}})
+			if err != nil {
+				t.Fatalf("failed setting policy: %s", err)
+			}
+
+			userAuthChecker := userauth.NewChecker()
+			userAuthChecker.RegisterAuthenticator(userauth.NewPlainAuthenticator())
+
+			deviceConnector := &testDeviceConnector{devices: devices}
+
+			loginInterceptor := NewLoginInterceptor(
+				policyStore,
+				"host",
+				userAuthChecker,
+				matrix.NewSharedSecretAuthPasswordGenerator("secret"),
+				deviceConnector,
+			)
+
+			payloadBytes, err := json.Marshal(tc.loginPayload)
+			if err != nil {
+				t.Fatalf("failed serializing login payload: %s", err)
+			}
+
+			request, err := http.NewRequest("POST", "/_matrix/client/v3/login", bytes.NewReader(payloadBytes))
+			if err != nil {
+				t.Fatalf("failed creating request: %s", err)
+			}
+
+			response := loginInterceptor.Intercept(request)
+
+			if response.Result != tc.expectedResult {
+				t.Fatalf("expected result %d, got %d (%s)", tc.expectedResult, response.Result, response.ErrorMessage)
+			}
+
+			if response.ErrorMessage != tc.expectedErrorMessage {
+				t.Errorf("expected error message `%s`, got `%s`", tc.expectedErrorMessage, response.ErrorMessage)
+			}
+
+			if len(deviceConnector.deletedDeviceIds) != 0 {
+				t.Errorf("expected no devices to be logged out before the login succeeds, got: %v", deviceConnector.deletedDeviceIds)
+			}
+
+			if tc.userPolicy.AuthType == userauth.UserAuthTypePassthrough && deviceConnector.getUserDevicesCounter != 0 {
+				t.Errorf("expected devices not to be checked before the homeserver authenticates the user")
+			}
+
+			if tc.expectedEvictedDeviceIds == nil {
+				if response.OnLoginSuccess != nil {
+					t.Errorf("expected no OnLoginSuccess callback")
+				}
+				return
+			}
+
+			if response.OnLoginSuccess == nil {
+				t.Fatalf("expected an OnLoginSuccess callback")
+			}
+
+			// By now, the homeserver has created the device for this login
+			deviceConnector.devices = append(append([]connector.CurrentUserDeviceState{}, devices...), tc.loginDevice)
+
+			loginResponse := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{"user_id": "@a:host", "device_id": "` + tc.loginDevice.DeviceId + `"}`))),
+			}
+
+			err = response.OnLoginSuccess(loginResponse)
+			if err != nil {
+				t.Fatalf("unexpected OnLoginSuccess error: %s", err)
+			}
+
+			if len(deviceConnector.deletedDeviceIds) != 0 || len(tc.expectedEvictedDeviceIds) != 0 {
+				if !reflect.DeepEqual(deviceConnector.deletedDeviceIds, tc.expectedEvictedDeviceIds) {
+					t.Errorf("expected devices %v to be logged out, got %v", tc.expectedEvictedDeviceIds, deviceConnector.deletedDeviceIds)
+				}
+			}
+
+			var loginResponsePayload map[string]interface{}
+			err = httphelp.GetJsonFromResponseBody(loginResponse, &loginResponsePayload)
+			if err != nil {
+				t.Fatalf("failed parsing login response: %s", err)
+			}
+
+			if tc.expectedLoginErrorMessage == "" {
+				if loginResponse.StatusCode != http.StatusOK {
+					t.Errorf("expected the login response to remain successful, got %d: %v", loginResponse.StatusCode, loginResponsePayload)
+				}
+				return
+			}
+
+			if loginResponse.StatusCode != http.StatusForbidden || loginResponsePayload["errcode"] != matrix.ErrorForbidden || loginResponsePayload["error"] != tc.expectedLoginErrorMessage {
+				t.Errorf("expected the login response to be denied with `%s`, got %d: %v", tc.expectedLoginErrorMessage, loginResponse.StatusCode, loginResponsePayload)
+			}
+		})
+	}
+}
@@ -20,6 +20,21 @@ type InterceptorResponse struct {
 
 	ErrorCode    string
 	ErrorMessage string
+
+	// UserId is the full Matrix user ID that the request was determined to be about (empty if it could not be determined).
+	UserId string
+
+	// LoginType is the login type (e.g. `m.login.password`) of intercepted login requests.
+	LoginType string
+
+	// AuthType is the policy-defined authentication type (see `userauth.UserAuthType*`) of the user that the request is about.
+	// It's empty for users which are not managed by the policy.
+	AuthType string
+
+	// OnLoginSuccess (if set) is to be called with the response of the proxied login request, once it succeeds.
+	// It's used for work which should only happen for successful logins (like logging out excess devices).
+	// It may also turn the response into an error response, denying the login after the fact.
+	OnLoginSuccess func(loginResponse *http.Response) error
 }
 
 type Interceptor interface {
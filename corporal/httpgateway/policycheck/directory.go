@@ -0,0 +1,60 @@
+package policycheck
+
+import (
+	"context"
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/policy"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// CheckDirectoryRoomVisibilityChange is a policy checker for: /_matrix/client/{apiVersion:(r0|v3)}/directory/list/room/{roomId}
+func CheckDirectoryRoomVisibilityChange(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	userId := ctx.Value("userId").(string)
+
+	var payload struct {
+		Visibility string `json:"visibility"`
+	}
+	err := httphelp.GetJsonFromRequestBody(r, &payload)
+	if err != nil {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorBadJson,
+			ErrorMessage: err.Error(),
+		}
+	}
+
+	// Removing rooms from the directory (`visibility=private`) is always allowed.
+	if payload.Visibility == "public" && !checker.CanUserPublishRoomToDirectory(policy, userId) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: "Denied by policy (cannot publish rooms to the room directory)",
+		}
+	}
+
+	return PolicyCheckResponse{
+		Allow: true,
+	}
+}
+
+// CheckDirectoryAliasCreate is a policy checker for: /_matrix/client/{apiVersion:(r0|v3)}/directory/room/{roomAlias}
+func CheckDirectoryAliasCreate(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	userId := ctx.Value("userId").(string)
+	roomAlias := mux.Vars(r)["roomAlias"]
+
+	if !checker.CanUserCreateAlias(policy, userId, roomAlias) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: fmt.Sprintf("Denied by policy (cannot create alias %s)", roomAlias),
+		}
+	}
+
+	return PolicyCheckResponse{
+		Allow: true,
+	}
+}
@@ -0,0 +1,142 @@
+package policycheck
+
+import (
+	"bytes"
+	"context"
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/policy"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// contentSniffingBytesCount is the number of bytes that http.DetectContentType considers
+const contentSniffingBytesCount = 512
+
+// CheckMediaUpload is a policy checker for:
+// - /_matrix/media/{apiVersion:(r0|v3)}/upload
+// - /_matrix/media/{apiVersion:(r0|v3)}/upload/{serverName}/{mediaId}
+func CheckMediaUpload(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	userId := ctx.Value("userId").(string)
+
+	if !checker.CanUserUploadMedia(policy, userId) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: "Denied by policy (cannot upload media)",
+		}
+	}
+
+	maxSizeBytes := checker.GetUserMediaUploadMaxSizeBytes(policy, userId)
+	if maxSizeBytes != 0 {
+		if r.ContentLength == -1 {
+			// The size is unknown (chunked transfer encoding).
+			// Rather than reading (and holding onto) the whole body to find out, we make reading fail
+			// if the limit is exceeded while the body is being proxied.
+			httphelp.LimitRequestBody(r, maxSizeBytes)
+		} else if !checker.CanUserUploadMediaOfSize(policy, userId, r.ContentLength) {
+			return PolicyCheckResponse{
+				Allow:        false,
+				ErrorCode:    matrix.ErrorTooLarge,
+				ErrorMessage: fmt.Sprintf("Denied by policy (media cannot be larger than %d bytes)", maxSizeBytes),
+			}
+		}
+	}
+
+	// The content type the client claims needs to be allowed..
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	if !checker.CanUserUploadMediaOfContentType(policy, userId, contentType) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: fmt.Sprintf("Denied by policy (cannot upload media of type %s)", contentType),
+		}
+	}
+
+	// .. and so does the content type we detect by looking at the content itself.
+	peekedBytes, err := httphelp.PeekRequestBody(r, contentSniffingBytesCount)
+	if err != nil {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorUnknown,
+			ErrorMessage: err.Error(),
+		}
+	}
+
+	sniffedContentType := detectMediaContentType(peekedBytes)
+
+	if isTextualContentType(sniffedContentType) && isTextualContentType(contentType) {
+		// Text-based formats (SVG images, JSON documents, etc.) are only detected as generic text,
+		// so we rely on the reported content type for them.
+		return PolicyCheckResponse{
+			Allow: true,
+		}
+	}
+
+	// Content which cannot be recognized is detected as `application/octet-stream`, which then needs to be allowed in its own right.
+	// Otherwise, an unrecognized file (like an executable) could get through by claiming to be of some allowed type (like `image/png`).
+	if !checker.CanUserUploadMediaOfContentType(policy, userId, sniffedContentType) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: fmt.Sprintf("Denied by policy (cannot upload media detected to be of type %s)", sniffedContentType),
+		}
+	}
+
+	return PolicyCheckResponse{
+		Allow: true,
+	}
+}
+
+// mediaContentTypeSignatures contains signatures (file prefixes) of formats that http.DetectContentType does not recognize.
+// Executables are detected, so that they cannot pass as some other (allowed) type.
+// Images produced by phones (HEIC, HEIF) and AVIF images are detected, so that they can pass as images.
+var mediaContentTypeSignatures = []struct {
+	offset      int
+	signature   string
+	contentType string
+}{
+	{offset: 0, signature: "MZ", contentType: "application/vnd.microsoft.portable-executable"},
+	{offset: 0, signature: "\x7fELF", contentType: "application/x-executable"},
+	{offset: 0, signature: "\xfe\xed\xfa\xce", contentType: "application/x-mach-binary"},
+	{offset: 0, signature: "\xfe\xed\xfa\xcf", contentType: "application/x-mach-binary"},
+	{offset: 0, signature: "\xce\xfa\xed\xfe", contentType: "application/x-mach-binary"},
+	{offset: 0, signature: "\xcf\xfa\xed\xfe", contentType: "application/x-mach-binary"},
+	{offset: 4, signature: "ftypheic", contentType: "image/heic"},
+	{offset: 4, signature: "ftypheix", contentType: "image/heic"},
+	{offset: 4, signature: "ftypmif1", contentType: "image/heif"},
+	{offset: 4, signature: "ftypmsf1", contentType: "image/heif-sequence"},
+	{offset: 4, signature: "ftypavif", contentType: "image/avif"},
+}
+
+// detectMediaContentType detects the content type of the given content (its first contentSniffingBytesCount bytes).
+// It extends http.DetectContentType with some more formats (see mediaContentTypeSignatures).
+func detectMediaContentType(content []byte) string {
+	for _, signature := range mediaContentTypeSignatures {
+		if bytes.HasPrefix(content[min(signature.offset, len(content)):], []byte(signature.signature)) {
+			return signature.contentType
+		}
+	}
+
+	return http.DetectContentType(content)
+}
+
+// isTextualContentType tells whether the given content type is for some text-based format
+func isTextualContentType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+
+	if strings.HasSuffix(mediaType, "+xml") || strings.HasSuffix(mediaType, "+json") {
+		return true
+	}
+
+	return mediaType == "application/xml" || mediaType == "application/json"
+}
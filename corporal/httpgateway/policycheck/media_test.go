@@ -0,0 +1,92 @@
+package policycheck
+
+import (
+	"bytes"
+	"context"
+	"devture-matrix-corporal/corporal/policy"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckMediaUploadContentTypes(t *testing.T) {
+	policyObj := policy.Policy{
+		SchemaVersion: 2,
+		Flags: policy.PolicyFlags{
+			MediaUploadAllowedContentTypes: []string{"image/*"},
+		},
+	}
+
+	pngContent := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	type testCase struct {
+		name          string
+		contentType   string
+		content       []byte
+		expectedAllow bool
+	}
+
+	testCases := []testCase{
+		{
+			name:          "image",
+			contentType:   "image/png",
+			content:       pngContent,
+			expectedAllow: true,
+		},
+		{
+			name:          "image reported with a disallowed type",
+			contentType:   "application/pdf",
+			content:       pngContent,
+			expectedAllow: false,
+		},
+		{
+			name:          "Windows executable labelled as an image",
+			contentType:   "image/png",
+			content:       []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff\x00\x00"),
+			expectedAllow: false,
+		},
+		{
+			name:          "Linux executable labelled as an image",
+			contentType:   "image/png",
+			content:       []byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00"),
+			expectedAllow: false,
+		},
+		{
+			name:          "unrecognized content labelled as an image",
+			contentType:   "image/png",
+			content:       []byte("\x00\x01\x02\x03\x04\x05\x06\x07"),
+			expectedAllow: false,
+		},
+		{
+			name:          "HEIC image",
+			contentType:   "image/heic",
+			content:       []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"),
+			expectedAllow: true,
+		},
+		{
+			name:          "SVG image (detected as text)",
+			contentType:   "image/svg+xml",
+			content:       []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`),
+			expectedAllow: true,
+		},
+		{
+			name:          "text labelled as a binary image",
+			contentType:   "image/png",
+			content:       []byte("Hello world"),
+			expectedAllow: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest("POST", "/_matrix/media/v3/upload", bytes.NewReader(tc.content))
+			request.Header.Set("Content-Type", tc.contentType)
+
+			ctx := context.WithValue(request.Context(), "userId", "@a:host") //nolint:staticcheck
+
+			response := CheckMediaUpload(request, ctx, policyObj, *policy.NewChecker())
+			if response.Allow != tc.expectedAllow {
+				t.Errorf("expected allow = %t, got %t (%s)", tc.expectedAllow, response.Allow, response.ErrorMessage)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"devture-matrix-corporal/corporal/httphelp"
 	"devture-matrix-corporal/corporal/matrix"
 	"devture-matrix-corporal/corporal/policy"
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -59,6 +60,86 @@ func CheckRoomCreate(r *http.Request, ctx context.Context, policy policy.Policy,
 		}
 	}
 
+	if creationRequest.Visibility == "public" && !checker.CanUserPublishRoomToDirectory(policy, userId) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: "Denied by policy (cannot publish rooms to the room directory)",
+		}
+	}
+
+	if creationRequest.RoomAliasName != "" {
+		// Aliases are always created on the user's own server (the home domain).
+		alias := fmt.Sprintf("#%s:%s", creationRequest.RoomAliasName, matrix.GetDomainFromIdentifier(userId))
+
+		if !checker.CanUserCreateAlias(policy, userId, alias) {
+			return PolicyCheckResponse{
+				Allow:        false,
+				ErrorCode:    matrix.ErrorForbidden,
+				ErrorMessage: fmt.Sprintf("Denied by policy (cannot create alias %s)", alias),
+			}
+		}
+	}
+
+	if creationRequest.IsDirect {
+		response := checkDirectMessageRoomCreate(creationRequest, userId, policy, checker)
+		if !response.Allow {
+			return response
+		}
+	}
+
+	for _, inviteeId := range creationRequest.Invite {
+		if !checker.CanUserInviteUser(policy, userId, inviteeId) {
+			return PolicyCheckResponse{
+				Allow:        false,
+				ErrorCode:    matrix.ErrorForbidden,
+				ErrorMessage: fmt.Sprintf("Denied by policy (cannot invite %s)", inviteeId),
+			}
+		}
+	}
+
+	if len(creationRequest.Invite3PID) != 0 && !checker.CanUserInviteThirdParties(policy, userId) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: "Denied by policy (cannot invite third-party identifiers)",
+		}
+	}
+
+	return PolicyCheckResponse{
+		Allow: true,
+	}
+}
+
+// checkDirectMessageRoomCreate checks whether the direct message room (`is_direct`) creation request complies with the direct message restrictions.
+// Invite restrictions are checked separately.
+func checkDirectMessageRoomCreate(creationRequest gomatrix.ReqCreateRoom, userId string, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	if !checker.CanUserCreateDirectMessageRoom(policy, userId) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: "Denied by policy (cannot create direct message rooms)",
+		}
+	}
+
+	for _, counterpartId := range creationRequest.Invite {
+		if !checker.CanUserDirectMessageUser(policy, userId, counterpartId) {
+			return PolicyCheckResponse{
+				Allow:        false,
+				ErrorCode:    matrix.ErrorForbidden,
+				ErrorMessage: fmt.Sprintf("Denied by policy (cannot create direct message rooms with %s)", counterpartId),
+			}
+		}
+	}
+
+	if len(creationRequest.Invite3PID) != 0 && !checker.CanUserDirectMessageThirdParties(policy, userId) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: "Denied by policy (cannot create direct message rooms with third-party identifiers)",
+		}
+	}
+
 	return PolicyCheckResponse{
 		Allow: true,
 	}
@@ -81,6 +162,41 @@ func CheckRoomEncryptionStateChange(r *http.Request, ctx context.Context, policy
 	}
 }
 
+// CheckRoomPowerLevelsStateChange is a policy checker for: /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/state/m.room.power_levels
+func CheckRoomPowerLevelsStateChange(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	userId := ctx.Value("userId").(string)
+	roomId := mux.Vars(r)["roomId"]
+
+	if policy.GetRoomPowerLevelsByRoomId(roomId) == nil {
+		// Not a room whose power levels we manage. No need to look at the payload.
+		return PolicyCheckResponse{
+			Allow: true,
+		}
+	}
+
+	var payload matrix.RoomPowerLevelsContent
+	err := httphelp.GetJsonFromRequestBody(r, &payload)
+	if err != nil {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorBadJson,
+			ErrorMessage: err.Error(),
+		}
+	}
+
+	if !checker.CanUserSetRoomPowerLevels(policy, userId, roomId, payload) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: "Denied by policy (power levels deviate from the ones the policy defines for this room)",
+		}
+	}
+
+	return PolicyCheckResponse{
+		Allow: true,
+	}
+}
+
 // CheckRoomSendEvent is a policy checker for: /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/send/{eventType}/{txnId}
 func CheckRoomSendEvent(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
 	userId := ctx.Value("userId").(string)
@@ -126,7 +242,28 @@ func CheckRoomMembershipStateChange(r *http.Request, ctx context.Context, policy
 
 	if userId != memberId {
 		// Someone is trying to update the membership details of another member.
-		// Let it go through and let the upstream server's policies apply, whatever they may be.
+		// Setting `membership=invite` is yet another way to invite someone, so it's subject to invite restrictions.
+		// Anything else goes through and the upstream server's policies apply, whatever they may be.
+		var payload struct {
+			Membership string `json:"membership"`
+		}
+		err := httphelp.GetJsonFromRequestBody(r, &payload)
+		if err != nil {
+			return PolicyCheckResponse{
+				Allow:        false,
+				ErrorCode:    matrix.ErrorBadJson,
+				ErrorMessage: err.Error(),
+			}
+		}
+
+		if payload.Membership == "invite" && !checker.CanUserInviteUser(policy, userId, memberId) {
+			return PolicyCheckResponse{
+				Allow:        false,
+				ErrorCode:    matrix.ErrorForbidden,
+				ErrorMessage: fmt.Sprintf("Denied by policy (cannot invite %s)", memberId),
+			}
+		}
+
 		return PolicyCheckResponse{
 			Allow: true,
 		}
@@ -134,7 +271,7 @@ func CheckRoomMembershipStateChange(r *http.Request, ctx context.Context, policy
 
 	// Someone is trying to modify their own membership state.
 	//
-	// This may be an attempt to leave (or may even possibly join?) the room,
+	// This may be an attempt to leave, join or knock on the room,
 	// but it may also be an attempt to change one's in-room avatar or name.
 	//
 	// Let's forbid all of these.
@@ -146,6 +283,23 @@ func CheckRoomMembershipStateChange(r *http.Request, ctx context.Context, policy
 		}
 	}
 
+	var payload struct {
+		Membership string `json:"membership"`
+	}
+	err := httphelp.GetJsonFromRequestBody(r, &payload)
+	if err != nil {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorBadJson,
+			ErrorMessage: err.Error(),
+		}
+	}
+
+	// Joining (or knocking) this way is subject to the same join restrictions as the dedicated join APIs.
+	if payload.Membership == matrix.MembershipJoin || payload.Membership == matrix.MembershipKnock {
+		return checkRoomJoinDomains(policy, checker, userId, []string{matrix.GetDomainFromIdentifier(roomId)})
+	}
+
 	return PolicyCheckResponse{
 		Allow: true,
 	}
@@ -193,3 +347,117 @@ func CheckRoomKick(r *http.Request, ctx context.Context, policy policy.Policy, c
 		Allow: true,
 	}
 }
+
+// CheckRoomInvite is a policy checker for: /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/invite
+func CheckRoomInvite(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	userId := ctx.Value("userId").(string)
+
+	// This API is used both for inviting Matrix users (`user_id`) and third-party identifiers (`id_server`, `medium`, `address`).
+	var payload struct {
+		UserID string `json:"user_id"`
+	}
+	err := httphelp.GetJsonFromRequestBody(r, &payload)
+	if err != nil {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorBadJson,
+			ErrorMessage: err.Error(),
+		}
+	}
+
+	if payload.UserID == "" {
+		if !checker.CanUserInviteThirdParties(policy, userId) {
+			return PolicyCheckResponse{
+				Allow:        false,
+				ErrorCode:    matrix.ErrorForbidden,
+				ErrorMessage: "Denied by policy (cannot invite third-party identifiers)",
+			}
+		}
+
+		return PolicyCheckResponse{
+			Allow: true,
+		}
+	}
+
+	if !checker.CanUserInviteUser(policy, userId, payload.UserID) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: fmt.Sprintf("Denied by policy (cannot invite %s)", payload.UserID),
+		}
+	}
+
+	return PolicyCheckResponse{
+		Allow: true,
+	}
+}
+
+// CheckRoomJoin is a policy checker for:
+// - /_matrix/client/{apiVersion:(r0|v3)}/join/{roomIdOrAlias}
+// - /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/join
+// - /_matrix/client/{apiVersion:(r0|v3)}/knock/{roomIdOrAlias}
+func CheckRoomJoin(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	userId := ctx.Value("userId").(string)
+
+	roomIdOrAlias, exists := mux.Vars(r)["roomIdOrAlias"]
+	if !exists {
+		roomIdOrAlias = mux.Vars(r)["roomId"]
+	}
+
+	// The room may be reached through its own server, but also through any of the server names the client specifies.
+	// `server_name` is the legacy name of the `via` parameter.
+	domains := []string{
+		matrix.GetDomainFromIdentifier(roomIdOrAlias),
+	}
+	domains = append(domains, r.URL.Query()["server_name"]...)
+	domains = append(domains, r.URL.Query()["via"]...)
+
+	return checkRoomJoinDomains(policy, checker, userId, domains)
+}
+
+// checkRoomJoinDomains ensures that the user can join rooms on all the given domains (related to a join or knock request)
+func checkRoomJoinDomains(policy policy.Policy, checker policy.Checker, userId string, domains []string) PolicyCheckResponse {
+	for _, domain := range domains {
+		if domain == "" {
+			// Newer room versions have room ids without a domain part.
+			continue
+		}
+
+		if !checker.CanUserJoinRoomOnDomain(policy, userId, domain) {
+			return PolicyCheckResponse{
+				Allow:        false,
+				ErrorCode:    matrix.ErrorForbidden,
+				ErrorMessage: fmt.Sprintf("Denied by policy (cannot join rooms on %s)", domain),
+			}
+		}
+	}
+
+	return PolicyCheckResponse{
+		Allow: true,
+	}
+}
+
+// CheckRoomUpgrade is a policy checker for: /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/upgrade
+func CheckRoomUpgrade(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	userId := ctx.Value("userId").(string)
+	roomId := mux.Vars(r)["roomId"]
+
+	if !checker.CanUserUpgradeRoom(policy, userId, roomId) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: "Denied by policy (cannot upgrade managed rooms)",
+		}
+	}
+
+	return PolicyCheckResponse{
+		Allow: true,
+	}
+}
+
+// CheckRoomTombstoneStateChange is a policy checker for: /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/state/m.room.tombstone
+//
+// Upgrading a room is what normally sends a tombstone event, but it can also be sent directly.
+func CheckRoomTombstoneStateChange(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	return CheckRoomUpgrade(r, ctx, policy, checker)
+}
@@ -6,6 +6,8 @@ import (
 	"devture-matrix-corporal/corporal/policy"
 	"devture-matrix-corporal/corporal/userauth"
 	"net/http"
+
+	"github.com/gorilla/mux"
 )
 
 // CheckUserDeactivate is a policy checker for: /_matrix/client/{apiVersion:(r0|v3)}/account/deactivate
@@ -28,6 +30,43 @@ func CheckUserDeactivate(r *http.Request, ctx context.Context, policy policy.Pol
 	}
 }
 
+// CheckUser3pidChange is a policy checker for the 3pid management APIs: /_matrix/client/{apiVersion:(r0|v3)}/account/3pid/*
+func CheckUser3pidChange(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	userId := ctx.Value("userId").(string)
+
+	if !checker.CanUserChange3pids(policy, userId) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: "Denied: 3pid changes are not allowed",
+		}
+	}
+
+	return PolicyCheckResponse{
+		Allow: true,
+	}
+}
+
+// CheckUserPushRuleChange is a policy checker for: /_matrix/client/{apiVersion:(r0|v3)}/pushrules/global/{kind}/{ruleId}
+// (as well as for the `/actions` and `/enabled` sub-resources)
+func CheckUserPushRuleChange(r *http.Request, ctx context.Context, policy policy.Policy, checker policy.Checker) PolicyCheckResponse {
+	userId := ctx.Value("userId").(string)
+	kind := mux.Vars(r)["kind"]
+	ruleId := mux.Vars(r)["ruleId"]
+
+	if !checker.CanUserChangePushRule(policy, userId, kind, ruleId) {
+		return PolicyCheckResponse{
+			Allow:        false,
+			ErrorCode:    matrix.ErrorForbidden,
+			ErrorMessage: "Denied: this push rule is enforced by the policy",
+		}
+	}
+
+	return PolicyCheckResponse{
+		Allow: true,
+	}
+}
+
 // CheckUserSetPassword is a policy checker for: /_matrix/client/{apiVersion:(r0|v3)}/account/password
 func CheckUserSetPassword(r *http.Request, ctx context.Context, policyObj policy.Policy, checker policy.Checker) PolicyCheckResponse {
 	userIdOrNil := ctx.Value("userId")
@@ -0,0 +1,129 @@
+package responsefilter
+
+import (
+	"devture-matrix-corporal/corporal/httphelp"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/policy"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ResponseFilterFunc removes whatever the given user is not allowed to see from a (decoded) JSON response payload.
+//
+// It returns the filtered payload, or nil if the response should be hidden altogether
+// (in which case a "not found" error is delivered to the user instead).
+type ResponseFilterFunc func(payload interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{}
+
+// FilterResponse filters a successful JSON response using the given filter function.
+//
+// The whole upstream body is decoded into memory (filtering needs the complete payload),
+// but it's not additionally buffered as raw bytes. The filtered payload is encoded into the new response body
+// as it's being read by the client.
+//
+// Numbers are preserved as-is (not converted to float64, which would lose precision for large integers)
+// and HTML characters (`<`, `>`, `&`) are not escaped, so untouched parts of the payload are re-encoded faithfully.
+func FilterResponse(
+	response *http.Response,
+	filterFunc ResponseFilterFunc,
+	policy policy.Policy,
+	checker policy.Checker,
+	userId string,
+) error {
+	if response.StatusCode != http.StatusOK {
+		// Errors don't contain anything worth filtering.
+		return nil
+	}
+
+	var payload interface{}
+	err := httphelp.DecodeJsonPreservingNumbers(response.Body, &payload)
+	_ = response.Body.Close()
+	if err != nil {
+		return fmt.Errorf("cannot understand response body payload (not JSON): %s", err)
+	}
+
+	payload = filterFunc(payload, policy, checker, userId)
+	if payload == nil {
+		response.StatusCode = http.StatusNotFound
+		response.Status = fmt.Sprintf("%d %s", http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		payload = map[string]interface{}{
+			"errcode": matrix.ErrorNotFound,
+			"error":   "Not found",
+		}
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		pipeWriter.CloseWithError(httphelp.EncodeJsonPreservingHtml(pipeWriter, payload))
+	}()
+
+	response.Body = pipeReader
+
+	// The size of the new body is unknown until it's fully encoded, so it will be sent using chunked transfer encoding.
+	response.ContentLength = -1
+	response.Header.Del("Content-Length")
+
+	return nil
+}
+
+// HideResponse is a response filter which hides the whole response.
+//
+// It's used for APIs which would reveal hidden rooms or events and which are not worth filtering
+// (deprecated APIs, or ones whose responses cannot be filtered reliably).
+func HideResponse(payload interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	return nil
+}
+
+// filterEventsList returns a copy of the given list of events, which only contains events the user is allowed to see
+func filterEventsList(events []interface{}, policy policy.Policy, checker policy.Checker, userId string) []interface{} {
+	filtered := make([]interface{}, 0, len(events))
+
+	for _, event := range events {
+		if !isEventVisible(event, policy, checker, userId) {
+			continue
+		}
+		filtered = append(filtered, event)
+	}
+
+	return filtered
+}
+
+// filterEventsListAtKey filters the list of events found at the given key of the container (if any)
+func filterEventsListAtKey(container map[string]interface{}, key string, policy policy.Policy, checker policy.Checker, userId string) {
+	events, ok := container[key].([]interface{})
+	if !ok {
+		return
+	}
+
+	container[key] = filterEventsList(events, policy, checker, userId)
+}
+
+// filterEventsObjectAtKey filters the `events` list found in an object at the given key of the container (if any).
+// This is the `{"events": [..]}` structure that `/sync` uses for timeline, state, account data, etc.
+func filterEventsObjectAtKey(container map[string]interface{}, key string, policy policy.Policy, checker policy.Checker, userId string) {
+	object, ok := container[key].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	filterEventsListAtKey(object, "events", policy, checker, userId)
+}
+
+func isEventVisible(event interface{}, policy policy.Policy, checker policy.Checker, userId string) bool {
+	eventMap, ok := event.(map[string]interface{})
+	if !ok {
+		return true
+	}
+
+	if roomId, ok := eventMap["room_id"].(string); ok && !checker.CanUserSeeRoom(policy, userId, roomId) {
+		// Most APIs return events of a single (already checked) room, but some (like `/search`) do not.
+		return false
+	}
+
+	eventType, ok := eventMap["type"].(string)
+	if !ok {
+		return true
+	}
+
+	return checker.CanUserSeeEventType(policy, userId, eventType)
+}
@@ -0,0 +1,160 @@
+package responsefilter
+
+import (
+	"devture-matrix-corporal/corporal/policy"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResponseFilters(t *testing.T) {
+	policyObj := policy.Policy{
+		Flags: policy.PolicyFlags{
+			HiddenRoomIds:    []string{"!hidden:host"},
+			HiddenEventTypes: []string{"m.call.*"},
+		},
+	}
+	checker := policy.NewChecker()
+
+	type testCase struct {
+		name       string
+		filterFunc ResponseFilterFunc
+		payload    string
+		expected   string
+	}
+
+	testCases := []testCase{
+		{
+			name:       "room state loses hidden event types",
+			filterFunc: FilterRoomStateResponse,
+			payload:    `[{"type": "m.room.name"}, {"type": "m.call.invite"}]`,
+			expected:   `[{"type": "m.room.name"}]`,
+		},
+		{
+			name:       "visible event is kept",
+			filterFunc: FilterRoomEventResponse,
+			payload:    `{"type": "m.room.message", "room_id": "!visible:host"}`,
+			expected:   `{"type": "m.room.message", "room_id": "!visible:host"}`,
+		},
+		{
+			name:       "hidden event is hidden",
+			filterFunc: FilterRoomEventResponse,
+			payload:    `{"type": "m.call.answer", "room_id": "!visible:host"}`,
+			expected:   `null`,
+		},
+		{
+			name:       "chunk loses hidden event types",
+			filterFunc: FilterRoomChunkResponse,
+			payload:    `{"chunk": [{"type": "m.call.invite"}, {"type": "m.room.message"}], "next_batch": "x"}`,
+			expected:   `{"chunk": [{"type": "m.room.message"}], "next_batch": "x"}`,
+		},
+		{
+			name:       "hierarchy loses hidden rooms and references to them",
+			filterFunc: FilterRoomHierarchyResponse,
+			payload: `{"rooms": [
+				{"room_id": "!space:host", "children_state": [
+					{"type": "m.space.child", "state_key": "!hidden:host"},
+					{"type": "m.space.child", "state_key": "!visible:host"}
+				]},
+				{"room_id": "!hidden:host", "children_state": []},
+				{"room_id": "!visible:host", "children_state": []}
+			]}`,
+			expected: `{"rooms": [
+				{"room_id": "!space:host", "children_state": [
+					{"type": "m.space.child", "state_key": "!visible:host"}
+				]},
+				{"room_id": "!visible:host", "children_state": []}
+			]}`,
+		},
+		{
+			name:       "search loses results from hidden rooms",
+			filterFunc: FilterSearchResponse,
+			payload: `{"search_categories": {"room_events": {
+				"results": [
+					{"rank": 1, "result": {"type": "m.room.message", "room_id": "!hidden:host"}},
+					{"rank": 2, "result": {"type": "m.room.message", "room_id": "!visible:host"}}
+				],
+				"state": {"!hidden:host": [], "!visible:host": []},
+				"groups": {"room_id": {"!hidden:host": {}, "!visible:host": {}}}
+			}}}`,
+			expected: `{"search_categories": {"room_events": {
+				"results": [
+					{"rank": 2, "result": {"type": "m.room.message", "room_id": "!visible:host"}}
+				],
+				"state": {"!visible:host": []},
+				"groups": {"room_id": {"!visible:host": {}}}
+			}}}`,
+		},
+		{
+			name:       "notifications lose hidden rooms",
+			filterFunc: FilterNotificationsResponse,
+			payload: `{"notifications": [
+				{"room_id": "!hidden:host", "event": {"type": "m.room.message"}},
+				{"room_id": "!visible:host", "event": {"type": "m.room.message"}}
+			]}`,
+			expected: `{"notifications": [
+				{"room_id": "!visible:host", "event": {"type": "m.room.message"}}
+			]}`,
+		},
+		{
+			name:       "whole response is hidden",
+			filterFunc: HideResponse,
+			payload:    `{"anything": true}`,
+			expected:   `null`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var payload interface{}
+			err := json.Unmarshal([]byte(testCase.payload), &payload)
+			if err != nil {
+				t.Fatalf("bad payload: %s", err)
+			}
+
+			var expected interface{}
+			err = json.Unmarshal([]byte(testCase.expected), &expected)
+			if err != nil {
+				t.Fatalf("bad expectation: %s", err)
+			}
+
+			filtered := testCase.filterFunc(payload, policyObj, *checker, "@user:host")
+
+			if !reflect.DeepEqual(filtered, expected) {
+				filteredBytes, _ := json.Marshal(filtered)
+				t.Errorf("unexpected result: %s", filteredBytes)
+			}
+		})
+	}
+}
+
+func TestFilterResponsePreservesNumbersAndHtml(t *testing.T) {
+	body := `{"chunk": [{"type": "m.room.message", "origin_server_ts": 9007199254740993, "content": {"body": "<b>&</b>"}}]}`
+
+	response := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	err := FilterResponse(response, FilterRoomChunkResponse, policy.Policy{}, *policy.NewChecker(), "@user:host")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	filteredBytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	filtered := strings.TrimSpace(string(filteredBytes))
+	if !strings.Contains(filtered, "9007199254740993") {
+		t.Errorf("large integer lost precision: %s", filtered)
+	}
+	if !strings.Contains(filtered, "<b>&</b>") {
+		t.Errorf("HTML characters got escaped: %s", filtered)
+	}
+}
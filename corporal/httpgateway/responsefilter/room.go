@@ -0,0 +1,154 @@
+package responsefilter
+
+import (
+	"devture-matrix-corporal/corporal/policy"
+)
+
+// FilterRoomMessagesResponse is a response filter for: /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/messages
+//
+// Room visibility is expected to have been checked before the request was sent upstream.
+func FilterRoomMessagesResponse(payloadRaw interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	payload, ok := payloadRaw.(map[string]interface{})
+	if !ok {
+		return payloadRaw
+	}
+
+	filterEventsListAtKey(payload, "chunk", policy, checker, userId)
+	filterEventsListAtKey(payload, "state", policy, checker, userId)
+
+	return payload
+}
+
+// FilterRoomContextResponse is a response filter for: /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/context/{eventId}
+//
+// Room visibility is expected to have been checked before the request was sent upstream.
+func FilterRoomContextResponse(payloadRaw interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	payload, ok := payloadRaw.(map[string]interface{})
+	if !ok {
+		return payloadRaw
+	}
+
+	if event, exists := payload["event"]; exists && !isEventVisible(event, policy, checker, userId) {
+		delete(payload, "event")
+	}
+
+	filterEventsListAtKey(payload, "events_before", policy, checker, userId)
+	filterEventsListAtKey(payload, "events_after", policy, checker, userId)
+	filterEventsListAtKey(payload, "state", policy, checker, userId)
+
+	return payload
+}
+
+// FilterRoomStateResponse is a response filter for: /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/state
+//
+// Room visibility is expected to have been checked before the request was sent upstream.
+func FilterRoomStateResponse(payloadRaw interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	events, ok := payloadRaw.([]interface{})
+	if !ok {
+		return payloadRaw
+	}
+
+	return filterEventsList(events, policy, checker, userId)
+}
+
+// FilterRoomEventResponse is a response filter for: /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/event/{eventId}
+//
+// Hidden events are reported as not found.
+// Room visibility is expected to have been checked before the request was sent upstream.
+func FilterRoomEventResponse(payloadRaw interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	if !isEventVisible(payloadRaw, policy, checker, userId) {
+		return nil
+	}
+
+	return payloadRaw
+}
+
+// FilterRoomChunkResponse is a response filter for APIs which return a `chunk` list of events for a single room:
+// - /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/members
+// - /_matrix/client/v1/rooms/{roomId}/relations/..
+// - /_matrix/client/v1/rooms/{roomId}/threads
+//
+// Room visibility is expected to have been checked before the request was sent upstream.
+func FilterRoomChunkResponse(payloadRaw interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	payload, ok := payloadRaw.(map[string]interface{})
+	if !ok {
+		return payloadRaw
+	}
+
+	filterEventsListAtKey(payload, "chunk", policy, checker, userId)
+
+	return payload
+}
+
+// FilterRoomInitialSyncResponse is a response filter for: /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/initialSync
+//
+// Room visibility is expected to have been checked before the request was sent upstream.
+func FilterRoomInitialSyncResponse(payloadRaw interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	payload, ok := payloadRaw.(map[string]interface{})
+	if !ok {
+		return payloadRaw
+	}
+
+	if messages, ok := payload["messages"].(map[string]interface{}); ok {
+		filterEventsListAtKey(messages, "chunk", policy, checker, userId)
+	}
+	filterEventsListAtKey(payload, "state", policy, checker, userId)
+	filterEventsListAtKey(payload, "account_data", policy, checker, userId)
+
+	return payload
+}
+
+// FilterRoomHierarchyResponse is a response filter for: /_matrix/client/v1/rooms/{roomId}/hierarchy
+//
+// It removes hidden (child) rooms and references to them.
+// Visibility of the room the hierarchy is requested for is expected to have been checked before the request was sent upstream.
+func FilterRoomHierarchyResponse(payloadRaw interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	payload, ok := payloadRaw.(map[string]interface{})
+	if !ok {
+		return payloadRaw
+	}
+
+	rooms, ok := payload["rooms"].([]interface{})
+	if !ok {
+		return payload
+	}
+
+	filteredRooms := make([]interface{}, 0, len(rooms))
+	for _, room := range rooms {
+		roomMap, ok := room.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if roomId, ok := roomMap["room_id"].(string); ok && !checker.CanUserSeeRoom(policy, userId, roomId) {
+			continue
+		}
+
+		if childrenState, ok := roomMap["children_state"].([]interface{}); ok {
+			filteredChildrenState := make([]interface{}, 0, len(childrenState))
+			for _, event := range childrenState {
+				eventMap, ok := event.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				// The state key of `m.space.child` events is the child room's id.
+				if childRoomId, ok := eventMap["state_key"].(string); ok && !checker.CanUserSeeRoom(policy, userId, childRoomId) {
+					continue
+				}
+
+				if !isEventVisible(event, policy, checker, userId) {
+					continue
+				}
+
+				filteredChildrenState = append(filteredChildrenState, event)
+			}
+			roomMap["children_state"] = filteredChildrenState
+		}
+
+		filteredRooms = append(filteredRooms, roomMap)
+	}
+	payload["rooms"] = filteredRooms
+
+	return payload
+}
@@ -0,0 +1,106 @@
+package responsefilter
+
+import (
+	"devture-matrix-corporal/corporal/policy"
+)
+
+// FilterSearchResponse is a response filter for: /_matrix/client/{apiVersion:(r0|v3)}/search
+//
+// Search results may come from any room, so results from hidden rooms (and of hidden event types) are removed.
+func FilterSearchResponse(payloadRaw interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	payload, ok := payloadRaw.(map[string]interface{})
+	if !ok {
+		return payloadRaw
+	}
+
+	searchCategories, ok := payload["search_categories"].(map[string]interface{})
+	if !ok {
+		return payload
+	}
+
+	roomEvents, ok := searchCategories["room_events"].(map[string]interface{})
+	if !ok {
+		return payload
+	}
+
+	if results, ok := roomEvents["results"].([]interface{}); ok {
+		filteredResults := make([]interface{}, 0, len(results))
+		for _, result := range results {
+			resultMap, ok := result.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if !isEventVisible(resultMap["result"], policy, checker, userId) {
+				continue
+			}
+
+			if context, ok := resultMap["context"].(map[string]interface{}); ok {
+				filterEventsListAtKey(context, "events_before", policy, checker, userId)
+				filterEventsListAtKey(context, "events_after", policy, checker, userId)
+			}
+
+			filteredResults = append(filteredResults, resultMap)
+		}
+		roomEvents["results"] = filteredResults
+	}
+
+	if state, ok := roomEvents["state"].(map[string]interface{}); ok {
+		for roomId := range state {
+			if !checker.CanUserSeeRoom(policy, userId, roomId) {
+				delete(state, roomId)
+				continue
+			}
+
+			filterEventsListAtKey(state, roomId, policy, checker, userId)
+		}
+	}
+
+	if groups, ok := roomEvents["groups"].(map[string]interface{}); ok {
+		if groupsByRoomId, ok := groups["room_id"].(map[string]interface{}); ok {
+			for roomId := range groupsByRoomId {
+				if !checker.CanUserSeeRoom(policy, userId, roomId) {
+					delete(groupsByRoomId, roomId)
+				}
+			}
+		}
+	}
+
+	return payload
+}
+
+// FilterNotificationsResponse is a response filter for: /_matrix/client/{apiVersion:(r0|v3)}/notifications
+//
+// Notifications may come from any room, so ones from hidden rooms (and for hidden event types) are removed.
+func FilterNotificationsResponse(payloadRaw interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	payload, ok := payloadRaw.(map[string]interface{})
+	if !ok {
+		return payloadRaw
+	}
+
+	notifications, ok := payload["notifications"].([]interface{})
+	if !ok {
+		return payload
+	}
+
+	filteredNotifications := make([]interface{}, 0, len(notifications))
+	for _, notification := range notifications {
+		notificationMap, ok := notification.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if roomId, ok := notificationMap["room_id"].(string); ok && !checker.CanUserSeeRoom(policy, userId, roomId) {
+			continue
+		}
+
+		if !isEventVisible(notificationMap["event"], policy, checker, userId) {
+			continue
+		}
+
+		filteredNotifications = append(filteredNotifications, notificationMap)
+	}
+	payload["notifications"] = filteredNotifications
+
+	return payload
+}
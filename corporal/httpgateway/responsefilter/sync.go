@@ -0,0 +1,58 @@
+package responsefilter
+
+import (
+	"devture-matrix-corporal/corporal/policy"
+)
+
+// FilterSyncResponse is a response filter for: /_matrix/client/{apiVersion:(r0|v3)}/sync
+//
+// It removes hidden rooms (regardless of membership) and hidden event types.
+func FilterSyncResponse(payloadRaw interface{}, policy policy.Policy, checker policy.Checker, userId string) interface{} {
+	payload, ok := payloadRaw.(map[string]interface{})
+	if !ok {
+		return payloadRaw
+	}
+
+	filterEventsObjectAtKey(payload, "presence", policy, checker, userId)
+	filterEventsObjectAtKey(payload, "account_data", policy, checker, userId)
+	filterEventsObjectAtKey(payload, "to_device", policy, checker, userId)
+
+	rooms, ok := payload["rooms"].(map[string]interface{})
+	if !ok {
+		return payload
+	}
+
+	// Each membership type contains a map of room ids to room data.
+	// The room data contains different event lists, depending on the membership type.
+	eventObjectKeysByMembership := map[string][]string{
+		"join":   {"state", "timeline", "ephemeral", "account_data"},
+		"invite": {"invite_state"},
+		"leave":  {"state", "timeline", "account_data"},
+		"knock":  {"knock_state"},
+	}
+
+	for membership, eventObjectKeys := range eventObjectKeysByMembership {
+		roomsByRoomId, ok := rooms[membership].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for roomId, roomData := range roomsByRoomId {
+			if !checker.CanUserSeeRoom(policy, userId, roomId) {
+				delete(roomsByRoomId, roomId)
+				continue
+			}
+
+			roomDataMap, ok := roomData.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			for _, key := range eventObjectKeys {
+				filterEventsObjectAtKey(roomDataMap, key, policy, checker, userId)
+			}
+		}
+	}
+
+	return payload
+}
@@ -0,0 +1,28 @@
+package httphelp
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// DecodeJsonPreservingNumbers decodes a JSON payload, keeping numbers as json.Number values.
+//
+// Unlike decoding numbers to float64 (the default), this doesn't lose precision for large integers,
+// so the payload can be re-encoded faithfully later (see EncodeJsonPreservingHtml).
+func DecodeJsonPreservingNumbers(reader io.Reader, out interface{}) error {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	return decoder.Decode(out)
+}
+
+// EncodeJsonPreservingHtml encodes a JSON payload without escaping HTML characters (`<`, `>`, `&`).
+//
+// Matrix payloads (e.g. `formatted_body`) commonly contain such characters,
+// and escaping them would make re-encoded payloads differ from what the client sent.
+func EncodeJsonPreservingHtml(writer io.Writer, payload interface{}) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	return encoder.Encode(payload)
+}
@@ -1,8 +1,11 @@
 package httphelp
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 )
@@ -67,3 +70,66 @@ func GetJsonFromRequestBody(r *http.Request, out interface{}) error {
 
 	return nil
 }
+
+// PeekRequestBody reads (at most) the first `limit` bytes of the request body and restores the body,
+// so that other things (like reverse-proxying) can read it in full later.
+// Unlike GetRequestBody, the rest of the body is not read into memory.
+func PeekRequestBody(r *http.Request, limit int64) ([]byte, error) {
+	peekedBytes, err := io.ReadAll(io.LimitReader(r.Body, limit))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read request body payload: %s", err)
+	}
+
+	r.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(peekedBytes), r.Body),
+		Closer: r.Body,
+	}
+
+	return peekedBytes, nil
+}
+
+// readCloser combines a Reader with the Closer of another (original) ReadCloser
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// ErrRequestBodyTooLarge is the error reading a request body limited by LimitRequestBody fails with,
+// once more than the allowed number of bytes have been read.
+var ErrRequestBodyTooLarge = errors.New("request body too large")
+
+// LimitRequestBody makes reading the request body fail with ErrRequestBodyTooLarge after more than `limit` bytes.
+// Unlike PeekRequestBody, nothing is read in advance, so this is suitable for bodies of unknown (and possibly large) size.
+// The limit is enforced whenever the body gets read (e.g. while reverse-proxying).
+func LimitRequestBody(r *http.Request, limit int64) {
+	r.Body = readCloser{
+		Reader: &limitedReader{reader: r.Body, remainingBytes: limit},
+		Closer: r.Body,
+	}
+}
+
+// limitedReader is like io.LimitedReader, but fails (instead of reporting EOF) when the limit is exceeded
+type limitedReader struct {
+	reader         io.Reader
+	remainingBytes int64
+}
+
+func (me *limitedReader) Read(p []byte) (int, error) {
+	if me.remainingBytes < 0 {
+		return 0, ErrRequestBodyTooLarge
+	}
+
+	// Reading (at most) 1 byte more than what remains allows us to tell if the limit has been exceeded.
+	if int64(len(p)) > me.remainingBytes+1 {
+		p = p[:me.remainingBytes+1]
+	}
+
+	n, err := me.reader.Read(p)
+	me.remainingBytes -= int64(n)
+	if me.remainingBytes < 0 {
+		// The extra byte is not passed along.
+		return n - 1, ErrRequestBodyTooLarge
+	}
+
+	return n, err
+}
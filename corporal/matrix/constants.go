@@ -12,6 +12,7 @@ const (
 	ErrorLimitExceeded    = "M_LIMIT_EXCEEDED"
 	ErrorMissingParameter = "M_MISSING_PARAM"
 	ErrorNotFound         = "M_NOT_FOUND"
+	ErrorTooLarge         = "M_TOO_LARGE"
 )
 
 const (
@@ -20,6 +21,14 @@ const (
 	DeactivatedAccountPrefixMarker = "[x] "
 )
 
+const (
+	MembershipJoin   = "join"
+	MembershipInvite = "invite"
+	MembershipKnock  = "knock"
+	MembershipLeave  = "leave"
+	MembershipBan    = "ban"
+)
+
 const (
 	LoginTypePassword = "m.login.password"
 	LoginTypeToken    = "m.login.token"
@@ -28,6 +28,12 @@ type ApiLoginRequestIdentifier struct {
 	User string `json:"user"`
 }
 
+// ApiLoginResponsePayload represents a (successful) response payload for: POST /_matrix/client/{apiVersion:(r0|v3)}/login
+type ApiLoginResponsePayload struct {
+	UserId   string `json:"user_id"`
+	DeviceId string `json:"device_id"`
+}
+
 // ApiAdminResponseUserLogin represents a login response payload
 // at: POST /_synapse/admin/v1/users/<user_id>/login
 type ApiAdminResponseUserLogin struct {
@@ -49,6 +55,161 @@ type ApiAdminEntityUser struct {
 	PasswordHash string `json:"password_hash"`
 	DisplayName  string `json:"displayname"`
 	AvatarURL    string `json:"avatar_url"`
+	Deactivated  bool   `json:"deactivated"`
+}
+
+// ApiAdminEntityUserDetails represents a user entity
+// at: GET /_synapse/admin/v2/users/<user_id>
+type ApiAdminEntityUserDetails struct {
+	Id        string                   `json:"name"`
+	Threepids []ApiAdminEntityThreepid `json:"threepids"`
+}
+
+// ApiAdminEntityThreepid represents a 3pid, as found in a user entity
+// at: GET /_synapse/admin/v2/users/<user_id>
+type ApiAdminEntityThreepid struct {
+	Medium  string `json:"medium"`
+	Address string `json:"address"`
+}
+
+// ApiAdminUserThreepidsUpdateRequestPayload is a request payload for: PUT /_synapse/admin/v2/users/<user_id>
+//
+// The 3pids submitted this way replace all of the user's existing 3pids.
+type ApiAdminUserThreepidsUpdateRequestPayload struct {
+	Threepids []ApiAdminEntityThreepid `json:"threepids"`
+}
+
+// ApiAdminUserServerAdminRequestPayload is a request payload for: PUT /_synapse/admin/v1/users/<user_id>/admin
+type ApiAdminUserServerAdminRequestPayload struct {
+	Admin bool `json:"admin"`
+}
+
+// ApiAdminUserRateLimitOverride is a request payload for: POST /_synapse/admin/v1/users/<user_id>/override_ratelimit
+// and a response as found at: GET /_synapse/admin/v1/users/<user_id>/override_ratelimit
+//
+// When the user has no override, the GET response is an empty object.
+type ApiAdminUserRateLimitOverride struct {
+	MessagesPerSecond *int `json:"messages_per_second,omitempty"`
+	BurstCount        *int `json:"burst_count,omitempty"`
+}
+
+// ApiAdminUserDevicesResponse is a response as found at: GET /_synapse/admin/v2/users/<user_id>/devices
+type ApiAdminUserDevicesResponse struct {
+	Devices []ApiAdminEntityDevice `json:"devices"`
+}
+
+// ApiAdminEntityDevice represents a device, as found in ApiAdminUserDevicesResponse
+type ApiAdminEntityDevice struct {
+	DeviceId    string `json:"device_id"`
+	DisplayName string `json:"display_name"`
+
+	// LastSeenTs is a timestamp (in milliseconds). It's 0 for devices which have never been seen.
+	LastSeenTs int64 `json:"last_seen_ts"`
+}
+
+// ApiAdminUserDeleteDevicesRequestPayload is a request payload for: POST /_synapse/admin/v2/users/<user_id>/delete_devices
+type ApiAdminUserDeleteDevicesRequestPayload struct {
+	Devices []string `json:"devices"`
+}
+
+// ApiAdminUserRedactRequestPayload is a request payload for: POST /_synapse/admin/v1/user/<user_id>/redact
+//
+// An empty Rooms list means "all rooms the user is a member of".
+type ApiAdminUserRedactRequestPayload struct {
+	Rooms  []string `json:"rooms"`
+	Reason string   `json:"reason,omitempty"`
+	Limit  int      `json:"limit,omitempty"`
+}
+
+// ApiAdminUserDeactivateRequestPayload is a request payload for: POST /_synapse/admin/v1/deactivate/<user_id>
+type ApiAdminUserDeactivateRequestPayload struct {
+	Erase bool `json:"erase"`
+}
+
+// ApiAdminUserReactivateRequestPayload is a request payload for: PUT /_synapse/admin/v2/users/<user_id>
+//
+// A password is required for reactivation, unless password authentication is disabled on the homeserver.
+type ApiAdminUserReactivateRequestPayload struct {
+	Deactivated bool   `json:"deactivated"`
+	Password    string `json:"password"`
+}
+
+// ApiRoomMembersResponse is a response as found at: GET /_matrix/client/{apiVersion:(r0|v3)}/rooms/{roomId}/members
+type ApiRoomMembersResponse struct {
+	Chunk []ApiRoomMemberEvent `json:"chunk"`
+}
+
+// ApiRoomMemberEvent is an `m.room.member` state event, as found in ApiRoomMembersResponse
+type ApiRoomMemberEvent struct {
+	StateKey string `json:"state_key"`
+	Content  struct {
+		Membership string `json:"membership"`
+	} `json:"content"`
+}
+
+// RoomPowerLevelsContent contains the room-wide fields of an `m.room.power_levels` state event's content.
+// User power levels (`users`, `users_default`) are not part of it.
+//
+// Fields which are missing from the event are nil.
+type RoomPowerLevelsContent struct {
+	Events        map[string]int `json:"events,omitempty"`
+	EventsDefault *int           `json:"events_default,omitempty"`
+	StateDefault  *int           `json:"state_default,omitempty"`
+	Invite        *int           `json:"invite,omitempty"`
+	Kick          *int           `json:"kick,omitempty"`
+	Ban           *int           `json:"ban,omitempty"`
+	Redact        *int           `json:"redact,omitempty"`
+	Notifications map[string]int `json:"notifications,omitempty"`
+}
+
+// ApiDirectoryRoomResponse is a response as found at: GET /_matrix/client/{apiVersion:(r0|v3)}/directory/room/{roomAlias}
+type ApiDirectoryRoomResponse struct {
+	RoomId  string   `json:"room_id"`
+	Servers []string `json:"servers"`
+}
+
+// RoomTag is the content of a room tag (e.g. `m.favourite`), as found in the `m.tag` room account data
+type RoomTag struct {
+	Order *float64 `json:"order,omitempty"`
+}
+
+// ApiRoomTagsResponse is a response as found at: GET /_matrix/client/{apiVersion:(r0|v3)}/user/{userId}/rooms/{roomId}/tags
+type ApiRoomTagsResponse struct {
+	Tags map[string]RoomTag `json:"tags"`
+}
+
+// ApiPushRule is a push rule, as found in the response at: GET /_matrix/client/{apiVersion:(r0|v3)}/pushrules/
+type ApiPushRule struct {
+	RuleId     string                   `json:"rule_id"`
+	Default    bool                     `json:"default"`
+	Enabled    bool                     `json:"enabled"`
+	Actions    []interface{}            `json:"actions"`
+	Conditions []map[string]interface{} `json:"conditions,omitempty"`
+	Pattern    string                   `json:"pattern,omitempty"`
+}
+
+// ApiPushRulesResponse is a response as found at: GET /_matrix/client/{apiVersion:(r0|v3)}/pushrules/
+//
+// Rules are grouped by kind (`override`, `content`, `room`, `sender`, `underride`).
+type ApiPushRulesResponse struct {
+	Global map[string][]ApiPushRule `json:"global"`
+}
+
+// ApiPushRuleRequestPayload is a request payload for: PUT /_matrix/client/{apiVersion:(r0|v3)}/pushrules/global/{kind}/{ruleId}
+type ApiPushRuleRequestPayload struct {
+	Actions    []interface{}            `json:"actions"`
+	Conditions []map[string]interface{} `json:"conditions,omitempty"`
+	Pattern    string                   `json:"pattern,omitempty"`
+}
+
+// ApiPushRuleActionsRequestPayload is a request payload for: PUT /_matrix/client/{apiVersion:(r0|v3)}/pushrules/global/{kind}/{ruleId}/actions
+type ApiPushRuleActionsRequestPayload struct {
+	Actions []interface{} `json:"actions"`
+}
+
+// ApiPushRuleEnabledRequestPayload is a request payload for: PUT /_matrix/client/{apiVersion:(r0|v3)}/pushrules/global/{kind}/{ruleId}/enabled
+type ApiPushRuleEnabledRequestPayload struct {
+	Enabled bool `json:"enabled"`
 }
 
 // ApiWhoAmIResponse is a response as found at: GET /_matrix/client/{apiVersion:(r0|v3)}/account/whoami
@@ -91,3 +91,30 @@ func DetermineFullUserId(userIdLocalOrFull, homeserverDomainName string) (string
 func IsFullUserIdOfDomain(userIdFull string, homeserverDomainName string) bool {
 	return strings.HasSuffix(userIdFull, fmt.Sprintf(":%s", homeserverDomainName))
 }
+
+// GetDomainFromIdentifier returns the server name part of a Matrix identifier (user id, room id, room alias, etc.)
+// For example, `@user:example.com` -> `example.com`.
+// An empty string is returned if the identifier does not contain a server name.
+func GetDomainFromIdentifier(identifier string) string {
+	idx := strings.Index(identifier, ":")
+	if idx == -1 {
+		return ""
+	}
+	return identifier[idx+1:]
+}
+
+// IsRoomAlias tells if the given identifier is a room alias (e.g. `#room:example.com`), as opposed to a room id (e.g. `!room:example.com`)
+func IsRoomAlias(identifier string) bool {
+	return strings.HasPrefix(identifier, "#")
+}
+
+// IsRoomAliasWellFormed tells if the given room alias has the expected `#localpart:server` form
+func IsRoomAliasWellFormed(roomAlias string) bool {
+	if !IsRoomAlias(roomAlias) {
+		return false
+	}
+
+	localpart, server, found := strings.Cut(roomAlias[1:], ":")
+
+	return found && localpart != "" && server != ""
+}
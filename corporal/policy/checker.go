@@ -1,5 +1,16 @@
 package policy
 
+import (
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/util"
+	"strings"
+	"time"
+)
+
+// Checker tells what users can do according to a policy.
+//
+// Room aliases are not taken into account, so checks are supposed to be done against a policy
+// whose aliases have been resolved (see Store.Get() and Policy.WithResolvedRoomAliases).
 type Checker struct {
 }
 
@@ -7,6 +18,11 @@ func NewChecker() *Checker {
 	return &Checker{}
 }
 
+// IsRoomManaged tells whether the given room is one of the policy's managed rooms
+func (me *Checker) IsRoomManaged(policy Policy, roomId string) bool {
+	return util.IsStringInArray(roomId, policy.ManagedRoomIds)
+}
+
 func (me *Checker) CanUserCreateRoom(policy Policy, userId string) bool {
 	userPolicy := policy.GetUserPolicyByUserId(userId)
 	if userPolicy != nil {
@@ -64,7 +80,7 @@ func (me *Checker) CanUserChangeOwnMembershipStateInRoom(policy Policy, userId s
 		return true
 	}
 
-	for _, value := range userPolicy.JoinedRooms {
+	for _, value := range userPolicy.GetJoinedRoomsActiveAt(time.Now()) {
 		if value.RoomId == roomId {
 			return false
 		}
@@ -74,9 +90,436 @@ func (me *Checker) CanUserChangeOwnMembershipStateInRoom(policy Policy, userId s
 }
 
 func (me *Checker) CanUserUseCustomDisplayName(policy Policy, userId string) bool {
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.AllowCustomDisplayName != nil {
+			return *userPolicy.AllowCustomDisplayName
+		}
+	}
+
+	// No dedicated policy for this user (likely an unmanaged user) or undefined AllowCustomDisplayName policy field.
+	// Stick to the global defaults.
 	return policy.Flags.AllowCustomUserDisplayNames
 }
 
 func (me *Checker) CanUserUseCustomAvatar(policy Policy, userId string) bool {
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.AllowCustomAvatar != nil {
+			return *userPolicy.AllowCustomAvatar
+		}
+	}
+
+	// No dedicated policy for this user (likely an unmanaged user) or undefined AllowCustomAvatar policy field.
+	// Stick to the global defaults.
 	return policy.Flags.AllowCustomUserAvatars
 }
+
+// HasVisibilityRestrictions tells whether some rooms or event types are hidden from the given user.
+// If not, responses can be delivered to the user without any filtering.
+func (me *Checker) HasVisibilityRestrictions(policy Policy, userId string) bool {
+	if len(policy.Flags.HiddenRoomIds) != 0 || len(policy.Flags.HiddenEventTypes) != 0 {
+		return true
+	}
+
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy == nil {
+		return false
+	}
+
+	return hasUserPolicyVisibilityRestrictions(userPolicy)
+}
+
+// HasAnyVisibilityRestrictions tells whether some rooms or event types are hidden from at least one user.
+// If not, there's no need to figure out who a request is for before delivering it unfiltered.
+func (me *Checker) HasAnyVisibilityRestrictions(policy Policy) bool {
+	if len(policy.Flags.HiddenRoomIds) != 0 || len(policy.Flags.HiddenEventTypes) != 0 {
+		return true
+	}
+
+	for _, userPolicy := range policy.User {
+		if hasUserPolicyVisibilityRestrictions(userPolicy) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasUserPolicyVisibilityRestrictions(userPolicy *UserPolicy) bool {
+	return len(userPolicy.HiddenRoomIds) != 0 || len(userPolicy.HiddenEventTypes) != 0 || userPolicy.VisibleRoomIds != nil
+}
+
+func (me *Checker) CanUserSeeRoom(policy Policy, userId string, roomId string) bool {
+	if util.IsStringInArray(roomId, policy.Flags.HiddenRoomIds) {
+		return false
+	}
+
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy == nil {
+		return true
+	}
+
+	if util.IsStringInArray(roomId, userPolicy.HiddenRoomIds) {
+		return false
+	}
+
+	if userPolicy.VisibleRoomIds != nil {
+		return util.IsStringInArray(roomId, *userPolicy.VisibleRoomIds)
+	}
+
+	return true
+}
+
+func (me *Checker) CanUserSeeEventType(policy Policy, userId string, eventType string) bool {
+	for _, pattern := range policy.Flags.HiddenEventTypes {
+		if matchesWildcardPattern(eventType, pattern) {
+			return false
+		}
+	}
+
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy == nil {
+		return true
+	}
+
+	for _, pattern := range userPolicy.HiddenEventTypes {
+		if matchesWildcardPattern(eventType, pattern) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// determineInviteRestriction returns the invite restriction (and allowed domains list) which apply to the given user
+func (me *Checker) determineInviteRestriction(policy Policy, userId string) (string, []string) {
+	inviteRestriction := policy.Flags.InviteRestriction
+	inviteAllowedDomains := policy.Flags.InviteAllowedDomains
+
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.InviteRestriction != nil {
+			inviteRestriction = *userPolicy.InviteRestriction
+		}
+		if userPolicy.InviteAllowedDomains != nil {
+			inviteAllowedDomains = *userPolicy.InviteAllowedDomains
+		}
+	}
+
+	if inviteRestriction == "" {
+		inviteRestriction = InviteRestrictionNone
+	}
+
+	return inviteRestriction, inviteAllowedDomains
+}
+
+func (me *Checker) CanUserInviteUser(policy Policy, userId string, inviteeId string) bool {
+	inviteRestriction, inviteAllowedDomains := me.determineInviteRestriction(policy, userId)
+
+	switch inviteRestriction {
+	case InviteRestrictionNone:
+		return true
+	case InviteRestrictionManagedUsers:
+		return policy.GetUserPolicyByUserId(inviteeId) != nil
+	case InviteRestrictionHomeDomain:
+		// Users going through us are local users, so the inviter's domain is the home domain.
+		return matrix.GetDomainFromIdentifier(inviteeId) == matrix.GetDomainFromIdentifier(userId)
+	case InviteRestrictionAllowedDomains:
+		return util.IsStringInArray(matrix.GetDomainFromIdentifier(inviteeId), inviteAllowedDomains)
+	}
+
+	// InviteRestrictionForbidden or something unknown
+	return false
+}
+
+// CanUserInviteThirdParties tells whether the user can send invites to third-party identifiers (email addresses, etc.)
+//
+// We cannot know which Matrix user (if any) such an invite ends up being for,
+// so these are only allowed when invites are not restricted at all.
+func (me *Checker) CanUserInviteThirdParties(policy Policy, userId string) bool {
+	inviteRestriction, _ := me.determineInviteRestriction(policy, userId)
+
+	return inviteRestriction == InviteRestrictionNone
+}
+
+// CanUserJoinRoomOnDomain tells whether the user can join rooms related to the given domain.
+// The domain may come from a room id, a room alias or a server name that the join is to happen through.
+func (me *Checker) CanUserJoinRoomOnDomain(policy Policy, userId string, domain string) bool {
+	joinAllowedDomains := policy.Flags.JoinAllowedDomains
+	joinForbiddenDomains := policy.Flags.JoinForbiddenDomains
+
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.JoinAllowedDomains != nil {
+			joinAllowedDomains = *userPolicy.JoinAllowedDomains
+		}
+		if userPolicy.JoinForbiddenDomains != nil {
+			joinForbiddenDomains = *userPolicy.JoinForbiddenDomains
+		}
+	}
+
+	if util.IsStringInArray(domain, joinForbiddenDomains) {
+		return false
+	}
+
+	if len(joinAllowedDomains) == 0 {
+		return true
+	}
+
+	return util.IsStringInArray(domain, joinAllowedDomains)
+}
+
+// determineDirectMessageRestriction returns the direct message restriction (and allowed domains list) which apply to the given user
+func (me *Checker) determineDirectMessageRestriction(policy Policy, userId string) (string, []string) {
+	directMessageRestriction := policy.Flags.DirectMessageRestriction
+	directMessageAllowedDomains := policy.Flags.DirectMessageAllowedDomains
+
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.DirectMessageRestriction != nil {
+			directMessageRestriction = *userPolicy.DirectMessageRestriction
+		}
+		if userPolicy.DirectMessageAllowedDomains != nil {
+			directMessageAllowedDomains = *userPolicy.DirectMessageAllowedDomains
+		}
+	}
+
+	if directMessageRestriction == "" {
+		directMessageRestriction = DirectMessageRestrictionNone
+	}
+
+	return directMessageRestriction, directMessageAllowedDomains
+}
+
+func (me *Checker) CanUserCreateDirectMessageRoom(policy Policy, userId string) bool {
+	directMessageRestriction, _ := me.determineDirectMessageRestriction(policy, userId)
+
+	return directMessageRestriction != DirectMessageRestrictionForbidden
+}
+
+func (me *Checker) CanUserDirectMessageUser(policy Policy, userId string, counterpartId string) bool {
+	directMessageRestriction, directMessageAllowedDomains := me.determineDirectMessageRestriction(policy, userId)
+
+	switch directMessageRestriction {
+	case DirectMessageRestrictionNone:
+		return true
+	case DirectMessageRestrictionManagedUsers:
+		return policy.GetUserPolicyByUserId(counterpartId) != nil
+	case DirectMessageRestrictionAllowedDomains:
+		return util.IsStringInArray(matrix.GetDomainFromIdentifier(counterpartId), directMessageAllowedDomains)
+	}
+
+	// DirectMessageRestrictionForbidden or something unknown
+	return false
+}
+
+// CanUserDirectMessageThirdParties tells whether the user can start direct message rooms with third-party identifiers (email addresses, etc.)
+//
+// We cannot know which Matrix user (if any) such a direct message room ends up being with,
+// so these are only allowed when direct messages are not restricted at all.
+func (me *Checker) CanUserDirectMessageThirdParties(policy Policy, userId string) bool {
+	directMessageRestriction, _ := me.determineDirectMessageRestriction(policy, userId)
+
+	return directMessageRestriction == DirectMessageRestrictionNone
+}
+
+func (me *Checker) CanUserPublishRoomToDirectory(policy Policy, userId string) bool {
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.ForbidRoomDirectoryPublishing != nil {
+			return !*userPolicy.ForbidRoomDirectoryPublishing
+		}
+	}
+
+	// No dedicated policy for this user (likely an unmanaged user) or undefined ForbidRoomDirectoryPublishing policy field.
+	// Stick to the global defaults.
+	return !policy.Flags.ForbidRoomDirectoryPublishing
+}
+
+// CanUserCreateAlias tells whether the user can create the given room alias (e.g. `#room:example.com`)
+func (me *Checker) CanUserCreateAlias(policy Policy, userId string, alias string) bool {
+	forbidAliasCreation := policy.Flags.ForbidAliasCreation
+	allowedAliasPatterns := policy.Flags.AllowedAliasPatterns
+	allowedAliasPatternsCompiled := policy.Flags.allowedAliasPatternsCompiled
+
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.ForbidAliasCreation != nil {
+			forbidAliasCreation = *userPolicy.ForbidAliasCreation
+		}
+		if userPolicy.AllowedAliasPatterns != nil {
+			allowedAliasPatterns = *userPolicy.AllowedAliasPatterns
+			allowedAliasPatternsCompiled = userPolicy.allowedAliasPatternsCompiled
+		}
+	}
+
+	if forbidAliasCreation {
+		return false
+	}
+
+	if len(allowedAliasPatterns) == 0 {
+		return true
+	}
+
+	return matchesRegexPatterns(alias, allowedAliasPatterns, allowedAliasPatternsCompiled)
+}
+
+func (me *Checker) CanUserUploadMedia(policy Policy, userId string) bool {
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.ForbidMediaUpload != nil {
+			return !*userPolicy.ForbidMediaUpload
+		}
+	}
+
+	// No dedicated policy for this user (likely an unmanaged user) or undefined ForbidMediaUpload policy field.
+	// Stick to the global defaults.
+	return !policy.Flags.ForbidMediaUpload
+}
+
+// GetUserMediaUploadMaxSizeBytes returns the maximum size (in bytes) of media files the user can upload (0 means no limit)
+func (me *Checker) GetUserMediaUploadMaxSizeBytes(policy Policy, userId string) int64 {
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.MediaUploadMaxSizeBytes != nil {
+			return *userPolicy.MediaUploadMaxSizeBytes
+		}
+	}
+
+	return policy.Flags.MediaUploadMaxSizeBytes
+}
+
+func (me *Checker) CanUserUploadMediaOfSize(policy Policy, userId string, sizeBytes int64) bool {
+	maxSizeBytes := me.GetUserMediaUploadMaxSizeBytes(policy, userId)
+
+	return maxSizeBytes == 0 || sizeBytes <= maxSizeBytes
+}
+
+// CanUserUploadMediaOfContentType tells whether the user can upload media of the given content type (e.g. `image/png`).
+// Content type parameters (e.g. `; charset=utf-8`) are ignored.
+func (me *Checker) CanUserUploadMediaOfContentType(policy Policy, userId string, contentType string) bool {
+	allowedContentTypes := policy.Flags.MediaUploadAllowedContentTypes
+
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.MediaUploadAllowedContentTypes != nil {
+			allowedContentTypes = *userPolicy.MediaUploadAllowedContentTypes
+		}
+	}
+
+	if len(allowedContentTypes) == 0 {
+		return true
+	}
+
+	contentType = strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+
+	for _, pattern := range allowedContentTypes {
+		if matchesWildcardPattern(contentType, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanUserUpgradeRoom tells whether the user can upgrade (or otherwise tombstone) the given room
+func (me *Checker) CanUserUpgradeRoom(policy Policy, userId string, roomId string) bool {
+	if !me.IsRoomManaged(policy, roomId) {
+		// We don't care about unmanaged rooms.
+		return true
+	}
+
+	return policy.Flags.AllowManagedRoomUpgrades
+}
+
+// CanUserChange3pids tells whether the user can add or remove 3pids (email addresses, phone numbers) to their account
+func (me *Checker) CanUserChange3pids(policy Policy, userId string) bool {
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy == nil {
+		// We don't care about unmanaged users.
+		return true
+	}
+
+	if userPolicy.Threepids != nil {
+		// The policy dictates what 3pids this user has.
+		// Any change would be undone by reconciliation anyway.
+		return false
+	}
+
+	return !policy.Flags.Forbid3pidChanges
+}
+
+// CanUserSetRoomPowerLevels tells whether the user can change the given room's power levels to the given ones.
+// Only changes which go against the power levels declared for the room in the policy are forbidden.
+func (me *Checker) CanUserSetRoomPowerLevels(policy Policy, userId string, roomId string, content matrix.RoomPowerLevelsContent) bool {
+	roomPowerLevels := policy.GetRoomPowerLevelsByRoomId(roomId)
+	if roomPowerLevels == nil {
+		// Not something we manage.
+		return true
+	}
+
+	return roomPowerLevels.IsSatisfiedBy(content)
+}
+
+// GetUserDeactivation returns the options controlling what happens when the user gets deactivated
+func (me *Checker) GetUserDeactivation(policy Policy, userId string) UserDeactivation {
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy != nil {
+		if userPolicy.Deactivation != nil {
+			return *userPolicy.Deactivation
+		}
+	}
+
+	// No dedicated policy for this user (likely an unmanaged user) or undefined deactivation policy field.
+	// Stick to the global defaults.
+	if policy.UserDeactivation != nil {
+		return *policy.UserDeactivation
+	}
+
+	return UserDeactivation{}
+}
+
+// GetUserPushRules returns the push rules that the user should have.
+// User-specific rules take precedence over global rules of the same kind and id.
+func (me *Checker) GetUserPushRules(policy Policy, userId string) []PushRule {
+	userPolicy := policy.GetUserPolicyByUserId(userId)
+	if userPolicy == nil {
+		// We only manage push rules for managed users.
+		return []PushRule{}
+	}
+
+	pushRules := make([]PushRule, 0, len(policy.PushRules)+len(userPolicy.PushRules))
+
+	isOverriddenByUserPolicy := func(pushRule *PushRule) bool {
+		for _, userPushRule := range userPolicy.PushRules {
+			if userPushRule.Kind == pushRule.Kind && userPushRule.RuleId == pushRule.RuleId {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, pushRule := range policy.PushRules {
+		if !isOverriddenByUserPolicy(pushRule) {
+			pushRules = append(pushRules, *pushRule)
+		}
+	}
+
+	for _, pushRule := range userPolicy.PushRules {
+		pushRules = append(pushRules, *pushRule)
+	}
+
+	return pushRules
+}
+
+// CanUserChangePushRule tells whether the user can change (or delete) the given push rule
+func (me *Checker) CanUserChangePushRule(policy Policy, userId string, kind string, ruleId string) bool {
+	for _, pushRule := range me.GetUserPushRules(policy, userId) {
+		if pushRule.Kind == kind && pushRule.RuleId == ruleId {
+			return !pushRule.Enforced
+		}
+	}
+
+	return true
+}
@@ -1,6 +1,9 @@
 package policy
 
 import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/userauth"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,6 +11,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 type PermissionAssertment struct {
@@ -20,6 +26,19 @@ type PermissionAssertment struct {
 type TestData struct {
 	Policy                Policy                 `json:"policy"`
 	PermissionAssertments []PermissionAssertment `json:"permissionAssertments"`
+
+	// RoomAliases tells which room id each room alias (possibly used in the policy) resolves to
+	RoomAliases map[string]string `json:"roomAliases"`
+}
+
+type staticRoomAliasLookuper map[string]string
+
+func (me staticRoomAliasLookuper) ResolveRoomAlias(roomAlias string) (string, error) {
+	roomId, exists := me[roomAlias]
+	if !exists {
+		return "", fmt.Errorf("unknown room alias: %s", roomAlias)
+	}
+	return roomId, nil
 }
 
 func TestPolicyPermissionAssertment(t *testing.T) {
@@ -58,7 +77,14 @@ func TestPolicyPermissionAssertment(t *testing.T) {
 				return
 			}
 
-			err = determinePolicyPermissionError(testData.Policy, checker, testData.PermissionAssertments)
+			// Policy checks happen against a policy with resolved room aliases (see Store.Get()).
+			policy, err := testData.Policy.WithResolvedRoomAliases(staticRoomAliasLookuper(testData.RoomAliases).ResolveRoomAlias)
+			if err != nil {
+				t.Errorf("Failed resolving room aliases in %s: %s", testPath, err)
+				return
+			}
+
+			err = determinePolicyPermissionError(*policy, checker, testData.PermissionAssertments)
 			if err != nil {
 				t.Errorf(
 					"Policy permission failure in %s: %s",
@@ -71,6 +97,76 @@ func TestPolicyPermissionAssertment(t *testing.T) {
 	}
 }
 
+func TestRegexPatternsAreCompiledDuringValidation(t *testing.T) {
+	userAliasPatterns := []string{"#user-[a-z]+:host"}
+
+	policy := &Policy{
+		SchemaVersion: 2,
+		Flags: PolicyFlags{
+			AllowedAliasPatterns: []string{"#team-[a-z]+:host"},
+		},
+		User: []*UserPolicy{
+			{
+				Id:                               "@a:host",
+				Active:                           true,
+				AuthType:                         userauth.UserAuthTypePassthrough,
+				AllowedDeviceDisplayNamePatterns: []string{"Element .*"},
+			},
+			{
+				Id:                   "@b:host",
+				Active:               true,
+				AuthType:             userauth.UserAuthTypePassthrough,
+				AllowedAliasPatterns: &userAliasPatterns,
+			},
+		},
+	}
+
+	err := NewValidator("host", hook.NewStore(), NewRoomAliasResolver(logrus.New(), nil, time.Minute)).Validate(policy)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+
+	if len(policy.Flags.allowedAliasPatternsCompiled) != 1 {
+		t.Errorf("expected the flags' alias patterns to be compiled")
+	}
+	if len(policy.User[0].allowedDeviceDisplayNamePatternsCompiled) != 1 {
+		t.Errorf("expected the device display name patterns to be compiled")
+	}
+	if len(policy.User[1].allowedAliasPatternsCompiled) != 1 {
+		t.Errorf("expected the user's alias patterns to be compiled")
+	}
+
+	checker := NewChecker()
+
+	type testCase struct {
+		userId          string
+		alias           string
+		expectedAllowed bool
+	}
+
+	testCases := []testCase{
+		{userId: "@a:host", alias: "#team-sales:host", expectedAllowed: true},
+		{userId: "@a:host", alias: "#not-a-team-sales:host", expectedAllowed: false},
+		{userId: "@a:host", alias: "#team-sales:host.evil.com", expectedAllowed: false},
+		{userId: "@b:host", alias: "#user-sales:host", expectedAllowed: true},
+		{userId: "@b:host", alias: "#team-sales:host", expectedAllowed: false},
+	}
+
+	for _, tc := range testCases {
+		allowed := checker.CanUserCreateAlias(*policy, tc.userId, tc.alias)
+		if allowed != tc.expectedAllowed {
+			t.Errorf("expected %s being allowed to create %s = %t, got %t", tc.userId, tc.alias, tc.expectedAllowed, allowed)
+		}
+	}
+
+	if !policy.User[0].IsDeviceDisplayNameAllowed("Element Web") {
+		t.Errorf("expected the device display name to be allowed")
+	}
+	if policy.User[0].IsDeviceDisplayNameAllowed("Not Element Web") {
+		t.Errorf("expected a partially matching device display name to not be allowed")
+	}
+}
+
 func determinePolicyPermissionError(policy Policy, checker *Checker, assertments []PermissionAssertment) error {
 	for _, assertment := range assertments {
 		err := checkAssertment(policy, checker, assertment)
@@ -95,5 +191,232 @@ func checkAssertment(policy Policy, checker *Checker, assertment PermissionAsser
 		return fmt.Errorf("Expected %t status for user %s being able to leave room %s", assertment.Allowed, userId, roomId)
 	}
 
+	if assertment.Type == "seeRoom" {
+		userId := assertment.Payload["userId"].(string)
+		roomId := assertment.Payload["roomId"].(string)
+
+		allowed := checker.CanUserSeeRoom(policy, userId, roomId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to see room %s", assertment.Allowed, userId, roomId)
+	}
+
+	if assertment.Type == "seeEventType" {
+		userId := assertment.Payload["userId"].(string)
+		eventType := assertment.Payload["eventType"].(string)
+
+		allowed := checker.CanUserSeeEventType(policy, userId, eventType)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to see events of type %s", assertment.Allowed, userId, eventType)
+	}
+
+	if assertment.Type == "inviteUser" {
+		userId := assertment.Payload["userId"].(string)
+		inviteeId := assertment.Payload["inviteeId"].(string)
+
+		allowed := checker.CanUserInviteUser(policy, userId, inviteeId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to invite %s", assertment.Allowed, userId, inviteeId)
+	}
+
+	if assertment.Type == "joinRoomOnDomain" {
+		userId := assertment.Payload["userId"].(string)
+		domain := assertment.Payload["domain"].(string)
+
+		allowed := checker.CanUserJoinRoomOnDomain(policy, userId, domain)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to join rooms on %s", assertment.Allowed, userId, domain)
+	}
+
+	if assertment.Type == "createDirectMessageRoom" {
+		userId := assertment.Payload["userId"].(string)
+
+		allowed := checker.CanUserCreateDirectMessageRoom(policy, userId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to create direct message rooms", assertment.Allowed, userId)
+	}
+
+	if assertment.Type == "directMessageUser" {
+		userId := assertment.Payload["userId"].(string)
+		counterpartId := assertment.Payload["counterpartId"].(string)
+
+		allowed := checker.CanUserDirectMessageUser(policy, userId, counterpartId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to create direct message rooms with %s", assertment.Allowed, userId, counterpartId)
+	}
+
+	if assertment.Type == "publishRoomToDirectory" {
+		userId := assertment.Payload["userId"].(string)
+
+		allowed := checker.CanUserPublishRoomToDirectory(policy, userId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to publish rooms to the room directory", assertment.Allowed, userId)
+	}
+
+	if assertment.Type == "createAlias" {
+		userId := assertment.Payload["userId"].(string)
+		alias := assertment.Payload["alias"].(string)
+
+		allowed := checker.CanUserCreateAlias(policy, userId, alias)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to create alias %s", assertment.Allowed, userId, alias)
+	}
+
+	if assertment.Type == "uploadMedia" {
+		userId := assertment.Payload["userId"].(string)
+
+		allowed := checker.CanUserUploadMedia(policy, userId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to upload media", assertment.Allowed, userId)
+	}
+
+	if assertment.Type == "uploadMediaOfSize" {
+		userId := assertment.Payload["userId"].(string)
+		sizeBytes := int64(assertment.Payload["sizeBytes"].(float64))
+
+		allowed := checker.CanUserUploadMediaOfSize(policy, userId, sizeBytes)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to upload media of size %d", assertment.Allowed, userId, sizeBytes)
+	}
+
+	if assertment.Type == "uploadMediaOfContentType" {
+		userId := assertment.Payload["userId"].(string)
+		contentType := assertment.Payload["contentType"].(string)
+
+		allowed := checker.CanUserUploadMediaOfContentType(policy, userId, contentType)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to upload media of type %s", assertment.Allowed, userId, contentType)
+	}
+
+	if assertment.Type == "upgradeRoom" {
+		userId := assertment.Payload["userId"].(string)
+		roomId := assertment.Payload["roomId"].(string)
+
+		allowed := checker.CanUserUpgradeRoom(policy, userId, roomId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to upgrade room %s", assertment.Allowed, userId, roomId)
+	}
+
+	if assertment.Type == "change3pids" {
+		userId := assertment.Payload["userId"].(string)
+
+		allowed := checker.CanUserChange3pids(policy, userId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to change 3pids", assertment.Allowed, userId)
+	}
+
+	if assertment.Type == "useCustomDisplayName" {
+		userId := assertment.Payload["userId"].(string)
+
+		allowed := checker.CanUserUseCustomDisplayName(policy, userId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to use a custom display name", assertment.Allowed, userId)
+	}
+
+	if assertment.Type == "useCustomAvatar" {
+		userId := assertment.Payload["userId"].(string)
+
+		allowed := checker.CanUserUseCustomAvatar(policy, userId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to use a custom avatar", assertment.Allowed, userId)
+	}
+
+	if assertment.Type == "setRoomPowerLevels" {
+		userId := assertment.Payload["userId"].(string)
+		roomId := assertment.Payload["roomId"].(string)
+
+		powerLevelsBytes, err := json.Marshal(assertment.Payload["powerLevels"])
+		if err != nil {
+			return err
+		}
+
+		var powerLevels matrix.RoomPowerLevelsContent
+		err = json.Unmarshal(powerLevelsBytes, &powerLevels)
+		if err != nil {
+			return err
+		}
+
+		allowed := checker.CanUserSetRoomPowerLevels(policy, userId, roomId, powerLevels)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to set power levels %s in room %s", assertment.Allowed, userId, powerLevelsBytes, roomId)
+	}
+
+	if assertment.Type == "changePushRule" {
+		userId := assertment.Payload["userId"].(string)
+		kind := assertment.Payload["kind"].(string)
+		ruleId := assertment.Payload["ruleId"].(string)
+
+		allowed := checker.CanUserChangePushRule(policy, userId, kind, ruleId)
+
+		if allowed == assertment.Allowed {
+			return nil
+		}
+
+		return fmt.Errorf("Expected %t status for user %s being able to change push rule %s/%s", assertment.Allowed, userId, kind, ruleId)
+	}
+
 	return fmt.Errorf("Unknown policy assertment type: %s", assertment.Type)
 }
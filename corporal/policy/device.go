@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"fmt"
+)
+
+const (
+	// DeviceLimitActionDeny makes logins which would exceed the device limit fail
+	DeviceLimitActionDeny = "deny"
+
+	// DeviceLimitActionEvictOldest makes logins which would exceed the device limit succeed,
+	// by logging out the least recently seen devices first
+	DeviceLimitActionEvictOldest = "evictOldest"
+)
+
+// HasDeviceRestrictions tells whether the devices of this user are subject to any restrictions
+func (me UserPolicy) HasDeviceRestrictions() bool {
+	return me.MaxDevices != 0 || len(me.AllowedDeviceDisplayNamePatterns) != 0
+}
+
+// ShouldEvictOldestDevices tells whether logins exceeding the device limit should log out old devices (instead of being denied)
+func (me UserPolicy) ShouldEvictOldestDevices() bool {
+	return me.DeviceLimitAction == DeviceLimitActionEvictOldest
+}
+
+// IsDeviceDisplayNameAllowed tells whether a device with the given display name is allowed for this user
+func (me UserPolicy) IsDeviceDisplayNameAllowed(displayName string) bool {
+	if len(me.AllowedDeviceDisplayNamePatterns) == 0 {
+		return true
+	}
+
+	return matchesRegexPatterns(displayName, me.AllowedDeviceDisplayNamePatterns, me.allowedDeviceDisplayNamePatternsCompiled)
+}
+
+func (me *UserPolicy) validateDeviceRestrictions() error {
+	if me.MaxDevices < 0 {
+		return fmt.Errorf("maxDevices cannot be negative")
+	}
+
+	if me.DeviceLimitAction != "" && me.DeviceLimitAction != DeviceLimitActionDeny && me.DeviceLimitAction != DeviceLimitActionEvictOldest {
+		return fmt.Errorf("`%s` is an invalid device limit action", me.DeviceLimitAction)
+	}
+
+	var err error
+	me.allowedDeviceDisplayNamePatternsCompiled, err = compileRegexPatterns(me.AllowedDeviceDisplayNamePatterns)
+	if err != nil {
+		return fmt.Errorf("invalid allowedDeviceDisplayNamePatterns: %s", err)
+	}
+
+	return nil
+}
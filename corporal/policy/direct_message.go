@@ -0,0 +1,30 @@
+package policy
+
+import (
+	"devture-matrix-corporal/corporal/util"
+)
+
+const (
+	// DirectMessageRestrictionNone lets users start direct message rooms with anyone
+	DirectMessageRestrictionNone = "none"
+
+	// DirectMessageRestrictionManagedUsers only lets users start direct message rooms with users managed by the policy
+	DirectMessageRestrictionManagedUsers = "managedUsers"
+
+	// DirectMessageRestrictionAllowedDomains only lets users start direct message rooms with users hosted on the domains listed in `directMessageAllowedDomains`
+	DirectMessageRestrictionAllowedDomains = "allowedDomains"
+
+	// DirectMessageRestrictionForbidden prevents users from starting direct message rooms
+	DirectMessageRestrictionForbidden = "forbidden"
+)
+
+var knownDirectMessageRestrictions = []string{
+	DirectMessageRestrictionNone,
+	DirectMessageRestrictionManagedUsers,
+	DirectMessageRestrictionAllowedDomains,
+	DirectMessageRestrictionForbidden,
+}
+
+func isKnownDirectMessageRestriction(directMessageRestriction string) bool {
+	return util.IsStringInArray(directMessageRestriction, knownDirectMessageRestrictions)
+}
@@ -0,0 +1,22 @@
+package policy
+
+import (
+	"devture-matrix-corporal/corporal/util"
+)
+
+const (
+	// ExclusiveRoomRemovalMethodKick kicks members who are not supposed to be in the room (they may come back if invited again)
+	ExclusiveRoomRemovalMethodKick = "kick"
+
+	// ExclusiveRoomRemovalMethodBan bans members who are not supposed to be in the room
+	ExclusiveRoomRemovalMethodBan = "ban"
+)
+
+var knownExclusiveRoomRemovalMethods = []string{
+	ExclusiveRoomRemovalMethodKick,
+	ExclusiveRoomRemovalMethodBan,
+}
+
+func isKnownExclusiveRoomRemovalMethod(removalMethod string) bool {
+	return util.IsStringInArray(removalMethod, knownExclusiveRoomRemovalMethods)
+}
@@ -0,0 +1,34 @@
+package policy
+
+import (
+	"devture-matrix-corporal/corporal/util"
+)
+
+const (
+	// InviteRestrictionNone lets users invite anyone
+	InviteRestrictionNone = "none"
+
+	// InviteRestrictionManagedUsers only lets users invite users managed by the policy
+	InviteRestrictionManagedUsers = "managedUsers"
+
+	// InviteRestrictionHomeDomain only lets users invite users hosted on the same homeserver (managed or not)
+	InviteRestrictionHomeDomain = "homeDomain"
+
+	// InviteRestrictionAllowedDomains only lets users invite users hosted on the domains listed in `inviteAllowedDomains`
+	InviteRestrictionAllowedDomains = "allowedDomains"
+
+	// InviteRestrictionForbidden prevents users from inviting anyone
+	InviteRestrictionForbidden = "forbidden"
+)
+
+var knownInviteRestrictions = []string{
+	InviteRestrictionNone,
+	InviteRestrictionManagedUsers,
+	InviteRestrictionHomeDomain,
+	InviteRestrictionAllowedDomains,
+	InviteRestrictionForbidden,
+}
+
+func isKnownInviteRestriction(inviteRestriction string) bool {
+	return util.IsStringInArray(inviteRestriction, knownInviteRestrictions)
+}
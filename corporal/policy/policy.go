@@ -2,10 +2,17 @@ package policy
 
 import (
 	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/matrix"
 	"devture-matrix-corporal/corporal/userauth"
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 )
 
+// reservedAccountDataTypePrefix is the prefix of account data types that matrix-corporal uses internally (and which the policy cannot manage)
+const reservedAccountDataTypePrefix = "com.devture.matrix.corporal."
+
 type Policy struct {
 	SchemaVersion int `json:"schemaVersion"`
 
@@ -24,6 +31,21 @@ type Policy struct {
 
 	ManagedRoomIds []string `json:"managedRoomIds"`
 
+	// ExclusiveRooms contains managed rooms, whose membership is exclusively controlled by the policy.
+	// Anyone who is not supposed to be in such a room (according to the policy) gets removed from it.
+	ExclusiveRooms []*ExclusiveRoom `json:"exclusiveRooms"`
+
+	// RoomPowerLevels contains declarations for the room-wide power levels of managed rooms
+	RoomPowerLevels []*RoomPowerLevels `json:"roomPowerLevels"`
+
+	// PushRules contains push rules that all managed users should have.
+	// Rules can be overridden for individual users via UserPolicy.PushRules.
+	PushRules []*PushRule `json:"pushRules"`
+
+	// UserDeactivation controls what happens when users get deactivated.
+	// It can be overridden for individual users via UserPolicy.Deactivation.
+	UserDeactivation *UserDeactivation `json:"userDeactivation"`
+
 	User []*UserPolicy `json:"users"`
 }
 
@@ -35,6 +57,24 @@ func (me *Policy) GetManagedUserIds() []string {
 	return userIds
 }
 
+func (me *Policy) GetExclusiveRoomByRoomId(roomId string) *ExclusiveRoom {
+	for _, exclusiveRoom := range me.ExclusiveRooms {
+		if exclusiveRoom.RoomId == roomId {
+			return exclusiveRoom
+		}
+	}
+	return nil
+}
+
+func (me *Policy) GetRoomPowerLevelsByRoomId(roomId string) *RoomPowerLevels {
+	for _, roomPowerLevels := range me.RoomPowerLevels {
+		if roomPowerLevels.RoomId == roomId {
+			return roomPowerLevels
+		}
+	}
+	return nil
+}
+
 func (me *Policy) GetUserPolicyByUserId(userId string) *UserPolicy {
 	for _, userPolicy := range me.User {
 		if userPolicy.Id == userId {
@@ -44,6 +84,12 @@ func (me *Policy) GetUserPolicyByUserId(userId string) *UserPolicy {
 	return nil
 }
 
+// PolicyFlags contains settings which apply to all users.
+//
+// Many of these flags are merely defaults, which can be overridden for a given user via the corresponding UserPolicy field
+// (e.g. UserPolicy.ForbidRoomCreation for ForbidRoomCreation).
+// A UserPolicy only overrides a flag when its corresponding (pointer) field is set.
+// If that field is nil, the flag applies to the user, just like it does to users without a UserPolicy.
 type PolicyFlags struct {
 	// AllowCustomUserDisplayNames tells whether users are allowed to have display names,
 	// which deviate from the ones in the policy.
@@ -53,6 +99,11 @@ type PolicyFlags struct {
 	// which deviate from the ones in the policy.
 	AllowCustomUserAvatars bool `json:"allowCustomUserAvatars"`
 
+	// AllowCustomUserAccountData tells whether users are allowed to change the account data (and room tags) that the policy defines for them.
+	// If allowed, the policy's account data and room tags are only used for seeding (setting them when they're missing).
+	// Otherwise, they are enforced (any changes get reverted).
+	AllowCustomUserAccountData bool `json:"allowCustomUserAccountData"`
+
 	// AllowCustomPassthroughUserPasswords tells if managed users of AuthType=UserAuthTypePassthrough can change their password.
 	// This is possible, because their password is stored and managed on the actual homeserver.
 	// We can let password-changing requests go through.
@@ -68,32 +119,133 @@ type PolicyFlags struct {
 	AllowUnauthenticatedPasswordResets bool `json:"allowUnauthenticatedPasswordResets"`
 
 	// ForbidRoomCreation tells whether users are forbidden from creating rooms.
-	// When there's a dedicated `UserPolicy` for the user, that one takes precedence over this default.
 	ForbidRoomCreation bool `json:"forbidRoomCreation"`
 
 	// ForbidEncryptedRoomCreation tells whether users are forbidden from creating encrypted rooms, and from switching rooms from unencrypted to encrypted.
-	// When there's a dedicated `UserPolicy` for the user, that one takes precedence over this default.
 	ForbidEncryptedRoomCreation bool `json:"forbidEncryptedRoomCreation"`
 
 	// ForbidUnencryptedRoomCreation tells whether users are forbidden from creating unencrypted rooms.
-	// When there's a dedicated `UserPolicy` for the user, that one takes precedence over this default.
 	ForbidUnencryptedRoomCreation bool `json:"forbidUnencryptedRoomCreation"`
 
 	// Allow3pidLogin tells whether login requests using an email address or phone number will be allowed to go through unmodified.
 	// Enabling this may have security implications.
 	// With this setting enabled, you're completely skipping matrix-corporal's login checks (`active` flag in the user policy, etc).
 	Allow3pidLogin bool `json:"allow3pidLogin"`
+
+	// HiddenRoomIds contains a list of room ids, which are hidden from all users.
+	// Hidden rooms are removed from `/sync` responses and their `/messages` and `/context` APIs are inaccessible.
+	HiddenRoomIds []string `json:"hiddenRoomIds"`
+
+	// HiddenEventTypes contains a list of event types, which are hidden from all users.
+	// Events of these types are removed from `/sync`, `/messages` and `/context` responses.
+	// A trailing `*` matches any event type with the given prefix (e.g. `m.call.*`).
+	HiddenEventTypes []string `json:"hiddenEventTypes"`
+
+	// InviteRestriction controls who users are allowed to invite (into existing rooms or while creating new ones).
+	// It's one of the `InviteRestriction*` constants. If empty, InviteRestrictionNone is assumed.
+	InviteRestriction string `json:"inviteRestriction"`
+
+	// InviteAllowedDomains contains the list of domains that users are allowed to invite users from,
+	// when InviteRestriction = InviteRestrictionAllowedDomains.
+	InviteAllowedDomains []string `json:"inviteAllowedDomains"`
+
+	// JoinAllowedDomains contains the list of domains hosting rooms that users are allowed to join.
+	// If empty, rooms on any domain (which is not in JoinForbiddenDomains) can be joined.
+	JoinAllowedDomains []string `json:"joinAllowedDomains"`
+
+	// JoinForbiddenDomains contains the list of domains hosting rooms that users are forbidden from joining.
+	JoinForbiddenDomains []string `json:"joinForbiddenDomains"`
+
+	// DirectMessageRestriction controls who users are allowed to start direct message rooms (`is_direct` rooms) with.
+	// It's one of the `DirectMessageRestriction*` constants. If empty, DirectMessageRestrictionNone is assumed.
+	DirectMessageRestriction string `json:"directMessageRestriction"`
+
+	// DirectMessageAllowedDomains contains the list of domains that users are allowed to start direct message rooms with,
+	// when DirectMessageRestriction = DirectMessageRestrictionAllowedDomains.
+	DirectMessageAllowedDomains []string `json:"directMessageAllowedDomains"`
+
+	// ForbidRoomDirectoryPublishing tells whether users are forbidden from publishing rooms to the public room directory.
+	ForbidRoomDirectoryPublishing bool `json:"forbidRoomDirectoryPublishing"`
+
+	// ForbidAliasCreation tells whether users are forbidden from creating room aliases.
+	ForbidAliasCreation bool `json:"forbidAliasCreation"`
+
+	// AllowedAliasPatterns contains a list of regular expressions that room aliases (e.g. `#room:example.com`) created by users need to match.
+	// Patterns need to match the whole alias, not just a part of it.
+	// If empty, any alias can be created (unless alias creation is forbidden).
+	AllowedAliasPatterns []string `json:"allowedAliasPatterns"`
+
+	// allowedAliasPatternsCompiled contains the compiled AllowedAliasPatterns (see compileRegexPatterns()).
+	// It's populated during policy validation.
+	allowedAliasPatternsCompiled []*regexp.Regexp
+
+	// ForbidMediaUpload tells whether users are forbidden from uploading media.
+	ForbidMediaUpload bool `json:"forbidMediaUpload"`
+
+	// MediaUploadMaxSizeBytes specifies the maximum size (in bytes) of media files that users can upload.
+	// A value of 0 means no limit.
+	MediaUploadMaxSizeBytes int64 `json:"mediaUploadMaxSizeBytes"`
+
+	// MediaUploadAllowedContentTypes contains a list of content types (e.g. `image/png`) that users can upload.
+	// A trailing `*` matches any content type with the given prefix (e.g. `image/*`).
+	// If empty, all content types are allowed.
+	MediaUploadAllowedContentTypes []string `json:"mediaUploadAllowedContentTypes"`
+
+	// AllowManagedRoomUpgrades tells whether users are allowed to upgrade (or otherwise tombstone) managed rooms.
+	//
+	// Upgrading replaces a room with a new one, but the policy (ManagedRoomIds, JoinedRooms) keeps referencing the old room.
+	// When allowed, upgrades are reported (via the HTTP API and hooks), so that the policy can be updated.
+	AllowManagedRoomUpgrades bool `json:"allowManagedRoomUpgrades"`
+
+	// Forbid3pidChanges tells whether managed users are forbidden from adding or removing 3pids (email addresses, phone numbers) to their account.
+	//
+	// Users whose 3pids are managed by the policy (see UserPolicy.Threepids) can never change them.
+	Forbid3pidChanges bool `json:"forbid3pidChanges"`
+}
+
+type ExclusiveRoom struct {
+	RoomId string `json:"roomId"`
+
+	// RemovalMethod's value is supposed to be one of the `ExclusiveRoomRemovalMethod*` constants
+	RemovalMethod string `json:"removalMethod"`
 }
 
 type RoomState struct {
 	RoomId     string `json:"roomId"`
 	PowerLevel int    `json:"powerLevel"`
+
+	// ActiveFrom and ActiveUntil optionally limit the time window ([ActiveFrom, ActiveUntil)), during which the user is supposed to be in the room
+	ActiveFrom  *time.Time `json:"activeFrom"`
+	ActiveUntil *time.Time `json:"activeUntil"`
+
+	// Tags contains the tags (e.g. `m.favourite`, `m.lowpriority`) that the user should have on this room.
+	// If not defined, the user's tags for this room are not managed (reconciled).
+	Tags map[string]matrix.RoomTag `json:"tags"`
+}
+
+// UserThreepid is a third-party identifier (email address, phone number) associated with a user account
+type UserThreepid struct {
+	// Medium is one of the `ThreepidMedium*` constants
+	Medium  string `json:"medium"`
+	Address string `json:"address"`
+}
+
+// UserRateLimitOverride specifies custom message rate limits for a user.
+// A value of 0 for both fields means no rate limiting at all.
+type UserRateLimitOverride struct {
+	MessagesPerSecond int `json:"messagesPerSecond"`
+	BurstCount        int `json:"burstCount"`
 }
 
 type UserPolicy struct {
 	Id     string `json:"id"`
 	Active bool   `json:"active"`
 
+	// ActiveFrom and ActiveUntil optionally limit the time window ([ActiveFrom, ActiveUntil)), during which an `Active` user is actually active.
+	// Outside of it, the user is treated as inactive.
+	ActiveFrom  *time.Time `json:"activeFrom"`
+	ActiveUntil *time.Time `json:"activeUntil"`
+
 	// AuthType's value is supposed to be one the `UserAuthType*` constants
 	AuthType string `json:"authType"`
 
@@ -111,6 +263,14 @@ type UserPolicy struct {
 
 	JoinedRooms []*RoomState `json:"joinedRooms"`
 
+	// AllowCustomDisplayName tells whether this user is allowed to have a display name, which is different than DisplayName.
+	// If not defined, PolicyFlags.AllowCustomUserDisplayNames applies.
+	AllowCustomDisplayName *bool `json:"allowCustomDisplayName"`
+
+	// AllowCustomAvatar tells whether this user is allowed to have an avatar, which is different than AvatarUri.
+	// If not defined, PolicyFlags.AllowCustomUserAvatars applies.
+	AllowCustomAvatar *bool `json:"allowCustomAvatar"`
+
 	// ForbidRoomCreation tells whether this user is forbidden from creating rooms.
 	ForbidRoomCreation *bool `json:"forbidRoomCreation"`
 
@@ -119,9 +279,116 @@ type UserPolicy struct {
 
 	// ForbidUnencryptedRoomCreation tells whether this user is forbidden from creating unencrypted rooms.
 	ForbidUnencryptedRoomCreation *bool `json:"forbidUnencryptedRoomCreation"`
+
+	// HiddenRoomIds contains a list of room ids, which are hidden from this user (in addition to PolicyFlags.HiddenRoomIds).
+	HiddenRoomIds []string `json:"hiddenRoomIds"`
+
+	// VisibleRoomIds restricts this user to only seeing the listed rooms.
+	// If not defined, all rooms (except for hidden ones) are visible.
+	VisibleRoomIds *[]string `json:"visibleRoomIds"`
+
+	// HiddenEventTypes contains a list of event types, which are hidden from this user (in addition to PolicyFlags.HiddenEventTypes).
+	HiddenEventTypes []string `json:"hiddenEventTypes"`
+
+	// InviteRestriction controls who this user is allowed to invite. It's one of the `InviteRestriction*` constants.
+	InviteRestriction *string `json:"inviteRestriction"`
+
+	// InviteAllowedDomains contains the list of domains that this user is allowed to invite users from,
+	// when the invite restriction in effect is InviteRestrictionAllowedDomains.
+	InviteAllowedDomains *[]string `json:"inviteAllowedDomains"`
+
+	// JoinAllowedDomains contains the list of domains hosting rooms that this user is allowed to join.
+	// An empty list means rooms on any domain (which is not forbidden) can be joined.
+	JoinAllowedDomains *[]string `json:"joinAllowedDomains"`
+
+	// JoinForbiddenDomains contains the list of domains hosting rooms that this user is forbidden from joining.
+	JoinForbiddenDomains *[]string `json:"joinForbiddenDomains"`
+
+	// DirectMessageRestriction controls who this user is allowed to start direct message rooms with.
+	// It's one of the `DirectMessageRestriction*` constants.
+	DirectMessageRestriction *string `json:"directMessageRestriction"`
+
+	// DirectMessageAllowedDomains contains the list of domains that this user is allowed to start direct message rooms with,
+	// when the direct message restriction in effect is DirectMessageRestrictionAllowedDomains.
+	DirectMessageAllowedDomains *[]string `json:"directMessageAllowedDomains"`
+
+	// ForbidRoomDirectoryPublishing tells whether this user is forbidden from publishing rooms to the public room directory.
+	ForbidRoomDirectoryPublishing *bool `json:"forbidRoomDirectoryPublishing"`
+
+	// ForbidAliasCreation tells whether this user is forbidden from creating room aliases.
+	ForbidAliasCreation *bool `json:"forbidAliasCreation"`
+
+	// AllowedAliasPatterns contains a list of regular expressions that room aliases created by this user need to match.
+	// Patterns need to match the whole alias, not just a part of it.
+	AllowedAliasPatterns *[]string `json:"allowedAliasPatterns"`
+
+	// allowedAliasPatternsCompiled contains the compiled AllowedAliasPatterns (see compileRegexPatterns()).
+	// It's populated during policy validation.
+	allowedAliasPatternsCompiled []*regexp.Regexp
+
+	// ForbidMediaUpload tells whether this user is forbidden from uploading media.
+	ForbidMediaUpload *bool `json:"forbidMediaUpload"`
+
+	// MediaUploadMaxSizeBytes specifies the maximum size (in bytes) of media files that this user can upload.
+	// A value of 0 means no limit.
+	MediaUploadMaxSizeBytes *int64 `json:"mediaUploadMaxSizeBytes"`
+
+	// MediaUploadAllowedContentTypes contains a list of content types that this user can upload.
+	MediaUploadAllowedContentTypes *[]string `json:"mediaUploadAllowedContentTypes"`
+
+	// Threepids contains the 3pids (email addresses, phone numbers) that this user account should have.
+	// If not defined, the user's 3pids are not managed (reconciled).
+	Threepids *[]UserThreepid `json:"threepids"`
+
+	// IsServerAdmin tells whether this user should be a homeserver administrator.
+	// If not defined, the user's administrator status is not managed (reconciled).
+	IsServerAdmin *bool `json:"isServerAdmin"`
+
+	// RateLimitOverride exempts this user from the homeserver's default message rate limits.
+	// If not defined, the user's rate limit override is not managed (reconciled), unless RemoveRateLimitOverride says so.
+	RateLimitOverride *UserRateLimitOverride `json:"rateLimitOverride"`
+
+	// RemoveRateLimitOverride tells whether any existing rate limit override for this user should be removed,
+	// making the user subject to the homeserver's default rate limits.
+	// It cannot be combined with RateLimitOverride.
+	RemoveRateLimitOverride bool `json:"removeRateLimitOverride"`
+
+	// AccountData contains global account data (e.g. `m.ignored_user_list`), keyed by type, that this user should have.
+	// Account data types not listed here are not managed (reconciled).
+	AccountData map[string]map[string]interface{} `json:"accountData"`
+
+	// PushRules contains push rules that this user should have (in addition to Policy.PushRules).
+	// A rule here takes precedence over a rule of the same kind and id in Policy.PushRules.
+	PushRules []*PushRule `json:"pushRules"`
+
+	// MaxDevices is the maximum number of devices (sessions) this user can have at the same time.
+	// A value of 0 means no limit.
+	MaxDevices int `json:"maxDevices"`
+
+	// DeviceLimitAction controls what happens when a login would make the user exceed MaxDevices.
+	// It's one of the `DeviceLimitAction*` constants (defaults to DeviceLimitActionDeny).
+	DeviceLimitAction string `json:"deviceLimitAction"`
+
+	// AllowedDeviceDisplayNamePatterns contains a list of regular expressions that device display names need to match.
+	// Patterns need to match the whole display name, not just a part of it.
+	// An empty list means all device display names are allowed.
+	AllowedDeviceDisplayNamePatterns []string `json:"allowedDeviceDisplayNamePatterns"`
+
+	// allowedDeviceDisplayNamePatternsCompiled contains the compiled AllowedDeviceDisplayNamePatterns (see compileRegexPatterns()).
+	// It's populated during policy validation.
+	allowedDeviceDisplayNamePatternsCompiled []*regexp.Regexp
+
+	// Deactivation controls what happens when this user gets deactivated.
+	// If not defined, Policy.UserDeactivation applies.
+	Deactivation *UserDeactivation `json:"deactivation"`
 }
 
-func (me UserPolicy) Validate() error {
+// IsRateLimitOverrideManaged tells whether the rate limit override of this user is managed (reconciled)
+func (me UserPolicy) IsRateLimitOverrideManaged() bool {
+	return me.RateLimitOverride != nil || me.RemoveRateLimitOverride
+}
+
+func (me *UserPolicy) Validate() error {
 	if me.Id == "" {
 		return fmt.Errorf("user has no id")
 	}
@@ -130,5 +397,159 @@ func (me UserPolicy) Validate() error {
 		return fmt.Errorf("`%s` is an invalid auth type", me.AuthType)
 	}
 
+	err := validateTimeBounds(me.ActiveFrom, me.ActiveUntil)
+	if err != nil {
+		return fmt.Errorf("invalid activity time window: %s", err)
+	}
+
+	for _, room := range me.JoinedRooms {
+		err := validateTimeBounds(room.ActiveFrom, room.ActiveUntil)
+		if err != nil {
+			return fmt.Errorf("invalid membership time window for room `%s`: %s", room.RoomId, err)
+		}
+	}
+
+	err = validateWildcardPatterns(me.HiddenEventTypes)
+	if err != nil {
+		return fmt.Errorf("invalid hidden event types: %s", err)
+	}
+
+	if me.InviteRestriction != nil && !isKnownInviteRestriction(*me.InviteRestriction) {
+		return fmt.Errorf("`%s` is an invalid invite restriction", *me.InviteRestriction)
+	}
+
+	if me.DirectMessageRestriction != nil && !isKnownDirectMessageRestriction(*me.DirectMessageRestriction) {
+		return fmt.Errorf("`%s` is an invalid direct message restriction", *me.DirectMessageRestriction)
+	}
+
+	if me.MediaUploadAllowedContentTypes != nil {
+		err := validateWildcardPatterns(*me.MediaUploadAllowedContentTypes)
+		if err != nil {
+			return fmt.Errorf("invalid media upload allowed content types: %s", err)
+		}
+	}
+
+	if me.AllowedAliasPatterns != nil {
+		me.allowedAliasPatternsCompiled, err = compileRegexPatterns(*me.AllowedAliasPatterns)
+		if err != nil {
+			return fmt.Errorf("invalid allowed alias patterns: %s", err)
+		}
+	}
+
+	if me.RateLimitOverride != nil {
+		if me.RemoveRateLimitOverride {
+			return fmt.Errorf("rateLimitOverride and removeRateLimitOverride cannot be used together")
+		}
+
+		if me.RateLimitOverride.MessagesPerSecond < 0 || me.RateLimitOverride.BurstCount < 0 {
+			return fmt.Errorf("rate limit override values cannot be negative")
+		}
+	}
+
+	for accountDataType := range me.AccountData {
+		if accountDataType == "" {
+			return fmt.Errorf("account data types cannot be empty")
+		}
+
+		if strings.HasPrefix(accountDataType, reservedAccountDataTypePrefix) {
+			return fmt.Errorf("account data type `%s` is reserved for internal use", accountDataType)
+		}
+	}
+
+	for _, room := range me.JoinedRooms {
+		for tag := range room.Tags {
+			if tag == "" {
+				return fmt.Errorf("room tags cannot be empty (found in room `%s`)", room.RoomId)
+			}
+		}
+	}
+
+	err = me.validateDeviceRestrictions()
+	if err != nil {
+		return err
+	}
+
+	for _, pushRule := range me.PushRules {
+		err := pushRule.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid push rule: %s", err)
+		}
+	}
+
+	if me.Deactivation != nil {
+		err := me.Deactivation.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid deactivation options: %s", err)
+		}
+	}
+
+	if me.Threepids != nil {
+		for _, threepid := range *me.Threepids {
+			if !isKnownThreepidMedium(threepid.Medium) {
+				return fmt.Errorf("`%s` is an invalid 3pid medium", threepid.Medium)
+			}
+
+			if threepid.Address == "" {
+				return fmt.Errorf("3pid of medium `%s` has no address", threepid.Medium)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateWildcardPatterns ensures that the given patterns (event types, content types, etc.) are valid.
+// A `*` wildcard is only supported at the end of a pattern.
+func validateWildcardPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return fmt.Errorf("empty pattern")
+		}
+
+		if strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+			return fmt.Errorf("`%s` contains a wildcard which is not at the end", pattern)
+		}
+	}
 	return nil
 }
+
+// compileRegexPatterns compiles the given regular expressions.
+// The compiled expressions are anchored, so they only match whole values (not just a part of them).
+func compileRegexPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		regex, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", pattern))
+		if err != nil {
+			return nil, fmt.Errorf("`%s` is not a valid regular expression: %s", pattern, err)
+		}
+		compiled = append(compiled, regex)
+	}
+	return compiled, nil
+}
+
+// matchesRegexPatterns tells whether a value matches at least one of the given regular expressions.
+//
+// Patterns are compiled during policy validation (compiledPatterns).
+// For policies which were not validated (compiledPatterns being nil), they get compiled on the fly.
+// Invalid patterns never match.
+func matchesRegexPatterns(value string, patterns []string, compiledPatterns []*regexp.Regexp) bool {
+	if compiledPatterns == nil {
+		compiledPatterns, _ = compileRegexPatterns(patterns)
+	}
+
+	for _, regex := range compiledPatterns {
+		if regex.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesWildcardPattern tells whether a value (event type, content type, etc.) matches a given pattern (an exact value or a prefix ending with `*`)
+func matchesWildcardPattern(value string, pattern string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	}
+	return value == pattern
+}
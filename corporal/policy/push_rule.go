@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"devture-matrix-corporal/corporal/util"
+	"fmt"
+	"strings"
+)
+
+const (
+	PushRuleKindOverride  = "override"
+	PushRuleKindContent   = "content"
+	PushRuleKindRoom      = "room"
+	PushRuleKindSender    = "sender"
+	PushRuleKindUnderride = "underride"
+)
+
+var knownPushRuleKinds = []string{
+	PushRuleKindOverride,
+	PushRuleKindContent,
+	PushRuleKindRoom,
+	PushRuleKindSender,
+	PushRuleKindUnderride,
+}
+
+func isKnownPushRuleKind(kind string) bool {
+	return util.IsStringInArray(kind, knownPushRuleKinds)
+}
+
+// PushRule is a push rule (in the `global` scope) that managed users should have.
+//
+// Server-default rules (those whose RuleId starts with a `.`, like `.m.rule.master`) can be declared as well,
+// but only their Actions and Enabled status can be controlled.
+type PushRule struct {
+	// Kind is one of the `PushRuleKind*` constants
+	Kind string `json:"kind"`
+
+	// RuleId identifies the rule. For `room` rules, it's the room id. For `sender` rules, it's the user id.
+	RuleId string `json:"ruleId"`
+
+	Actions    []interface{}            `json:"actions"`
+	Conditions []map[string]interface{} `json:"conditions"`
+
+	// Pattern is only relevant to `content` rules
+	Pattern string `json:"pattern"`
+
+	// Enabled tells whether the rule is enabled. If not defined, the rule is enabled.
+	Enabled *bool `json:"enabled"`
+
+	// Enforced tells whether the rule is kept exactly as defined and users are prevented from changing it.
+	// Rules which are not enforced are only created if missing. Users are free to change or delete them afterwards.
+	Enforced bool `json:"enforced"`
+}
+
+func (me PushRule) IsEnabled() bool {
+	return me.Enabled == nil || *me.Enabled
+}
+
+// IsServerDefault tells whether this is a server-default rule (e.g. `.m.rule.master`)
+func (me PushRule) IsServerDefault() bool {
+	return strings.HasPrefix(me.RuleId, ".")
+}
+
+func (me PushRule) Validate() error {
+	if !isKnownPushRuleKind(me.Kind) {
+		return fmt.Errorf("`%s` is an invalid push rule kind", me.Kind)
+	}
+
+	if me.RuleId == "" {
+		return fmt.Errorf("push rule of kind `%s` has no rule id", me.Kind)
+	}
+
+	if me.Actions == nil {
+		return fmt.Errorf("push rule `%s` has no actions (use an empty list to not notify)", me.RuleId)
+	}
+
+	if me.IsServerDefault() {
+		if len(me.Conditions) != 0 || me.Pattern != "" {
+			return fmt.Errorf("push rule `%s` is a server-default rule, so only its actions and enabled status can be controlled", me.RuleId)
+		}
+		return nil
+	}
+
+	if me.Kind == PushRuleKindContent && me.Pattern == "" {
+		return fmt.Errorf("push rule `%s` is a content rule, so it needs a pattern", me.RuleId)
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package policy
+
+import (
+	"devture-matrix-corporal/corporal/matrix"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RoomAliasLookuper looks up the room id that a room alias points to
+type RoomAliasLookuper interface {
+	ResolveRoomAlias(roomAlias string) (string, error)
+}
+
+// RoomAliasResolver resolves room aliases (e.g. `#room:example.com`), which may be used in the policy instead of room ids.
+//
+// Results are cached. Aliases are periodically looked up again (including ones which previously failed to resolve),
+// so that changes to where they point to are noticed. Change listeners (see AddChangeListener) get notified about such changes.
+type RoomAliasResolver struct {
+	logger          *logrus.Logger
+	lookuper        RoomAliasLookuper
+	refreshInterval time.Duration
+
+	roomIdByAlias     map[string]string
+	unresolvedAliases map[string]bool
+	lock              sync.RWMutex
+
+	changeListeners []func()
+	lockListeners   sync.Mutex
+
+	refreshTicker *time.Ticker
+	refreshCancel chan bool
+}
+
+func NewRoomAliasResolver(
+	logger *logrus.Logger,
+	lookuper RoomAliasLookuper,
+	refreshInterval time.Duration,
+) *RoomAliasResolver {
+	return &RoomAliasResolver{
+		logger:          logger,
+		lookuper:        lookuper,
+		refreshInterval: refreshInterval,
+
+		roomIdByAlias:     map[string]string{},
+		unresolvedAliases: map[string]bool{},
+	}
+}
+
+func (me *RoomAliasResolver) Start() error {
+	me.refreshTicker = time.NewTicker(me.refreshInterval)
+	me.refreshCancel = make(chan bool, 1)
+
+	go me.refreshPeriodically(me.refreshTicker, me.refreshCancel)
+
+	me.logger.Infof("Started room alias resolver (refreshing every %s)", me.refreshInterval)
+
+	return nil
+}
+
+func (me *RoomAliasResolver) Stop() {
+	if me.refreshTicker != nil {
+		me.refreshTicker.Stop()
+		me.refreshCancel <- true
+
+		me.refreshTicker = nil
+		me.refreshCancel = nil
+	}
+
+	me.logger.Infof("Stopped room alias resolver")
+}
+
+// Resolve returns the room id for the given room alias.
+// Room ids are returned as-is.
+func (me *RoomAliasResolver) Resolve(roomIdOrAlias string) (string, error) {
+	if !matrix.IsRoomAlias(roomIdOrAlias) {
+		return roomIdOrAlias, nil
+	}
+
+	me.lock.RLock()
+	roomId, exists := me.roomIdByAlias[roomIdOrAlias]
+	me.lock.RUnlock()
+
+	if exists {
+		return roomId, nil
+	}
+
+	return me.lookup(roomIdOrAlias)
+}
+
+// AddChangeListener registers a function to call whenever (during periodic refreshing) an alias is found to point somewhere new
+func (me *RoomAliasResolver) AddChangeListener(listener func()) {
+	me.lockListeners.Lock()
+	defer me.lockListeners.Unlock()
+
+	me.changeListeners = append(me.changeListeners, listener)
+}
+
+func (me *RoomAliasResolver) lookup(roomAlias string) (string, error) {
+	roomId, err := me.lookuper.ResolveRoomAlias(roomAlias)
+
+	me.lock.Lock()
+	defer me.lock.Unlock()
+
+	if err != nil {
+		if _, exists := me.roomIdByAlias[roomAlias]; !exists {
+			// We'll retry during the next refresh.
+			me.unresolvedAliases[roomAlias] = true
+		}
+		return "", err
+	}
+
+	me.roomIdByAlias[roomAlias] = roomId
+	delete(me.unresolvedAliases, roomAlias)
+
+	return roomId, nil
+}
+
+func (me *RoomAliasResolver) refreshPeriodically(ticker *time.Ticker, cancel chan bool) {
+	for {
+		select {
+		case <-ticker.C:
+			me.refresh()
+		case <-cancel:
+			return
+		}
+	}
+}
+
+func (me *RoomAliasResolver) refresh() {
+	me.lock.RLock()
+	roomIdByAlias := make(map[string]string, len(me.roomIdByAlias)+len(me.unresolvedAliases))
+	for roomAlias, roomId := range me.roomIdByAlias {
+		roomIdByAlias[roomAlias] = roomId
+	}
+	for roomAlias := range me.unresolvedAliases {
+		roomIdByAlias[roomAlias] = ""
+	}
+	me.lock.RUnlock()
+
+	isChanged := false
+
+	for roomAlias, previousRoomId := range roomIdByAlias {
+		roomId, err := me.lookup(roomAlias)
+		if err != nil {
+			if previousRoomId == "" {
+				me.logger.Warnf("Failed resolving room alias %s: %s", roomAlias, err)
+			} else {
+				// We'd rather keep using the last known room id than break policy checks.
+				me.logger.Warnf("Failed refreshing room alias %s (still pointing to %s): %s", roomAlias, previousRoomId, err)
+			}
+			continue
+		}
+
+		if roomId != previousRoomId {
+			me.logger.Infof("Room alias %s now points to %s (was: %s)", roomAlias, roomId, previousRoomId)
+			isChanged = true
+		}
+	}
+
+	if !isChanged {
+		return
+	}
+
+	me.lockListeners.Lock()
+	listeners := me.changeListeners
+	me.lockListeners.Unlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// GetRoomReferences returns all rooms (room ids or room aliases) referenced in `ManagedRoomIds`, `JoinedRooms`,
+// `ExclusiveRooms` and `RoomPowerLevels`. These are the places where room aliases can be used instead of room ids.
+func (me Policy) GetRoomReferences() []string {
+	roomIdsOrAliases := make([]string, 0, len(me.ManagedRoomIds)+len(me.ExclusiveRooms)+len(me.RoomPowerLevels))
+
+	roomIdsOrAliases = append(roomIdsOrAliases, me.ManagedRoomIds...)
+
+	for _, userPolicy := range me.User {
+		for _, room := range userPolicy.JoinedRooms {
+			roomIdsOrAliases = append(roomIdsOrAliases, room.RoomId)
+		}
+	}
+
+	for _, exclusiveRoom := range me.ExclusiveRooms {
+		roomIdsOrAliases = append(roomIdsOrAliases, exclusiveRoom.RoomId)
+	}
+
+	for _, roomPowerLevels := range me.RoomPowerLevels {
+		roomIdsOrAliases = append(roomIdsOrAliases, roomPowerLevels.RoomId)
+	}
+
+	return roomIdsOrAliases
+}
+
+// HasRoomAliases tells whether room aliases are used (instead of room ids) anywhere in the policy
+func (me Policy) HasRoomAliases() bool {
+	for _, roomIdOrAlias := range me.GetRoomReferences() {
+		if matrix.IsRoomAlias(roomIdOrAlias) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithResolvedRoomAliases returns a copy of the policy, in which room aliases (see GetRoomReferences)
+// are replaced by the room ids they point to, according to the given resolve function.
+func (me Policy) WithResolvedRoomAliases(resolve func(roomIdOrAlias string) (string, error)) (*Policy, error) {
+	if !me.HasRoomAliases() {
+		return &me, nil
+	}
+
+	resolveRoomIdOrAlias := func(roomIdOrAlias string) (string, error) {
+		if !matrix.IsRoomAlias(roomIdOrAlias) {
+			return roomIdOrAlias, nil
+		}
+
+		roomId, err := resolve(roomIdOrAlias)
+		if err != nil {
+			return "", fmt.Errorf("failed resolving room alias `%s`: %s", roomIdOrAlias, err)
+		}
+		return roomId, nil
+	}
+
+	managedRoomIds := make([]string, 0, len(me.ManagedRoomIds))
+	for _, roomIdOrAlias := range me.ManagedRoomIds {
+		roomId, err := resolveRoomIdOrAlias(roomIdOrAlias)
+		if err != nil {
+			return nil, err
+		}
+		managedRoomIds = append(managedRoomIds, roomId)
+	}
+	me.ManagedRoomIds = managedRoomIds
+
+	users := make([]*UserPolicy, 0, len(me.User))
+	for _, userPolicy := range me.User {
+		userPolicyCopy := *userPolicy
+
+		joinedRooms := make([]*RoomState, 0, len(userPolicy.JoinedRooms))
+		for _, room := range userPolicy.JoinedRooms {
+			roomCopy := *room
+
+			roomId, err := resolveRoomIdOrAlias(room.RoomId)
+			if err != nil {
+				return nil, fmt.Errorf("user `%s`: %s", userPolicy.Id, err)
+			}
+			roomCopy.RoomId = roomId
+
+			joinedRooms = append(joinedRooms, &roomCopy)
+		}
+		userPolicyCopy.JoinedRooms = joinedRooms
+
+		users = append(users, &userPolicyCopy)
+	}
+	me.User = users
+
+	exclusiveRooms := make([]*ExclusiveRoom, 0, len(me.ExclusiveRooms))
+	for _, exclusiveRoom := range me.ExclusiveRooms {
+		exclusiveRoomCopy := *exclusiveRoom
+
+		roomId, err := resolveRoomIdOrAlias(exclusiveRoom.RoomId)
+		if err != nil {
+			return nil, err
+		}
+		exclusiveRoomCopy.RoomId = roomId
+
+		exclusiveRooms = append(exclusiveRooms, &exclusiveRoomCopy)
+	}
+	me.ExclusiveRooms = exclusiveRooms
+
+	roomPowerLevelsList := make([]*RoomPowerLevels, 0, len(me.RoomPowerLevels))
+	for _, roomPowerLevels := range me.RoomPowerLevels {
+		roomPowerLevelsCopy := *roomPowerLevels
+
+		roomId, err := resolveRoomIdOrAlias(roomPowerLevels.RoomId)
+		if err != nil {
+			return nil, err
+		}
+		roomPowerLevelsCopy.RoomId = roomId
+
+		roomPowerLevelsList = append(roomPowerLevelsList, &roomPowerLevelsCopy)
+	}
+	me.RoomPowerLevels = roomPowerLevelsList
+
+	return &me, nil
+}
@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"devture-matrix-corporal/corporal/matrix"
+)
+
+// RoomPowerLevels declares the room-wide power levels (the `m.room.power_levels` state event) of a managed room.
+//
+// Only the fields (and map entries) which are defined here are managed. Everything else is left untouched.
+// User power levels are managed separately, via each user's `JoinedRooms`.
+type RoomPowerLevels struct {
+	RoomId string `json:"roomId"`
+
+	Events        map[string]int `json:"events"`
+	EventsDefault *int           `json:"eventsDefault"`
+	StateDefault  *int           `json:"stateDefault"`
+	Invite        *int           `json:"invite"`
+	Kick          *int           `json:"kick"`
+	Ban           *int           `json:"ban"`
+	Redact        *int           `json:"redact"`
+	Notifications map[string]int `json:"notifications"`
+}
+
+// IsSatisfiedBy tells whether the given power levels content matches everything declared here
+func (me RoomPowerLevels) IsSatisfiedBy(content matrix.RoomPowerLevelsContent) bool {
+	if !isIntSatisfiedBy(me.EventsDefault, content.EventsDefault) ||
+		!isIntSatisfiedBy(me.StateDefault, content.StateDefault) ||
+		!isIntSatisfiedBy(me.Invite, content.Invite) ||
+		!isIntSatisfiedBy(me.Kick, content.Kick) ||
+		!isIntSatisfiedBy(me.Ban, content.Ban) ||
+		!isIntSatisfiedBy(me.Redact, content.Redact) {
+		return false
+	}
+
+	return isIntMapSatisfiedBy(me.Events, content.Events) && isIntMapSatisfiedBy(me.Notifications, content.Notifications)
+}
+
+// ToContent returns the power levels content (to be merged into an `m.room.power_levels` state event), which satisfies this declaration
+func (me RoomPowerLevels) ToContent() matrix.RoomPowerLevelsContent {
+	return matrix.RoomPowerLevelsContent{
+		Events:        me.Events,
+		EventsDefault: me.EventsDefault,
+		StateDefault:  me.StateDefault,
+		Invite:        me.Invite,
+		Kick:          me.Kick,
+		Ban:           me.Ban,
+		Redact:        me.Redact,
+		Notifications: me.Notifications,
+	}
+}
+
+func isIntSatisfiedBy(declared *int, actual *int) bool {
+	if declared == nil {
+		return true
+	}
+	return actual != nil && *actual == *declared
+}
+
+func isIntMapSatisfiedBy(declared map[string]int, actual map[string]int) bool {
+	for key, declaredValue := range declared {
+		actualValue, exists := actual[key]
+		if !exists || actualValue != declaredValue {
+			return false
+		}
+	}
+	return true
+}
@@ -1,17 +1,26 @@
 package policy
 
 import (
+	"devture-matrix-corporal/corporal/hook"
 	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
+// Store holds the current policy.
+//
+// Room aliases, which may be used in the policy instead of room ids, get resolved when a policy is set
+// (and again whenever the RoomAliasResolver notices a change), so that Get() returns a policy containing room ids only.
+// Policies with aliases which cannot be resolved are rejected during validation (see Validator).
 type Store struct {
-	logger    *logrus.Logger
-	validator *Validator
+	logger            *logrus.Logger
+	validator         *Validator
+	roomAliasResolver *RoomAliasResolver
+	hookStore         *hook.Store
 
-	policy     *Policy
-	lockPolicy sync.RWMutex
+	policy         *Policy
+	resolvedPolicy *Policy
+	lockPolicy     sync.RWMutex
 
 	listenerChannels []chan *Policy
 	lockListeners    sync.RWMutex
@@ -20,19 +29,37 @@ type Store struct {
 func NewStore(
 	logger *logrus.Logger,
 	validator *Validator,
+	roomAliasResolver *RoomAliasResolver,
+	hookStore *hook.Store,
 ) *Store {
-	return &Store{
-		logger:    logger,
-		validator: validator,
+	instance := &Store{
+		logger:            logger,
+		validator:         validator,
+		roomAliasResolver: roomAliasResolver,
+		hookStore:         hookStore,
 
 		listenerChannels: make([]chan *Policy, 0),
 	}
+
+	roomAliasResolver.AddChangeListener(instance.refreshResolvedPolicy)
+
+	return instance
 }
 
+// Get returns the current policy (which may be nil), with room aliases resolved to room ids.
+// This is what requests are supposed to be checked against (see Checker).
 func (me *Store) Get() *Policy {
 	me.lockPolicy.RLock()
 	defer me.lockPolicy.RUnlock()
 
+	return me.resolvedPolicy
+}
+
+// GetUnresolved returns the current policy (which may be nil) the way it was set (possibly containing room aliases)
+func (me *Store) GetUnresolved() *Policy {
+	me.lockPolicy.RLock()
+	defer me.lockPolicy.RUnlock()
+
 	return me.policy
 }
 
@@ -42,10 +69,23 @@ func (me *Store) Set(policy *Policy) error {
 		return err
 	}
 
+	resolvedPolicy := me.resolveRoomAliases(policy)
+
 	me.lockPolicy.Lock()
 	defer me.lockPolicy.Unlock()
 
+	// Hooks from all sources get merged and ordered in the hook store, so the policy's hooks need to go there as well.
+	// This also guards against hooks with conflicting IDs having been added to the store since validation.
+	err = me.hookStore.Set(hook.StoreSourcePolicy, policy.Hooks)
+	if err != nil {
+		return err
+	}
+
 	me.policy = policy
+	me.resolvedPolicy = resolvedPolicy
+
+	// Listeners (the reconciler, etc.) get the policy the way it was set.
+	// The reconciler resolves room aliases by itself, right before acting on them.
 
 	for _, channel := range me.listenerChannels {
 		// Do it asynchronously. We don't want to block here..
@@ -57,6 +97,45 @@ func (me *Store) Set(policy *Policy) error {
 	return nil
 }
 
+func (me *Store) resolveRoomAliases(policy *Policy) *Policy {
+	resolvedPolicy, err := policy.WithResolvedRoomAliases(func(roomAlias string) (string, error) {
+		roomId, err := me.roomAliasResolver.Resolve(roomAlias)
+		if err != nil {
+			// Validation made sure that all aliases resolve and the resolver keeps the last known room ids, so this is not expected to happen.
+			// If it does, the resolver retries in the background and lets us know (see refreshResolvedPolicy) when it succeeds.
+			me.logger.Warnf("Failed resolving room alias %s (will retry later): %s", roomAlias, err)
+			return roomAlias, nil
+		}
+		return roomId, nil
+	})
+	if err != nil {
+		// Our resolve function never fails, so this is not expected to happen.
+		return policy
+	}
+
+	return resolvedPolicy
+}
+
+// refreshResolvedPolicy resolves the room aliases in the current policy again
+func (me *Store) refreshResolvedPolicy() {
+	policy := me.GetUnresolved()
+	if policy == nil || !policy.HasRoomAliases() {
+		return
+	}
+
+	resolvedPolicy := me.resolveRoomAliases(policy)
+
+	me.lockPolicy.Lock()
+	defer me.lockPolicy.Unlock()
+
+	if me.policy != policy {
+		// A new policy was set in the meantime (and resolved).
+		return
+	}
+
+	me.resolvedPolicy = resolvedPolicy
+}
+
 func (me *Store) GetNotificationChannel() chan *Policy {
 	me.lockListeners.Lock()
 	defer me.lockListeners.Unlock()
@@ -0,0 +1,22 @@
+package policy
+
+import (
+	"devture-matrix-corporal/corporal/util"
+)
+
+const (
+	// ThreepidMediumEmail is the medium for email address 3pids
+	ThreepidMediumEmail = "email"
+
+	// ThreepidMediumMsisdn is the medium for phone number 3pids (in international format, without a leading `+`)
+	ThreepidMediumMsisdn = "msisdn"
+)
+
+var knownThreepidMediums = []string{
+	ThreepidMediumEmail,
+	ThreepidMediumMsisdn,
+}
+
+func isKnownThreepidMedium(medium string) bool {
+	return util.IsStringInArray(medium, knownThreepidMediums)
+}
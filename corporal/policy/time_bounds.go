@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+)
+
+// isWithinTimeBounds tells whether the given time is within the [activeFrom, activeUntil) time window.
+// Undefined bounds are open-ended.
+func isWithinTimeBounds(t time.Time, activeFrom *time.Time, activeUntil *time.Time) bool {
+	if activeFrom != nil && t.Before(*activeFrom) {
+		return false
+	}
+
+	if activeUntil != nil && !t.Before(*activeUntil) {
+		return false
+	}
+
+	return true
+}
+
+func validateTimeBounds(activeFrom *time.Time, activeUntil *time.Time) error {
+	if activeFrom != nil && activeUntil != nil && !activeFrom.Before(*activeUntil) {
+		return fmt.Errorf("activeFrom (%s) needs to be before activeUntil (%s)", activeFrom.Format(time.RFC3339), activeUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// IsActiveAt tells whether the user is active at the given time, taking ActiveFrom and ActiveUntil into account
+func (me UserPolicy) IsActiveAt(t time.Time) bool {
+	return me.Active && isWithinTimeBounds(t, me.ActiveFrom, me.ActiveUntil)
+}
+
+// GetJoinedRoomsActiveAt returns the rooms the user is supposed to be joined to at the given time.
+//
+// Users which are `Active`, but outside of their activity time window, are meant to leave all their (managed) rooms
+// before getting deactivated, so they get no rooms.
+func (me UserPolicy) GetJoinedRoomsActiveAt(t time.Time) []*RoomState {
+	rooms := make([]*RoomState, 0, len(me.JoinedRooms))
+	if me.Active && !me.IsActiveAt(t) {
+		return rooms
+	}
+
+	for _, room := range me.JoinedRooms {
+		if room.IsActiveAt(t) {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms
+}
+
+// IsActiveAt tells whether the room membership is in effect at the given time, taking ActiveFrom and ActiveUntil into account
+func (me RoomState) IsActiveAt(t time.Time) bool {
+	return isWithinTimeBounds(t, me.ActiveFrom, me.ActiveUntil)
+}
+
+// EffectiveAt returns a copy of the policy, in which time-bounded user activation and room memberships
+// are evaluated at the given time.
+//
+// In the returned policy, users which are outside of their activity time window are not `Active` and have no `JoinedRooms`.
+// For all other users, `JoinedRooms` only contains the room memberships which are in effect.
+func (me Policy) EffectiveAt(t time.Time) *Policy {
+	users := make([]*UserPolicy, 0, len(me.User))
+	for _, userPolicy := range me.User {
+		userPolicyCopy := *userPolicy
+		userPolicyCopy.Active = userPolicy.IsActiveAt(t)
+		userPolicyCopy.JoinedRooms = userPolicy.GetJoinedRoomsActiveAt(t)
+		users = append(users, &userPolicyCopy)
+	}
+
+	me.User = users
+
+	return &me
+}
+
+// GetNextTimeBoundaryAfter returns the earliest activeFrom/activeUntil time (of any user or room membership), which comes after the given time.
+// When the policy has no such time boundaries, nil is returned.
+func (me Policy) GetNextTimeBoundaryAfter(t time.Time) *time.Time {
+	var next *time.Time
+
+	consider := func(boundary *time.Time) {
+		if boundary == nil || !boundary.After(t) {
+			return
+		}
+		if next == nil || boundary.Before(*next) {
+			next = boundary
+		}
+	}
+
+	for _, userPolicy := range me.User {
+		consider(userPolicy.ActiveFrom)
+		consider(userPolicy.ActiveUntil)
+
+		for _, room := range userPolicy.JoinedRooms {
+			consider(room.ActiveFrom)
+			consider(room.ActiveUntil)
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"devture-matrix-corporal/corporal/util"
+	"fmt"
+)
+
+const (
+	// UserDeactivationLeaveRoomsManaged makes deactivated users leave the managed rooms they're not supposed to be in (according to JoinedRooms)
+	UserDeactivationLeaveRoomsManaged = "managed"
+
+	// UserDeactivationLeaveRoomsAll makes deactivated users leave unmanaged rooms as well
+	UserDeactivationLeaveRoomsAll = "all"
+)
+
+var knownUserDeactivationLeaveRooms = []string{
+	UserDeactivationLeaveRoomsManaged,
+	UserDeactivationLeaveRoomsAll,
+}
+
+func isKnownUserDeactivationLeaveRooms(leaveRooms string) bool {
+	return util.IsStringInArray(leaveRooms, knownUserDeactivationLeaveRooms)
+}
+
+// UserDeactivation controls what happens when a user account gets deactivated.
+//
+// Regardless of these options, deactivated users always get their access tokens revoked
+// and their display name prefixed with a deactivation marker.
+type UserDeactivation struct {
+	// LeaveRooms is one of the `UserDeactivationLeaveRooms*` constants.
+	// If not defined, UserDeactivationLeaveRoomsManaged applies.
+	LeaveRooms string `json:"leaveRooms"`
+
+	// LogoutDevices tells whether all of the user's devices (not just access tokens) should be deleted.
+	LogoutDevices bool `json:"logoutDevices"`
+
+	// RemoveAvatar tells whether the user's avatar should be removed.
+	RemoveAvatar bool `json:"removeAvatar"`
+
+	// RedactMessages tells whether the user's recent messages should be redacted.
+	RedactMessages bool `json:"redactMessages"`
+
+	// RedactMessagesLimit limits how many of the user's most recent events (per room) get redacted when RedactMessages is enabled.
+	// A value of 0 means "use the homeserver's default".
+	RedactMessagesLimit int `json:"redactMessagesLimit"`
+
+	// UseHomeserverDeactivation tells whether the user account should also be deactivated via the homeserver's own deactivation API.
+	// Accounts deactivated like this can no longer be used (or impersonated) in any way, until they get reactivated.
+	UseHomeserverDeactivation bool `json:"useHomeserverDeactivation"`
+
+	// Erase tells whether the homeserver should also erase the user's data (profile, messages for new room members, etc.) when deactivating.
+	// It's only possible with UseHomeserverDeactivation.
+	Erase bool `json:"erase"`
+}
+
+// ShouldLeaveUnmanagedRooms tells whether deactivated users should leave unmanaged rooms (in addition to managed ones)
+func (me UserDeactivation) ShouldLeaveUnmanagedRooms() bool {
+	return me.LeaveRooms == UserDeactivationLeaveRoomsAll
+}
+
+func (me UserDeactivation) Validate() error {
+	if me.LeaveRooms != "" && !isKnownUserDeactivationLeaveRooms(me.LeaveRooms) {
+		return fmt.Errorf("`%s` is an invalid leaveRooms value", me.LeaveRooms)
+	}
+
+	if me.RedactMessagesLimit < 0 {
+		return fmt.Errorf("redactMessagesLimit cannot be negative")
+	}
+
+	if me.Erase && !me.UseHomeserverDeactivation {
+		return fmt.Errorf("erase requires useHomeserverDeactivation")
+	}
+
+	return nil
+}
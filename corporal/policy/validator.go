@@ -1,17 +1,23 @@
 package policy
 
 import (
+	"devture-matrix-corporal/corporal/hook"
 	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/util"
 	"fmt"
 )
 
 type Validator struct {
 	homeserverDomainName string
+	hookStore            *hook.Store
+	roomAliasResolver    *RoomAliasResolver
 }
 
-func NewValidator(homeserverDomainName string) *Validator {
+func NewValidator(homeserverDomainName string, hookStore *hook.Store, roomAliasResolver *RoomAliasResolver) *Validator {
 	return &Validator{
 		homeserverDomainName: homeserverDomainName,
+		hookStore:            hookStore,
+		roomAliasResolver:    roomAliasResolver,
 	}
 }
 
@@ -20,6 +26,73 @@ func (me *Validator) Validate(policy *Policy) error {
 		return fmt.Errorf("found policy with schema version (%d) that we do not support", policy.SchemaVersion)
 	}
 
+	// Room aliases may be used instead of room ids.
+	// A policy referencing aliases which don't resolve (yet) is rejected, instead of having us act upon unknown rooms.
+	// Aliases pointing elsewhere later on are handled by the RoomAliasResolver (see Store).
+	err := validateRoomAliases(policy)
+	if err != nil {
+		return err
+	}
+
+	resolvedPolicy, err := policy.WithResolvedRoomAliases(me.roomAliasResolver.Resolve)
+	if err != nil {
+		return err
+	}
+
+	err = validateWildcardPatterns(policy.Flags.HiddenEventTypes)
+	if err != nil {
+		return fmt.Errorf("invalid hidden event types in policy flags: %s", err)
+	}
+
+	if policy.Flags.InviteRestriction != "" && !isKnownInviteRestriction(policy.Flags.InviteRestriction) {
+		return fmt.Errorf("`%s` is an invalid invite restriction in policy flags", policy.Flags.InviteRestriction)
+	}
+
+	if policy.Flags.DirectMessageRestriction != "" && !isKnownDirectMessageRestriction(policy.Flags.DirectMessageRestriction) {
+		return fmt.Errorf("`%s` is an invalid direct message restriction in policy flags", policy.Flags.DirectMessageRestriction)
+	}
+
+	err = validateWildcardPatterns(policy.Flags.MediaUploadAllowedContentTypes)
+	if err != nil {
+		return fmt.Errorf("invalid media upload allowed content types in policy flags: %s", err)
+	}
+
+	policy.Flags.allowedAliasPatternsCompiled, err = compileRegexPatterns(policy.Flags.AllowedAliasPatterns)
+	if err != nil {
+		return fmt.Errorf("invalid allowed alias patterns in policy flags: %s", err)
+	}
+
+	// Rooms are compared by room id, so that referencing the same room by alias in one place and by room id in another is fine.
+	for _, exclusiveRoom := range resolvedPolicy.ExclusiveRooms {
+		if !util.IsStringInArray(exclusiveRoom.RoomId, resolvedPolicy.ManagedRoomIds) {
+			return fmt.Errorf("exclusive room `%s` is not a managed room", exclusiveRoom.RoomId)
+		}
+
+		if !isKnownExclusiveRoomRemovalMethod(exclusiveRoom.RemovalMethod) {
+			return fmt.Errorf("`%s` is an invalid removal method for exclusive room `%s`", exclusiveRoom.RemovalMethod, exclusiveRoom.RoomId)
+		}
+	}
+
+	for _, roomPowerLevels := range resolvedPolicy.RoomPowerLevels {
+		if !util.IsStringInArray(roomPowerLevels.RoomId, resolvedPolicy.ManagedRoomIds) {
+			return fmt.Errorf("room `%s` has power levels defined, but is not a managed room", roomPowerLevels.RoomId)
+		}
+	}
+
+	for _, pushRule := range policy.PushRules {
+		err := pushRule.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid push rule: %s", err)
+		}
+	}
+
+	if policy.UserDeactivation != nil {
+		err := policy.UserDeactivation.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid user deactivation options: %s", err)
+		}
+	}
+
 	for _, userId := range policy.GetManagedUserIds() {
 		if !matrix.IsFullUserIdOfDomain(userId, me.homeserverDomainName) {
 			return fmt.Errorf(
@@ -44,29 +117,44 @@ func (me *Validator) Validate(policy *Policy) error {
 
 	hookIDToIndexMap := make(map[string]int)
 
-	for idx, hook := range policy.Hooks {
-		existingIndex, exists := hookIDToIndexMap[hook.ID]
+	for idx, hookObj := range policy.Hooks {
+		existingIndex, exists := hookIDToIndexMap[hookObj.ID]
 		if exists {
 			return fmt.Errorf(
 				"hook at index `%d` (ID = %s) has the same ID as the hook at index %d. Assign unique hook IDs to prevent confusion",
 				idx,
-				hook.ID,
+				hookObj.ID,
 				existingIndex,
 			)
 		}
 
-		err := hook.Validate()
+		err := hookObj.Validate()
 		if err != nil {
 			return fmt.Errorf(
 				"hook at index `%d` (ID = %s) is invalid: %s",
 				idx,
-				hook.ID,
+				hookObj.ID,
 				err,
 			)
 		}
 
-		hookIDToIndexMap[hook.ID] = idx
+		hookIDToIndexMap[hookObj.ID] = idx
 	}
 
+	// Hooks defined elsewhere (files, the HTTP API) share the same ID namespace.
+	err = me.hookStore.CheckIDsAreUnique(hook.StoreSourcePolicy, policy.Hooks)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validateRoomAliases(policy *Policy) error {
+	for _, roomIdOrAlias := range policy.GetRoomReferences() {
+		if matrix.IsRoomAlias(roomIdOrAlias) && !matrix.IsRoomAliasWellFormed(roomIdOrAlias) {
+			return fmt.Errorf("`%s` is not a valid room alias", roomIdOrAlias)
+		}
+	}
 	return nil
 }
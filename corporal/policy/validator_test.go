@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/userauth"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestValidatorRoomAliases(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	roomAliases := staticRoomAliasLookuper{
+		"#a:host": "!a:host",
+		"#b:host": "!b:host",
+	}
+
+	type testCase struct {
+		name          string
+		policy        *Policy
+		expectedValid bool
+	}
+
+	testCases := []testCase{
+		{
+			name: "resolvable aliases",
+			policy: &Policy{
+				SchemaVersion:  2,
+				ManagedRoomIds: []string{"#a:host", "!b:host"},
+			},
+			expectedValid: true,
+		},
+		{
+			name: "unresolvable alias",
+			policy: &Policy{
+				SchemaVersion:  2,
+				ManagedRoomIds: []string{"#a:host", "#missing:host"},
+			},
+			expectedValid: false,
+		},
+		{
+			name: "unresolvable alias in a user's joined rooms",
+			policy: &Policy{
+				SchemaVersion: 2,
+				User: []*UserPolicy{
+					{
+						Id:          "@a:host",
+						Active:      true,
+						AuthType:    userauth.UserAuthTypePassthrough,
+						JoinedRooms: []*RoomState{{RoomId: "#missing:host"}},
+					},
+				},
+			},
+			expectedValid: false,
+		},
+		{
+			name: "managed room by alias, exclusive room and power levels by room id",
+			policy: &Policy{
+				SchemaVersion:   2,
+				ManagedRoomIds:  []string{"#a:host"},
+				ExclusiveRooms:  []*ExclusiveRoom{{RoomId: "!a:host", RemovalMethod: ExclusiveRoomRemovalMethodKick}},
+				RoomPowerLevels: []*RoomPowerLevels{{RoomId: "!a:host"}},
+			},
+			expectedValid: true,
+		},
+		{
+			name: "managed room by room id, exclusive room and power levels by alias",
+			policy: &Policy{
+				SchemaVersion:   2,
+				ManagedRoomIds:  []string{"!a:host"},
+				ExclusiveRooms:  []*ExclusiveRoom{{RoomId: "#a:host", RemovalMethod: ExclusiveRoomRemovalMethodKick}},
+				RoomPowerLevels: []*RoomPowerLevels{{RoomId: "#a:host"}},
+			},
+			expectedValid: true,
+		},
+		{
+			name: "exclusive room which is not managed",
+			policy: &Policy{
+				SchemaVersion:  2,
+				ManagedRoomIds: []string{"#a:host"},
+				ExclusiveRooms: []*ExclusiveRoom{{RoomId: "#b:host", RemovalMethod: ExclusiveRoomRemovalMethodKick}},
+			},
+			expectedValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validator := NewValidator("host", hook.NewStore(), NewRoomAliasResolver(logger, roomAliases, time.Minute))
+
+			err := validator.Validate(tc.policy)
+			if tc.expectedValid && err != nil {
+				t.Errorf("expected the policy to be valid, got: %s", err)
+			}
+			if !tc.expectedValid && err == nil {
+				t.Errorf("expected the policy to be invalid")
+			}
+		})
+	}
+}
@@ -8,9 +8,31 @@ const (
 	ActionUserSetAvatar      = "user.set_avatar"
 	ActionUserActivate       = "user.activate"
 	ActionUserDeactivate     = "user.deactivate"
+	ActionUserSetThreepids   = "user.set_threepids"
+
+	ActionUserSetAccountData = "user.set_account_data"
+	ActionUserSetRoomTag     = "user.set_room_tag"
+	ActionUserRemoveRoomTag  = "user.remove_room_tag"
+	ActionUserSetPushRule    = "user.set_push_rule"
+
+	ActionUserLogoutDevices          = "user.logout_devices"
+	ActionUserDeleteDevices          = "user.delete_devices"
+	ActionUserRemoveAvatar           = "user.remove_avatar"
+	ActionUserRedactMessages         = "user.redact_messages"
+	ActionUserDeactivateOnHomeserver = "user.deactivate_on_homeserver"
+	ActionUserReactivateOnHomeserver = "user.reactivate_on_homeserver"
+
+	ActionUserPromoteToServerAdmin  = "user.promote_to_server_admin"
+	ActionUserDemoteFromServerAdmin = "user.demote_from_server_admin"
+
+	ActionUserSetRateLimitOverride    = "user.set_rate_limit_override"
+	ActionUserRemoveRateLimitOverride = "user.remove_rate_limit_override"
 
 	ActionRoomJoin                = "room.join"
 	ActionRoomLeave               = "room.leave"
 	ActionRoomUserSetPowerLevel   = "room.user_set_power_level"
 	ActionRoomUsersSetPowerLevels = "room.users_set_power_levels"
+	ActionRoomSetPowerLevels      = "room.set_power_levels"
+	ActionRoomKick                = "room.kick"
+	ActionRoomBan                 = "room.ban"
 )
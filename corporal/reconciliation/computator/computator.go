@@ -3,22 +3,35 @@ package computator
 import (
 	"devture-matrix-corporal/corporal/avatar"
 	"devture-matrix-corporal/corporal/connector"
+	"devture-matrix-corporal/corporal/matrix"
 	"devture-matrix-corporal/corporal/policy"
 	"devture-matrix-corporal/corporal/reconciliation"
 	"devture-matrix-corporal/corporal/userauth"
 	"devture-matrix-corporal/corporal/util"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type ReconciliationStateComputator struct {
-	logger *logrus.Logger
+	logger         *logrus.Logger
+	policyChecker  *policy.Checker
+	corporalUserId string
 }
 
-func NewReconciliationStateComputator(logger *logrus.Logger) *ReconciliationStateComputator {
+func NewReconciliationStateComputator(
+	logger *logrus.Logger,
+	policyChecker *policy.Checker,
+	corporalUserId string,
+) *ReconciliationStateComputator {
 	return &ReconciliationStateComputator{
-		logger: logger,
+		logger:         logger,
+		policyChecker:  policyChecker,
+		corporalUserId: corporalUserId,
 	}
 }
 
@@ -26,6 +39,10 @@ func (me *ReconciliationStateComputator) Compute(
 	currentState *connector.CurrentState,
 	policy *policy.Policy,
 ) (*reconciliation.State, error) {
+	// Time-bounded user activation and room memberships are evaluated against the current time,
+	// so that the rest of the computation can deal with a regular (time-independent) policy.
+	policy = policy.EffectiveAt(time.Now())
+
 	reconciliationState := &reconciliation.State{
 		Actions: make([]*reconciliation.StateAction, 0),
 	}
@@ -46,6 +63,8 @@ func (me *ReconciliationStateComputator) Compute(
 		computedActions = append(computedActions, actions...)
 	}
 
+	computedActions = append(computedActions, me.computeExclusiveRoomChanges(currentState, policy)...)
+
 	// Group all set power actions for each room
 	groupedActions := make([]*reconciliation.StateAction, 0)
 
@@ -90,14 +109,64 @@ func (me *ReconciliationStateComputator) Compute(
 		}
 	}
 
+	// Room-wide power levels are merged into the same action (and thus the same `m.room.power_levels` update)
+	// as the user power levels for that room.
+	roomPowerLevelsActions := me.computeRoomPowerLevelsChanges(currentState, policy, setPowerActionsByRoomId)
+
 	for _, action := range setPowerActionsByRoomId {
 		groupedActions = append(groupedActions, action)
 	}
+
+	groupedActions = append(groupedActions, roomPowerLevelsActions...)
 	reconciliationState.Actions = groupedActions
 
 	return reconciliationState, nil
 }
 
+// computeRoomPowerLevelsChanges ensures that rooms get their room-wide power levels updated to match the policy.
+//
+// Rooms which already have a user power levels update action (in setPowerActionsByRoomId) get it turned into
+// a full power levels update. Actions for other rooms are returned.
+func (me *ReconciliationStateComputator) computeRoomPowerLevelsChanges(
+	currentState *connector.CurrentState,
+	policy *policy.Policy,
+	setPowerActionsByRoomId map[string]*reconciliation.StateAction,
+) []*reconciliation.StateAction {
+	var actions []*reconciliation.StateAction
+
+	for _, roomPowerLevels := range policy.RoomPowerLevels {
+		roomState := currentState.GetRoomStateByRoomId(roomPowerLevels.RoomId)
+		if roomState == nil || roomState.PowerLevels == nil {
+			me.logger.Warnf("Missing power levels state for room %s, so they cannot be checked", roomPowerLevels.RoomId)
+			continue
+		}
+
+		if roomPowerLevels.IsSatisfiedBy(*roomState.PowerLevels) {
+			continue
+		}
+
+		content := roomPowerLevels.ToContent()
+
+		setPowerAction, exists := setPowerActionsByRoomId[roomPowerLevels.RoomId]
+		if exists {
+			setPowerAction.Type = reconciliation.ActionRoomSetPowerLevels
+			setPowerAction.Payload["roomPowerLevels"] = &content
+			continue
+		}
+
+		actions = append(actions, &reconciliation.StateAction{
+			Type: reconciliation.ActionRoomSetPowerLevels,
+			Payload: map[string]interface{}{
+				"roomId":             roomPowerLevels.RoomId,
+				"roomPowerForUserId": map[string]int{},
+				"roomPowerLevels":    &content,
+			},
+		})
+	}
+
+	return actions
+}
+
 func (me *ReconciliationStateComputator) computeUserChanges(
 	userId string,
 	currentUserState *connector.CurrentUserState,
@@ -127,6 +196,26 @@ func (me *ReconciliationStateComputator) computeUserChanges(
 		me.computeUserMembershipChanges(userId, currentUserState, userPolicy, policy.ManagedRoomIds)...,
 	)
 
+	actions = append(
+		actions,
+		me.computeUserAccountDataChanges(userId, currentUserState, policy, userPolicy)...,
+	)
+
+	actions = append(
+		actions,
+		me.computeUserRoomTagChanges(userId, currentUserState, policy, userPolicy)...,
+	)
+
+	actions = append(
+		actions,
+		me.computeUserPushRuleChanges(userId, currentUserState, policy)...,
+	)
+
+	actions = append(
+		actions,
+		me.computeUserDeviceChanges(userId, currentUserState, userPolicy)...,
+	)
+
 	return actions
 }
 
@@ -152,6 +241,22 @@ func (me *ReconciliationStateComputator) computeUserActivationChanges(
 		return actions
 	}
 
+	deactivation := me.policyChecker.GetUserDeactivation(*policy, userId)
+
+	isBeingDeactivated := currentUserState.Active && !userPolicy.Active
+
+	if isBeingDeactivated && deactivation.RedactMessages {
+		// Redaction only covers rooms that the user is still a member of,
+		// so it needs to happen before leaving rooms.
+		actions = append(actions, &reconciliation.StateAction{
+			Type: reconciliation.ActionUserRedactMessages,
+			Payload: map[string]interface{}{
+				"userId": userPolicy.Id,
+				"limit":  deactivation.RedactMessagesLimit,
+			},
+		})
+	}
+
 	if !userPolicy.Active {
 		// If the user is supposed to be inactive,
 		// we want to ensure that it has left all rooms first,
@@ -160,26 +265,71 @@ func (me *ReconciliationStateComputator) computeUserActivationChanges(
 			actions,
 			me.computeUserMembershipChanges(userId, currentUserState, userPolicy, policy.ManagedRoomIds)...,
 		)
+
+		if deactivation.ShouldLeaveUnmanagedRooms() {
+			actions = append(
+				actions,
+				me.computeUserUnmanagedRoomLeaveChanges(userId, currentUserState, policy.ManagedRoomIds)...,
+			)
+		}
 	}
 
-	if currentUserState.Active {
-		if !userPolicy.Active {
+	if isBeingDeactivated {
+		if deactivation.RemoveAvatar && currentUserState.AvatarMxcUri != "" {
 			actions = append(actions, &reconciliation.StateAction{
-				Type: reconciliation.ActionUserDeactivate,
+				Type: reconciliation.ActionUserRemoveAvatar,
 				Payload: map[string]interface{}{
 					"userId": userPolicy.Id,
 				},
 			})
 		}
-	} else {
-		if userPolicy.Active {
+
+		if deactivation.LogoutDevices {
 			actions = append(actions, &reconciliation.StateAction{
-				Type: reconciliation.ActionUserActivate,
+				Type: reconciliation.ActionUserLogoutDevices,
 				Payload: map[string]interface{}{
 					"userId": userPolicy.Id,
 				},
 			})
 		}
+
+		actions = append(actions, &reconciliation.StateAction{
+			Type: reconciliation.ActionUserDeactivate,
+			Payload: map[string]interface{}{
+				"userId": userPolicy.Id,
+			},
+		})
+	}
+
+	if !userPolicy.Active && deactivation.UseHomeserverDeactivation && !currentUserState.IsDeactivatedOnHomeserver {
+		// This is not limited to users being deactivated right now.
+		// Previously-deactivated users (in our regular way) are also deactivated on the homeserver.
+		actions = append(actions, &reconciliation.StateAction{
+			Type: reconciliation.ActionUserDeactivateOnHomeserver,
+			Payload: map[string]interface{}{
+				"userId": userPolicy.Id,
+				"erase":  deactivation.Erase,
+			},
+		})
+	}
+
+	if !currentUserState.Active && userPolicy.Active {
+		if currentUserState.IsDeactivatedOnHomeserver {
+			actions = append(actions, &reconciliation.StateAction{
+				Type: reconciliation.ActionUserReactivateOnHomeserver,
+				Payload: map[string]interface{}{
+					"userId":   userPolicy.Id,
+					"password": me.generateInitialPasswordForUser(*userPolicy),
+				},
+			})
+		}
+
+		actions = append(actions, &reconciliation.StateAction{
+			Type: reconciliation.ActionUserActivate,
+			Payload: map[string]interface{}{
+				"userId": userPolicy.Id,
+			},
+		})
 	}
 
 	return actions
@@ -203,6 +353,21 @@ func (me *ReconciliationStateComputator) computeUserProfileDataChanges(
 		me.computeUserProfileAvatarChanges(userId, currentUserState, policy, userPolicy)...,
 	)
 
+	actions = append(
+		actions,
+		me.computeUserThreepidChanges(userId, currentUserState, userPolicy)...,
+	)
+
+	actions = append(
+		actions,
+		me.computeUserServerAdminChanges(userId, currentUserState, userPolicy)...,
+	)
+
+	actions = append(
+		actions,
+		me.computeUserRateLimitOverrideChanges(userId, currentUserState, userPolicy)...,
+	)
+
 	return actions
 }
 
@@ -222,7 +387,7 @@ func (me *ReconciliationStateComputator) computeUserProfileDisplayNameChanges(
 			shouldSetDisplayName = true
 		}
 	} else {
-		if policy.Flags.AllowCustomUserDisplayNames {
+		if me.policyChecker.CanUserUseCustomDisplayName(*policy, userId) {
 			if currentUserState.DisplayName == "" && userPolicy.DisplayName != "" {
 				// Even if we allow custom names, we still want to avoid
 				// people having empty names.
@@ -267,7 +432,7 @@ func (me *ReconciliationStateComputator) computeUserProfileAvatarChanges(
 			shouldSetAvatar = true
 		}
 	} else {
-		if policy.Flags.AllowCustomUserAvatars {
+		if me.policyChecker.CanUserUseCustomAvatar(*policy, userId) {
 			if currentUserState.AvatarSourceUriHash == avatar.UriHash("") && userPolicy.AvatarUri != "" {
 				// Even if we allow custom avatars, we still want to avoid
 				// people having empty avatars.
@@ -296,6 +461,136 @@ func (me *ReconciliationStateComputator) computeUserProfileAvatarChanges(
 	return actions
 }
 
+func (me *ReconciliationStateComputator) computeUserThreepidChanges(
+	userId string,
+	currentUserState *connector.CurrentUserState,
+	userPolicy *policy.UserPolicy,
+) []*reconciliation.StateAction {
+	var actions []*reconciliation.StateAction
+
+	if userPolicy.Threepids == nil {
+		// 3pids are not managed for this user.
+		return actions
+	}
+
+	var currentThreepids []connector.CurrentUserThreepidState
+	if currentUserState != nil {
+		currentThreepids = currentUserState.Threepids
+	}
+
+	policyThreepids := make([]connector.CurrentUserThreepidState, 0, len(*userPolicy.Threepids))
+	for _, threepid := range *userPolicy.Threepids {
+		policyThreepids = append(policyThreepids, connector.CurrentUserThreepidState{
+			Medium:  threepid.Medium,
+			Address: threepid.Address,
+		})
+	}
+
+	if areThreepidListsEquivalent(currentThreepids, policyThreepids) {
+		return actions
+	}
+
+	actions = append(actions, &reconciliation.StateAction{
+		Type: reconciliation.ActionUserSetThreepids,
+		Payload: map[string]interface{}{
+			"userId":    userId,
+			"threepids": policyThreepids,
+		},
+	})
+
+	return actions
+}
+
+func (me *ReconciliationStateComputator) computeUserServerAdminChanges(
+	userId string,
+	currentUserState *connector.CurrentUserState,
+	userPolicy *policy.UserPolicy,
+) []*reconciliation.StateAction {
+	var actions []*reconciliation.StateAction
+
+	if userPolicy.IsServerAdmin == nil {
+		// Administrator status is not managed for this user.
+		return actions
+	}
+
+	isServerAdminCurrently := false
+	if currentUserState != nil {
+		isServerAdminCurrently = currentUserState.IsServerAdmin
+	}
+
+	if *userPolicy.IsServerAdmin == isServerAdminCurrently {
+		return actions
+	}
+
+	actionType := reconciliation.ActionUserDemoteFromServerAdmin
+	if *userPolicy.IsServerAdmin {
+		actionType = reconciliation.ActionUserPromoteToServerAdmin
+	} else if userId == me.corporalUserId {
+		// matrix-corporal relies on its user being a server admin for pretty much everything it does.
+		// Demoting it would break reconciliation, without a way to recover from it automatically.
+		me.logger.Warnf("Refusing to demote %s (the matrix-corporal user) from server admin, despite the policy asking for it", userId)
+		return actions
+	}
+
+	actions = append(actions, &reconciliation.StateAction{
+		Type: actionType,
+		Payload: map[string]interface{}{
+			"userId": userId,
+		},
+	})
+
+	return actions
+}
+
+func (me *ReconciliationStateComputator) computeUserRateLimitOverrideChanges(
+	userId string,
+	currentUserState *connector.CurrentUserState,
+	userPolicy *policy.UserPolicy,
+) []*reconciliation.StateAction {
+	var actions []*reconciliation.StateAction
+
+	if !userPolicy.IsRateLimitOverrideManaged() {
+		// Rate limit overrides are not managed for this user.
+		// Overrides set by other means (e.g. by an administrator, by hand) are left alone.
+		return actions
+	}
+
+	var currentOverride *connector.CurrentUserRateLimitOverrideState
+	if currentUserState != nil {
+		currentOverride = currentUserState.RateLimitOverride
+	}
+
+	if userPolicy.RemoveRateLimitOverride {
+		if currentOverride != nil {
+			actions = append(actions, &reconciliation.StateAction{
+				Type: reconciliation.ActionUserRemoveRateLimitOverride,
+				Payload: map[string]interface{}{
+					"userId": userId,
+				},
+			})
+		}
+
+		return actions
+	}
+
+	if currentOverride != nil &&
+		currentOverride.MessagesPerSecond == userPolicy.RateLimitOverride.MessagesPerSecond &&
+		currentOverride.BurstCount == userPolicy.RateLimitOverride.BurstCount {
+		return actions
+	}
+
+	actions = append(actions, &reconciliation.StateAction{
+		Type: reconciliation.ActionUserSetRateLimitOverride,
+		Payload: map[string]interface{}{
+			"userId":            userId,
+			"messagesPerSecond": userPolicy.RateLimitOverride.MessagesPerSecond,
+			"burstCount":        userPolicy.RateLimitOverride.BurstCount,
+		},
+	})
+
+	return actions
+}
+
 func (me *ReconciliationStateComputator) computeUserMembershipChanges(
 	userId string,
 	currentUserState *connector.CurrentUserState,
@@ -312,6 +607,254 @@ func (me *ReconciliationStateComputator) computeUserMembershipChanges(
 	return actions
 }
 
+// computeUserAccountDataChanges ensures that the user has the account data defined in the policy.
+//
+// If custom account data is allowed, existing (non-empty) account data is left alone and the policy is only used for seeding.
+func (me *ReconciliationStateComputator) computeUserAccountDataChanges(
+	userId string,
+	currentUserState *connector.CurrentUserState,
+	policy *policy.Policy,
+	userPolicy *policy.UserPolicy,
+) []*reconciliation.StateAction {
+	var actions []*reconciliation.StateAction
+
+	// Going in a predictable order, instead of the random map order
+	accountDataTypes := make([]string, 0, len(userPolicy.AccountData))
+	for accountDataType := range userPolicy.AccountData {
+		accountDataTypes = append(accountDataTypes, accountDataType)
+	}
+	sort.Strings(accountDataTypes)
+
+	for _, accountDataType := range accountDataTypes {
+		content := userPolicy.AccountData[accountDataType]
+		if content == nil {
+			content = map[string]interface{}{}
+		}
+
+		currentContent := map[string]interface{}{}
+		if currentUserState != nil && currentUserState.AccountData[accountDataType] != nil {
+			currentContent = currentUserState.AccountData[accountDataType]
+		}
+
+		if reflect.DeepEqual(currentContent, content) {
+			continue
+		}
+
+		if len(currentContent) != 0 && policy.Flags.AllowCustomUserAccountData {
+			continue
+		}
+
+		actions = append(actions, &reconciliation.StateAction{
+			Type: reconciliation.ActionUserSetAccountData,
+			Payload: map[string]interface{}{
+				"userId":  userId,
+				"type":    accountDataType,
+				"content": content,
+			},
+		})
+	}
+
+	return actions
+}
+
+// computeUserRoomTagChanges ensures that the user has the room tags defined in the policy.
+//
+// If custom account data is allowed, missing tags are added, but existing ones are left alone.
+// Otherwise, tags are made to match the policy exactly (for rooms that the policy defines tags for).
+func (me *ReconciliationStateComputator) computeUserRoomTagChanges(
+	userId string,
+	currentUserState *connector.CurrentUserState,
+	policy *policy.Policy,
+	userPolicy *policy.UserPolicy,
+) []*reconciliation.StateAction {
+	var actions []*reconciliation.StateAction
+
+	for _, room := range userPolicy.JoinedRooms {
+		if room.Tags == nil {
+			continue
+		}
+
+		currentTags := map[string]matrix.RoomTag{}
+		if currentUserState != nil && currentUserState.RoomTags[room.RoomId] != nil {
+			currentTags = currentUserState.RoomTags[room.RoomId]
+		}
+
+		tags := make([]string, 0, len(room.Tags))
+		for tag := range room.Tags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+
+		for _, tag := range tags {
+			content := room.Tags[tag]
+
+			currentContent, exists := currentTags[tag]
+			if exists && (policy.Flags.AllowCustomUserAccountData || areRoomTagsEquivalent(currentContent, content)) {
+				continue
+			}
+
+			actions = append(actions, &reconciliation.StateAction{
+				Type: reconciliation.ActionUserSetRoomTag,
+				Payload: map[string]interface{}{
+					"userId":  userId,
+					"roomId":  room.RoomId,
+					"tag":     tag,
+					"content": content,
+				},
+			})
+		}
+
+		if policy.Flags.AllowCustomUserAccountData {
+			continue
+		}
+
+		currentTagNames := make([]string, 0, len(currentTags))
+		for tag := range currentTags {
+			currentTagNames = append(currentTagNames, tag)
+		}
+		sort.Strings(currentTagNames)
+
+		for _, tag := range currentTagNames {
+			if _, exists := room.Tags[tag]; exists {
+				continue
+			}
+
+			actions = append(actions, &reconciliation.StateAction{
+				Type: reconciliation.ActionUserRemoveRoomTag,
+				Payload: map[string]interface{}{
+					"userId": userId,
+					"roomId": room.RoomId,
+					"tag":    tag,
+				},
+			})
+		}
+	}
+
+	return actions
+}
+
+// computeUserPushRuleChanges ensures that the user has the push rules defined in the policy.
+//
+// Enforced rules are kept exactly as defined. Other rules are only created if missing.
+// Server-default rules always exist, so they're only ever updated if enforced.
+func (me *ReconciliationStateComputator) computeUserPushRuleChanges(
+	userId string,
+	currentUserState *connector.CurrentUserState,
+	policy *policy.Policy,
+) []*reconciliation.StateAction {
+	var actions []*reconciliation.StateAction
+
+	for _, pushRule := range me.policyChecker.GetUserPushRules(*policy, userId) {
+		var currentPushRule *connector.CurrentUserPushRuleState
+		if currentUserState != nil {
+			for idx, rule := range currentUserState.PushRules {
+				if rule.Kind == pushRule.Kind && rule.RuleId == pushRule.RuleId {
+					currentPushRule = &currentUserState.PushRules[idx]
+					break
+				}
+			}
+		}
+
+		desiredPushRule := connector.CurrentUserPushRuleState{
+			Kind:       pushRule.Kind,
+			RuleId:     pushRule.RuleId,
+			Enabled:    pushRule.IsEnabled(),
+			Actions:    pushRule.Actions,
+			Conditions: pushRule.Conditions,
+			Pattern:    pushRule.Pattern,
+		}
+
+		if currentPushRule != nil {
+			if !pushRule.Enforced || arePushRulesEquivalent(*currentPushRule, desiredPushRule) {
+				continue
+			}
+		}
+
+		actions = append(actions, &reconciliation.StateAction{
+			Type: reconciliation.ActionUserSetPushRule,
+			Payload: map[string]interface{}{
+				"userId":   userId,
+				"pushRule": desiredPushRule,
+			},
+		})
+	}
+
+	return actions
+}
+
+// computeUserDeviceChanges logs out devices which the policy does not allow.
+//
+// Devices with a disallowed display name are always logged out.
+// If the user still has more than the allowed number of devices, the least recently seen ones are logged out.
+func (me *ReconciliationStateComputator) computeUserDeviceChanges(
+	userId string,
+	currentUserState *connector.CurrentUserState,
+	userPolicy *policy.UserPolicy,
+) []*reconciliation.StateAction {
+	if currentUserState == nil || currentUserState.Devices == nil {
+		// Device information is not available (new user, unrestricted user, or a connector which doesn't support it)
+		return nil
+	}
+
+	var deviceIdsToDelete []string
+	var remainingDevices []connector.CurrentUserDeviceState
+
+	for _, device := range currentUserState.Devices {
+		if userPolicy.IsDeviceDisplayNameAllowed(device.DisplayName) {
+			remainingDevices = append(remainingDevices, device)
+		} else {
+			deviceIdsToDelete = append(deviceIdsToDelete, device.DeviceId)
+		}
+	}
+
+	if userPolicy.MaxDevices != 0 && len(remainingDevices) > userPolicy.MaxDevices {
+		excessDevices := connector.DetermineLeastRecentlySeenDevices(remainingDevices, len(remainingDevices)-userPolicy.MaxDevices)
+		for _, device := range excessDevices {
+			deviceIdsToDelete = append(deviceIdsToDelete, device.DeviceId)
+		}
+	}
+
+	if len(deviceIdsToDelete) == 0 {
+		return nil
+	}
+
+	return []*reconciliation.StateAction{
+		{
+			Type: reconciliation.ActionUserDeleteDevices,
+			Payload: map[string]interface{}{
+				"userId":    userId,
+				"deviceIds": deviceIdsToDelete,
+			},
+		},
+	}
+}
+
+// computeUserUnmanagedRoomLeaveChanges makes the user leave all the unmanaged rooms they're part of.
+// Managed rooms are handled by computeUserRoomChanges.
+func (me *ReconciliationStateComputator) computeUserUnmanagedRoomLeaveChanges(
+	userId string,
+	currentUserState *connector.CurrentUserState,
+	managedRoomIds []string,
+) []*reconciliation.StateAction {
+	var actions []*reconciliation.StateAction
+
+	for _, room := range currentUserState.JoinedRooms {
+		if util.IsStringInArray(room.RoomId, managedRoomIds) {
+			continue
+		}
+
+		actions = append(actions, &reconciliation.StateAction{
+			Type: reconciliation.ActionRoomLeave,
+			Payload: map[string]interface{}{
+				"userId": userId,
+				"roomId": room.RoomId,
+			},
+		})
+	}
+
+	return actions
+}
+
 func (me *ReconciliationStateComputator) computeUserRoomChanges(
 	userId string,
 	currentUserState *connector.CurrentUserState,
@@ -406,6 +949,70 @@ func (me *ReconciliationStateComputator) computeUserRoomChanges(
 	return actions
 }
 
+// computeExclusiveRoomChanges computes actions which remove everyone who is not supposed to be in an exclusive room.
+//
+// Managed users who are joined to a room they're not supposed to be in are not handled here,
+// as they leave such rooms by themselves (see computeUserRoomChanges).
+func (me *ReconciliationStateComputator) computeExclusiveRoomChanges(
+	currentState *connector.CurrentState,
+	policyObj *policy.Policy,
+) []*reconciliation.StateAction {
+	var actions []*reconciliation.StateAction
+
+	for _, exclusiveRoom := range policyObj.ExclusiveRooms {
+		roomState := currentState.GetRoomStateByRoomId(exclusiveRoom.RoomId)
+		if roomState == nil {
+			me.logger.Warnf("Missing state for exclusive room %s, so its members cannot be checked", exclusiveRoom.RoomId)
+			continue
+		}
+
+		for _, member := range roomState.Members {
+			if member.Membership == matrix.MembershipBan {
+				// Already as removed as it gets.
+				continue
+			}
+
+			userPolicy := policyObj.GetUserPolicyByUserId(member.UserId)
+
+			if userPolicy != nil {
+				if userPolicy.Active && isRoomInUserPolicyJoinedRooms(userPolicy, exclusiveRoom.RoomId) {
+					continue
+				}
+
+				if member.Membership == matrix.MembershipJoin {
+					// This managed user will be made to leave.
+					continue
+				}
+			}
+
+			actionType := reconciliation.ActionRoomKick
+			if exclusiveRoom.RemovalMethod == policy.ExclusiveRoomRemovalMethodBan && userPolicy == nil {
+				// We never ban managed users, as that would prevent us from joining them to the room later on.
+				actionType = reconciliation.ActionRoomBan
+			}
+
+			actions = append(actions, &reconciliation.StateAction{
+				Type: actionType,
+				Payload: map[string]interface{}{
+					"userId": member.UserId,
+					"roomId": exclusiveRoom.RoomId,
+				},
+			})
+		}
+	}
+
+	return actions
+}
+
+func isRoomInUserPolicyJoinedRooms(userPolicy *policy.UserPolicy, roomId string) bool {
+	for _, room := range userPolicy.JoinedRooms {
+		if room.RoomId == roomId {
+			return true
+		}
+	}
+	return false
+}
+
 func (me *ReconciliationStateComputator) generateInitialPasswordForUser(userPolicy policy.UserPolicy) string {
 	// UserAuthTypePassthrough is a special AuthType. Users are created with an initial password as specified in the policy.
 	// For such users, authentication is delegated to the homeserver.
@@ -433,3 +1040,76 @@ func (me *ReconciliationStateComputator) generateInitialPasswordForUser(userPoli
 	}
 	return fmt.Sprintf("%x", passwordBytes)
 }
+
+// areThreepidListsEquivalent tells whether both lists contain the same 3pids (regardless of order)
+func areThreepidListsEquivalent(a, b []connector.CurrentUserThreepidState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	keysA := make(map[string]bool)
+	for _, threepid := range a {
+		keysA[createThreepidComparisonKey(threepid)] = true
+	}
+
+	for _, threepid := range b {
+		if !keysA[createThreepidComparisonKey(threepid)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func createThreepidComparisonKey(threepid connector.CurrentUserThreepidState) string {
+	address := threepid.Address
+	if threepid.Medium == policy.ThreepidMediumEmail {
+		// The homeserver stores email addresses in lowercase.
+		address = strings.ToLower(address)
+	}
+	return fmt.Sprintf("%s:%s", threepid.Medium, address)
+}
+
+func areRoomTagsEquivalent(a, b matrix.RoomTag) bool {
+	if a.Order == nil || b.Order == nil {
+		return a.Order == nil && b.Order == nil
+	}
+	return *a.Order == *b.Order
+}
+
+func arePushRulesEquivalent(a, b connector.CurrentUserPushRuleState) bool {
+	if a.Enabled != b.Enabled {
+		return false
+	}
+
+	if !arePushRuleActionsEquivalent(a.Actions, b.Actions) {
+		return false
+	}
+
+	if a.IsServerDefault() {
+		// Only the actions and the enabled status of server-default rules can be controlled.
+		return true
+	}
+
+	if a.Pattern != b.Pattern {
+		return false
+	}
+
+	if len(a.Conditions) != len(b.Conditions) {
+		return false
+	}
+	for idx := range a.Conditions {
+		if !reflect.DeepEqual(a.Conditions[idx], b.Conditions[idx]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func arePushRuleActionsEquivalent(a, b []interface{}) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == 0 && len(b) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -30,7 +30,7 @@ func TestReconciliationStateComputation(t *testing.T) {
 	logger := logrus.New()
 	logger.Out = io.Discard
 
-	reconciliationComputator := NewReconciliationStateComputator(logger)
+	reconciliationComputator := NewReconciliationStateComputator(logger, policy.NewChecker(), "@matrix-corporal:host")
 
 	for _, testPath := range matches {
 		testPath := testPath //make local
@@ -0,0 +1,112 @@
+package reconciler
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/reconciliation"
+
+	"github.com/sirupsen/logrus"
+)
+
+// emitHookEvents notifies the REST services of all reconciliation hooks interested in the given (successfully completed) action.
+//
+// Failures are logged, but never influence reconciliation.
+func (me *Reconciler) emitHookEvents(action *reconciliation.StateAction, logger *logrus.Entry) {
+	event, err := createReconciliationEventForAction(action)
+	if err != nil {
+		logger.Warnf("Failed creating reconciliation hook event: %s", err)
+		return
+	}
+
+	if event == nil {
+		// Not an action that we emit events for
+		return
+	}
+
+	for _, hookObj := range me.hookStore.GetMerged() {
+		if hookObj.EventType != event.EventType {
+			continue
+		}
+
+		hookLogger := logger.WithField("hookId", hookObj.ID)
+		hookLogger = hookLogger.WithField("hookEventType", hookObj.EventType)
+
+		hookLogger.Infof("Executing reconciliation hook")
+
+		err := me.restServiceConsultor.NotifyAboutReconciliationEvent(*event, *hookObj, hookLogger)
+		if err != nil {
+			hookLogger.Warnf("Failed executing reconciliation hook: %s", err)
+		}
+	}
+}
+
+// createReconciliationEventForAction creates a hook.ReconciliationEvent for the given action.
+//
+// Not all actions trigger events. For those that don't, nil is returned.
+func createReconciliationEventForAction(action *reconciliation.StateAction) (*hook.ReconciliationEvent, error) {
+	event := &hook.ReconciliationEvent{
+		ReconciliationAction: action.Type,
+	}
+
+	switch action.Type {
+	case reconciliation.ActionUserCreate:
+		event.EventType = hook.EventTypeReconciliationUserCreated
+	case reconciliation.ActionUserDeactivate:
+		event.EventType = hook.EventTypeReconciliationUserDeactivated
+	case reconciliation.ActionRoomJoin:
+		event.EventType = hook.EventTypeReconciliationRoomJoined
+	case reconciliation.ActionRoomLeave:
+		event.EventType = hook.EventTypeReconciliationRoomLeft
+	case reconciliation.ActionRoomKick, reconciliation.ActionRoomBan:
+		event.EventType = hook.EventTypeReconciliationRoomUserRemoved
+	case reconciliation.ActionRoomUserSetPowerLevel, reconciliation.ActionRoomUsersSetPowerLevels, reconciliation.ActionRoomSetPowerLevels:
+		event.EventType = hook.EventTypeReconciliationRoomPowerLevelsChanged
+	default:
+		return nil, nil
+	}
+
+	// We deliberately pick the payload fields to pass along, instead of passing the whole action payload.
+	// Some of it (like the password for ActionUserCreate) should not leave matrix-corporal.
+
+	if action.Type == reconciliation.ActionRoomUsersSetPowerLevels || action.Type == reconciliation.ActionRoomSetPowerLevels {
+		roomPowerForUserId, err := action.GetPayloadDataByKey("roomPowerForUserId")
+		if err != nil {
+			return nil, err
+		}
+		if len(roomPowerForUserId.(map[string]int)) > 0 {
+			event.PowerLevels = roomPowerForUserId.(map[string]int)
+		}
+	} else {
+		userId, err := action.GetStringPayloadDataByKey("userId")
+		if err != nil {
+			return nil, err
+		}
+		event.UserId = &userId
+	}
+
+	if action.Type == reconciliation.ActionRoomSetPowerLevels {
+		roomPowerLevels, err := action.GetPayloadDataByKey("roomPowerLevels")
+		if err != nil {
+			return nil, err
+		}
+		event.RoomPowerLevels = roomPowerLevels.(*matrix.RoomPowerLevelsContent)
+	}
+
+	if action.Type == reconciliation.ActionRoomUserSetPowerLevel {
+		powerLevel, err := action.GetIntPayloadDataByKey("powerLevel")
+		if err != nil {
+			return nil, err
+		}
+		event.PowerLevels = map[string]int{*event.UserId: powerLevel}
+	}
+
+	if event.EventType != hook.EventTypeReconciliationUserCreated && event.EventType != hook.EventTypeReconciliationUserDeactivated {
+		roomId, err := action.GetStringPayloadDataByKey("roomId")
+		if err != nil {
+			return nil, err
+		}
+		event.RoomId = &roomId
+	}
+
+	return event, nil
+}
@@ -0,0 +1,190 @@
+package reconciler
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/matrix"
+	"devture-matrix-corporal/corporal/reconciliation"
+	"reflect"
+	"testing"
+)
+
+func TestCreateReconciliationEventForAction(t *testing.T) {
+	userId := "@a:host"
+	roomId := "!room:host"
+	kickPowerLevel := 50
+
+	roomPowerLevels := &matrix.RoomPowerLevelsContent{Kick: &kickPowerLevel}
+
+	type testCase struct {
+		action        *reconciliation.StateAction
+		expectedEvent *hook.ReconciliationEvent
+	}
+
+	testCases := []testCase{
+		{
+			action: &reconciliation.StateAction{
+				Type:    reconciliation.ActionUserCreate,
+				Payload: map[string]interface{}{"userId": userId, "password": "secret"},
+			},
+			expectedEvent: &hook.ReconciliationEvent{
+				EventType:            hook.EventTypeReconciliationUserCreated,
+				ReconciliationAction: reconciliation.ActionUserCreate,
+				UserId:               &userId,
+			},
+		},
+		{
+			action: &reconciliation.StateAction{
+				Type:    reconciliation.ActionUserDeactivate,
+				Payload: map[string]interface{}{"userId": userId},
+			},
+			expectedEvent: &hook.ReconciliationEvent{
+				EventType:            hook.EventTypeReconciliationUserDeactivated,
+				ReconciliationAction: reconciliation.ActionUserDeactivate,
+				UserId:               &userId,
+			},
+		},
+		{
+			action: &reconciliation.StateAction{
+				Type:    reconciliation.ActionRoomJoin,
+				Payload: map[string]interface{}{"userId": userId, "roomId": roomId},
+			},
+			expectedEvent: &hook.ReconciliationEvent{
+				EventType:            hook.EventTypeReconciliationRoomJoined,
+				ReconciliationAction: reconciliation.ActionRoomJoin,
+				UserId:               &userId,
+				RoomId:               &roomId,
+			},
+		},
+		{
+			action: &reconciliation.StateAction{
+				Type:    reconciliation.ActionRoomLeave,
+				Payload: map[string]interface{}{"userId": userId, "roomId": roomId},
+			},
+			expectedEvent: &hook.ReconciliationEvent{
+				EventType:            hook.EventTypeReconciliationRoomLeft,
+				ReconciliationAction: reconciliation.ActionRoomLeave,
+				UserId:               &userId,
+				RoomId:               &roomId,
+			},
+		},
+		{
+			action: &reconciliation.StateAction{
+				Type:    reconciliation.ActionRoomKick,
+				Payload: map[string]interface{}{"userId": userId, "roomId": roomId},
+			},
+			expectedEvent: &hook.ReconciliationEvent{
+				EventType:            hook.EventTypeReconciliationRoomUserRemoved,
+				ReconciliationAction: reconciliation.ActionRoomKick,
+				UserId:               &userId,
+				RoomId:               &roomId,
+			},
+		},
+		{
+			action: &reconciliation.StateAction{
+				Type:    reconciliation.ActionRoomBan,
+				Payload: map[string]interface{}{"userId": userId, "roomId": roomId},
+			},
+			expectedEvent: &hook.ReconciliationEvent{
+				EventType:            hook.EventTypeReconciliationRoomUserRemoved,
+				ReconciliationAction: reconciliation.ActionRoomBan,
+				UserId:               &userId,
+				RoomId:               &roomId,
+			},
+		},
+		{
+			action: &reconciliation.StateAction{
+				Type:    reconciliation.ActionRoomUserSetPowerLevel,
+				Payload: map[string]interface{}{"userId": userId, "roomId": roomId, "powerLevel": 100},
+			},
+			expectedEvent: &hook.ReconciliationEvent{
+				EventType:            hook.EventTypeReconciliationRoomPowerLevelsChanged,
+				ReconciliationAction: reconciliation.ActionRoomUserSetPowerLevel,
+				UserId:               &userId,
+				RoomId:               &roomId,
+				PowerLevels:          map[string]int{userId: 100},
+			},
+		},
+		{
+			action: &reconciliation.StateAction{
+				Type: reconciliation.ActionRoomUsersSetPowerLevels,
+				Payload: map[string]interface{}{
+					"roomId":             roomId,
+					"roomPowerForUserId": map[string]int{userId: 50, "@b:host": 0},
+				},
+			},
+			expectedEvent: &hook.ReconciliationEvent{
+				EventType:            hook.EventTypeReconciliationRoomPowerLevelsChanged,
+				ReconciliationAction: reconciliation.ActionRoomUsersSetPowerLevels,
+				RoomId:               &roomId,
+				PowerLevels:          map[string]int{userId: 50, "@b:host": 0},
+			},
+		},
+		{
+			// Room-wide power levels only
+			action: &reconciliation.StateAction{
+				Type: reconciliation.ActionRoomSetPowerLevels,
+				Payload: map[string]interface{}{
+					"roomId":             roomId,
+					"roomPowerForUserId": map[string]int{},
+					"roomPowerLevels":    roomPowerLevels,
+				},
+			},
+			expectedEvent: &hook.ReconciliationEvent{
+				EventType:            hook.EventTypeReconciliationRoomPowerLevelsChanged,
+				ReconciliationAction: reconciliation.ActionRoomSetPowerLevels,
+				RoomId:               &roomId,
+				RoomPowerLevels:      roomPowerLevels,
+			},
+		},
+		{
+			// Room-wide power levels, combined with users' power levels
+			action: &reconciliation.StateAction{
+				Type: reconciliation.ActionRoomSetPowerLevels,
+				Payload: map[string]interface{}{
+					"roomId":             roomId,
+					"roomPowerForUserId": map[string]int{userId: 50},
+					"roomPowerLevels":    roomPowerLevels,
+				},
+			},
+			expectedEvent: &hook.ReconciliationEvent{
+				EventType:            hook.EventTypeReconciliationRoomPowerLevelsChanged,
+				ReconciliationAction: reconciliation.ActionRoomSetPowerLevels,
+				RoomId:               &roomId,
+				PowerLevels:          map[string]int{userId: 50},
+				RoomPowerLevels:      roomPowerLevels,
+			},
+		},
+		{
+			// Not an action that we emit events for
+			action: &reconciliation.StateAction{
+				Type:    reconciliation.ActionUserSetDisplayName,
+				Payload: map[string]interface{}{"userId": userId, "displayName": "A"},
+			},
+			expectedEvent: nil,
+		},
+	}
+
+	for idx, tc := range testCases {
+		event, err := createReconciliationEventForAction(tc.action)
+		if err != nil {
+			t.Errorf("test case #%d (%s): unexpected error: %s", idx, tc.action.Type, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(event, tc.expectedEvent) {
+			t.Errorf("test case #%d (%s): expected event %#v, got %#v", idx, tc.action.Type, tc.expectedEvent, event)
+		}
+	}
+}
+
+func TestCreateReconciliationEventForActionWithMissingPayload(t *testing.T) {
+	action := &reconciliation.StateAction{
+		Type:    reconciliation.ActionRoomSetPowerLevels,
+		Payload: map[string]interface{}{"roomId": "!room:host", "roomPowerForUserId": map[string]int{}},
+	}
+
+	_, err := createReconciliationEventForAction(action)
+	if err == nil {
+		t.Errorf("expected an error for an action lacking a roomPowerLevels payload")
+	}
+}
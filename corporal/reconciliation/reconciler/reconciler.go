@@ -3,6 +3,7 @@ package reconciler
 import (
 	"devture-matrix-corporal/corporal/avatar"
 	"devture-matrix-corporal/corporal/connector"
+	"devture-matrix-corporal/corporal/hook"
 	"devture-matrix-corporal/corporal/matrix"
 	"devture-matrix-corporal/corporal/policy"
 	"devture-matrix-corporal/corporal/reconciliation"
@@ -19,11 +20,13 @@ const (
 type ReconciliationHandlerFunc func(*connector.AccessTokenContext, *reconciliation.StateAction) error
 
 type Reconciler struct {
-	logger              *logrus.Logger
-	connector           connector.MatrixConnector
-	computator          *computator.ReconciliationStateComputator
-	reconciliatorUserId string
-	avatarReader        *avatar.AvatarReader
+	logger               *logrus.Logger
+	connector            connector.MatrixConnector
+	computator           *computator.ReconciliationStateComputator
+	reconciliatorUserId  string
+	avatarReader         *avatar.AvatarReader
+	restServiceConsultor *hook.RESTServiceConsultor
+	hookStore            *hook.Store
 
 	handlers map[string]ReconciliationHandlerFunc
 }
@@ -34,13 +37,17 @@ func New(
 	computator *computator.ReconciliationStateComputator,
 	reconciliatorUserId string,
 	avatarReader *avatar.AvatarReader,
+	restServiceConsultor *hook.RESTServiceConsultor,
+	hookStore *hook.Store,
 ) *Reconciler {
 	me := &Reconciler{
-		logger:              logger,
-		connector:           connector,
-		computator:          computator,
-		reconciliatorUserId: reconciliatorUserId,
-		avatarReader:        avatarReader,
+		logger:               logger,
+		connector:            connector,
+		computator:           computator,
+		reconciliatorUserId:  reconciliatorUserId,
+		avatarReader:         avatarReader,
+		restServiceConsultor: restServiceConsultor,
+		hookStore:            hookStore,
 	}
 
 	me.handlers = map[string]ReconciliationHandlerFunc{
@@ -49,11 +56,33 @@ func New(
 		reconciliation.ActionUserSetAvatar:      me.reconcileForActionUserSetAvatar,
 		reconciliation.ActionUserActivate:       me.reconcileForActionUserActivate,
 		reconciliation.ActionUserDeactivate:     me.reconcileForActionUserDeactivate,
+		reconciliation.ActionUserSetThreepids:   me.reconcileForActionUserSetThreepids,
+
+		reconciliation.ActionUserSetAccountData: me.reconcileForActionUserSetAccountData,
+		reconciliation.ActionUserSetRoomTag:     me.reconcileForActionUserSetRoomTag,
+		reconciliation.ActionUserRemoveRoomTag:  me.reconcileForActionUserRemoveRoomTag,
+		reconciliation.ActionUserSetPushRule:    me.reconcileForActionUserSetPushRule,
+
+		reconciliation.ActionUserLogoutDevices:          me.reconcileForActionUserLogoutDevices,
+		reconciliation.ActionUserDeleteDevices:          me.reconcileForActionUserDeleteDevices,
+		reconciliation.ActionUserRemoveAvatar:           me.reconcileForActionUserRemoveAvatar,
+		reconciliation.ActionUserRedactMessages:         me.reconcileForActionUserRedactMessages,
+		reconciliation.ActionUserDeactivateOnHomeserver: me.reconcileForActionUserDeactivateOnHomeserver,
+		reconciliation.ActionUserReactivateOnHomeserver: me.reconcileForActionUserReactivateOnHomeserver,
+
+		reconciliation.ActionUserPromoteToServerAdmin:  me.reconcileForActionUserPromoteToServerAdmin,
+		reconciliation.ActionUserDemoteFromServerAdmin: me.reconcileForActionUserDemoteFromServerAdmin,
+
+		reconciliation.ActionUserSetRateLimitOverride:    me.reconcileForActionUserSetRateLimitOverride,
+		reconciliation.ActionUserRemoveRateLimitOverride: me.reconcileForActionUserRemoveRateLimitOverride,
 
 		reconciliation.ActionRoomJoin:                me.reconcileForActionRoomJoin,
 		reconciliation.ActionRoomLeave:               me.reconcileForActionRoomLeave,
 		reconciliation.ActionRoomUsersSetPowerLevels: me.reconcileForActionRoomUsersSetPowerLevels,
 		reconciliation.ActionRoomUserSetPowerLevel:   me.reconcileForActionRoomUserSetPowerLevel,
+		reconciliation.ActionRoomSetPowerLevels:      me.reconcileForActionRoomSetPowerLevels,
+		reconciliation.ActionRoomKick:                me.reconcileForActionRoomKick,
+		reconciliation.ActionRoomBan:                 me.reconcileForActionRoomBan,
 	}
 
 	return me
@@ -70,11 +99,38 @@ func (me *Reconciler) Reconcile(policy *policy.Policy) error {
 	ctx := connector.NewAccessTokenContext(me.connector, deviceIdReconciler, tokenValiditySeconds)
 	defer ctx.Release()
 
+	// Room aliases (which may be used in the policy instead of room ids) are looked up fresh,
+	// so that we act upon whatever rooms they point to right now.
+	policy, err := policy.WithResolvedRoomAliases(me.connector.ResolveRoomAlias)
+	if err != nil {
+		return err
+	}
+
 	currentState, err := me.connector.DetermineCurrentState(ctx, policy.GetManagedUserIds(), me.reconciliatorUserId)
 	if err != nil {
 		return fmt.Errorf("failed determining current state: %s", err)
 	}
 
+	err = me.determineUserAdministrativeStates(ctx, policy, currentState)
+	if err != nil {
+		return fmt.Errorf("failed determining current user administrative state: %s", err)
+	}
+
+	currentState.Rooms, err = me.determineRoomStates(ctx, policy)
+	if err != nil {
+		return fmt.Errorf("failed determining current room state: %s", err)
+	}
+
+	err = me.determineUserAccountDataStates(ctx, policy, currentState)
+	if err != nil {
+		return fmt.Errorf("failed determining current user account data state: %s", err)
+	}
+
+	err = me.determineUserDeviceStates(policy, currentState)
+	if err != nil {
+		return fmt.Errorf("failed determining current user device state: %s", err)
+	}
+
 	reconciliationState, err := me.computator.Compute(currentState, policy)
 	if err != nil {
 		return err
@@ -99,6 +155,171 @@ func (me *Reconciler) Reconcile(policy *policy.Policy) error {
 		}
 
 		logger.Infof("Completed reconciliation handler")
+
+		me.emitHookEvents(action, logger)
+	}
+
+	return nil
+}
+
+// determineRoomStates determines the state of rooms that the policy wishes to control beyond user membership
+// (exclusive rooms, rooms with declared power levels).
+func (me *Reconciler) determineRoomStates(ctx *connector.AccessTokenContext, policy *policy.Policy) ([]connector.CurrentRoomState, error) {
+	var roomStates []connector.CurrentRoomState
+
+	for _, roomId := range policy.ManagedRoomIds {
+		exclusiveRoom := policy.GetExclusiveRoomByRoomId(roomId)
+		roomPowerLevels := policy.GetRoomPowerLevelsByRoomId(roomId)
+
+		if exclusiveRoom == nil && roomPowerLevels == nil {
+			continue
+		}
+
+		roomState := connector.CurrentRoomState{
+			RoomId: roomId,
+		}
+
+		if exclusiveRoom != nil {
+			members, err := me.connector.GetRoomMembers(ctx, me.reconciliatorUserId, roomId)
+			if err != nil {
+				return nil, fmt.Errorf("failed determining members of %s: %s", roomId, err)
+			}
+
+			roomState.Members = make([]connector.CurrentRoomMemberState, 0, len(members))
+			for _, member := range members {
+				if member.UserId == me.reconciliatorUserId {
+					// We're not subject to the policy. We need to remain in the room to be able to manage it.
+					continue
+				}
+				roomState.Members = append(roomState.Members, member)
+			}
+		}
+
+		if roomPowerLevels != nil {
+			powerLevels, err := me.connector.GetRoomPowerLevels(ctx, me.reconciliatorUserId, roomId)
+			if err != nil {
+				return nil, fmt.Errorf("failed determining power levels of %s: %s", roomId, err)
+			}
+			roomState.PowerLevels = powerLevels
+		}
+
+		roomStates = append(roomStates, roomState)
+	}
+
+	return roomStates, nil
+}
+
+// determineUserAdministrativeStates populates the parts of users' state which are only available via admin APIs
+// and which need to be fetched individually for each user.
+//
+// To avoid needless API calls, they're only fetched for users whose policy manages them.
+func (me *Reconciler) determineUserAdministrativeStates(ctx *connector.AccessTokenContext, policy *policy.Policy, currentState *connector.CurrentState) error {
+	for idx := range currentState.Users {
+		userState := &currentState.Users[idx]
+
+		if userState.IsDeactivatedOnHomeserver {
+			// There's no way (and no need) to find out more about such users.
+			continue
+		}
+
+		userPolicy := policy.GetUserPolicyByUserId(userState.Id)
+		if userPolicy == nil {
+			continue
+		}
+
+		if userPolicy.Threepids != nil {
+			threepids, err := me.connector.GetUserThreepids(ctx, me.reconciliatorUserId, userState.Id)
+			if err != nil {
+				return fmt.Errorf("failed determining 3pids of %s: %s", userState.Id, err)
+			}
+			userState.Threepids = threepids
+		}
+
+		if userPolicy.IsRateLimitOverrideManaged() {
+			rateLimitOverride, err := me.connector.GetUserRateLimitOverride(ctx, me.reconciliatorUserId, userState.Id)
+			if err != nil {
+				return fmt.Errorf("failed determining rate limit override of %s: %s", userState.Id, err)
+			}
+			userState.RateLimitOverride = rateLimitOverride
+		}
+	}
+
+	return nil
+}
+
+// determineUserAccountDataStates populates the account data, room tags and push rules of users in the current state.
+//
+// There's no way to list all of a user's account data, so only what the policy manages is fetched.
+func (me *Reconciler) determineUserAccountDataStates(ctx *connector.AccessTokenContext, policy *policy.Policy, currentState *connector.CurrentState) error {
+	for idx := range currentState.Users {
+		userState := &currentState.Users[idx]
+
+		if !userState.Active || userState.IsDeactivatedOnHomeserver {
+			// Inactive users don't get their account data reconciled anyway.
+			continue
+		}
+
+		userPolicy := policy.GetUserPolicyByUserId(userState.Id)
+		if userPolicy == nil {
+			continue
+		}
+
+		userState.AccountData = map[string]map[string]interface{}{}
+		for accountDataType := range userPolicy.AccountData {
+			content, err := me.connector.GetUserAccountDataContentByType(ctx, userState.Id, accountDataType)
+			if err != nil {
+				return fmt.Errorf("failed determining %s account data of %s: %s", accountDataType, userState.Id, err)
+			}
+			userState.AccountData[accountDataType] = content
+		}
+
+		userState.RoomTags = map[string]map[string]matrix.RoomTag{}
+		for _, room := range userPolicy.JoinedRooms {
+			if room.Tags == nil {
+				continue
+			}
+
+			tags, err := me.connector.GetUserRoomTags(ctx, userState.Id, room.RoomId)
+			if err != nil {
+				return fmt.Errorf("failed determining tags of %s for %s: %s", room.RoomId, userState.Id, err)
+			}
+			userState.RoomTags[room.RoomId] = tags
+		}
+
+		if len(policy.PushRules) != 0 || len(userPolicy.PushRules) != 0 {
+			pushRules, err := me.connector.GetUserPushRules(ctx, userState.Id)
+			if err != nil {
+				return fmt.Errorf("failed determining push rules of %s: %s", userState.Id, err)
+			}
+			userState.PushRules = pushRules
+		}
+	}
+
+	return nil
+}
+
+// determineUserDeviceStates populates the devices of users in the current state.
+//
+// Only users whose devices the policy restricts are considered.
+func (me *Reconciler) determineUserDeviceStates(policy *policy.Policy, currentState *connector.CurrentState) error {
+	for idx := range currentState.Users {
+		userState := &currentState.Users[idx]
+
+		if !userState.Active || userState.IsDeactivatedOnHomeserver {
+			// Inactive users have no devices anyway.
+			continue
+		}
+
+		userPolicy := policy.GetUserPolicyByUserId(userState.Id)
+		if userPolicy == nil || !userPolicy.HasDeviceRestrictions() {
+			continue
+		}
+
+		devices, err := me.connector.GetUserDevices(userState.Id)
+		if err != nil {
+			return fmt.Errorf("failed determining devices of %s: %s", userState.Id, err)
+		}
+		userState.Devices = devices
 	}
 
 	return nil
@@ -223,6 +444,308 @@ func (me *Reconciler) reconcileForActionUserDeactivate(ctx *connector.AccessToke
 	return nil
 }
 
+func (me *Reconciler) reconcileForActionUserSetThreepids(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	threepids, err := action.GetPayloadDataByKey("threepids")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.SetUserThreepids(ctx, me.reconciliatorUserId, userId, threepids.([]connector.CurrentUserThreepidState))
+	if err != nil {
+		return fmt.Errorf("failed setting 3pids for %s: %s", userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserPromoteToServerAdmin(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.SetUserServerAdmin(ctx, me.reconciliatorUserId, userId, true)
+	if err != nil {
+		return fmt.Errorf("failed promoting %s to server admin: %s", userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserDemoteFromServerAdmin(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.SetUserServerAdmin(ctx, me.reconciliatorUserId, userId, false)
+	if err != nil {
+		return fmt.Errorf("failed demoting %s from server admin: %s", userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserSetRateLimitOverride(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	messagesPerSecond, err := action.GetIntPayloadDataByKey("messagesPerSecond")
+	if err != nil {
+		return err
+	}
+
+	burstCount, err := action.GetIntPayloadDataByKey("burstCount")
+	if err != nil {
+		return err
+	}
+
+	rateLimitOverride := &connector.CurrentUserRateLimitOverrideState{
+		MessagesPerSecond: messagesPerSecond,
+		BurstCount:        burstCount,
+	}
+
+	err = me.connector.SetUserRateLimitOverride(ctx, me.reconciliatorUserId, userId, rateLimitOverride)
+	if err != nil {
+		return fmt.Errorf("failed setting rate limit override for %s: %s", userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserRemoveRateLimitOverride(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.SetUserRateLimitOverride(ctx, me.reconciliatorUserId, userId, nil)
+	if err != nil {
+		return fmt.Errorf("failed removing rate limit override for %s: %s", userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserSetAccountData(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	accountDataType, err := action.GetStringPayloadDataByKey("type")
+	if err != nil {
+		return err
+	}
+
+	content, err := action.GetPayloadDataByKey("content")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.SetUserAccountDataContentByType(ctx, userId, accountDataType, content.(map[string]interface{}))
+	if err != nil {
+		return fmt.Errorf("failed setting %s account data for %s: %s", accountDataType, userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserSetRoomTag(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	roomId, err := action.GetStringPayloadDataByKey("roomId")
+	if err != nil {
+		return err
+	}
+
+	tag, err := action.GetStringPayloadDataByKey("tag")
+	if err != nil {
+		return err
+	}
+
+	content, err := action.GetPayloadDataByKey("content")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.SetUserRoomTag(ctx, userId, roomId, tag, content.(matrix.RoomTag))
+	if err != nil {
+		return fmt.Errorf("failed setting tag %s on %s for %s: %s", tag, roomId, userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserRemoveRoomTag(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	roomId, err := action.GetStringPayloadDataByKey("roomId")
+	if err != nil {
+		return err
+	}
+
+	tag, err := action.GetStringPayloadDataByKey("tag")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.RemoveUserRoomTag(ctx, userId, roomId, tag)
+	if err != nil {
+		return fmt.Errorf("failed removing tag %s from %s for %s: %s", tag, roomId, userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserSetPushRule(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	pushRule, err := action.GetPayloadDataByKey("pushRule")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.SetUserPushRule(ctx, userId, pushRule.(connector.CurrentUserPushRuleState))
+	if err != nil {
+		return fmt.Errorf("failed setting push rule %s for %s: %s", pushRule.(connector.CurrentUserPushRuleState).RuleId, userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserLogoutDevices(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.LogoutAllDevicesForUser(ctx, me.reconciliatorUserId, userId)
+	if err != nil {
+		return fmt.Errorf("failed logging out all devices for %s: %s", userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserDeleteDevices(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	deviceIds, err := action.GetPayloadDataByKey("deviceIds")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.DeleteUserDevices(userId, deviceIds.([]string))
+	if err != nil {
+		return fmt.Errorf("failed deleting devices %v of %s: %s", deviceIds, userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserRemoveAvatar(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	// Reading an empty avatar URI gives us an avatar with no content, which stands for avatar removal.
+	avatar, err := me.avatarReader.Read("")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.SetUserAvatar(ctx, userId, avatar)
+	if err != nil {
+		return fmt.Errorf("failed removing user avatar for %s: %s", userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserRedactMessages(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	limit, err := action.GetIntPayloadDataByKey("limit")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.RedactUserMessages(ctx, me.reconciliatorUserId, userId, limit)
+	if err != nil {
+		return fmt.Errorf("failed redacting messages for %s: %s", userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserDeactivateOnHomeserver(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	erase, err := action.GetPayloadDataByKey("erase")
+	if err != nil {
+		return err
+	}
+
+	if userId == me.reconciliatorUserId {
+		// Deactivating ourselves would make any further reconciliation impossible.
+		me.logger.Warnf("Refusing to deactivate the reconciliator user (%s) on the homeserver", userId)
+		return nil
+	}
+
+	err = me.connector.DeactivateUserOnHomeserver(ctx, me.reconciliatorUserId, userId, erase.(bool))
+	if err != nil {
+		return fmt.Errorf("failed deactivating %s on the homeserver: %s", userId, err)
+	}
+
+	return nil
+}
+
+func (me *Reconciler) reconcileForActionUserReactivateOnHomeserver(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	password, err := action.GetStringPayloadDataByKey("password")
+	if err != nil {
+		return err
+	}
+
+	err = me.connector.ReactivateUserOnHomeserver(ctx, me.reconciliatorUserId, userId, password)
+	if err != nil {
+		return fmt.Errorf("failed reactivating %s on the homeserver: %s", userId, err)
+	}
+
+	return nil
+}
+
 func (me *Reconciler) reconcileForActionRoomJoin(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
 	userId, err := action.GetStringPayloadDataByKey("userId")
 	if err != nil {
@@ -270,6 +793,31 @@ func (me *Reconciler) reconcileForActionRoomUsersSetPowerLevels(ctx *connector.A
 	return me.connector.UpdateRoomUserPowerLevel(ctx, me.reconciliatorUserId, roomPowerForUserId.(map[string]int), roomId)
 }
 
+func (me *Reconciler) reconcileForActionRoomSetPowerLevels(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	roomId, err := action.GetStringPayloadDataByKey("roomId")
+	if err != nil {
+		return err
+	}
+
+	roomPowerForUserId, err := action.GetPayloadDataByKey("roomPowerForUserId")
+	if err != nil {
+		return err
+	}
+
+	roomPowerLevels, err := action.GetPayloadDataByKey("roomPowerLevels")
+	if err != nil {
+		return err
+	}
+
+	return me.connector.UpdateRoomPowerLevels(
+		ctx,
+		me.reconciliatorUserId,
+		roomId,
+		roomPowerForUserId.(map[string]int),
+		roomPowerLevels.(*matrix.RoomPowerLevelsContent),
+	)
+}
+
 func (me *Reconciler) reconcileForActionRoomUserSetPowerLevel(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
 	userId, err := action.GetStringPayloadDataByKey("userId")
 	if err != nil {
@@ -291,3 +839,31 @@ func (me *Reconciler) reconcileForActionRoomUserSetPowerLevel(ctx *connector.Acc
 
 	return me.connector.UpdateRoomUserPowerLevel(ctx, me.reconciliatorUserId, roomPowerForUserId, roomId)
 }
+
+func (me *Reconciler) reconcileForActionRoomKick(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	roomId, err := action.GetStringPayloadDataByKey("roomId")
+	if err != nil {
+		return err
+	}
+
+	return me.connector.KickUserFromRoom(ctx, me.reconciliatorUserId, userId, roomId)
+}
+
+func (me *Reconciler) reconcileForActionRoomBan(ctx *connector.AccessTokenContext, action *reconciliation.StateAction) error {
+	userId, err := action.GetStringPayloadDataByKey("userId")
+	if err != nil {
+		return err
+	}
+
+	roomId, err := action.GetStringPayloadDataByKey("roomId")
+	if err != nil {
+		return err
+	}
+
+	return me.connector.BanUserFromRoom(ctx, me.reconciliatorUserId, userId, roomId)
+}
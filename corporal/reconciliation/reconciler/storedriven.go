@@ -50,47 +50,112 @@ func (me *StoreDrivenReconciler) Stop() {
 	me.logger.Infof("Stopped store-driven reconciler")
 }
 
+// timeBoundaryMaxWaitDuration caps how long we sleep while waiting for the next policy time boundary (see `policy.Policy.GetNextTimeBoundaryAfter()`).
+// Boundaries further away than this are simply waited for in multiple steps.
+const timeBoundaryMaxWaitDuration = 24 * time.Hour
+
 func (me *StoreDrivenReconciler) listenOnChannel(channel chan *policy.Policy) {
-	for {
-		policy, more := <-channel
+	var currentPolicy *policy.Policy
+
+	// Time-bounded user activation and room memberships (`activeFrom`/`activeUntil`) change the effective policy
+	// without the policy itself changing, so we reconcile again whenever the next time boundary is reached.
+	var boundaryTimer *time.Timer
+	var boundaryTimerChannel <-chan time.Time
+	var boundaryTime *time.Time
+
+	scheduleBoundaryTimer := func() {
+		if boundaryTimer != nil {
+			boundaryTimer.Stop()
+			boundaryTimer = nil
+			boundaryTimerChannel = nil
+		}
 
-		if !more {
+		boundaryTime = currentPolicy.GetNextTimeBoundaryAfter(time.Now())
+		if boundaryTime == nil {
 			return
 		}
 
-		me.logger.Infof("Store-driven reconciler received a new policy from the store")
+		wait := determineTimeBoundaryWaitDuration(*boundaryTime, time.Now())
 
-		me.lockReconciler.Lock()
+		boundaryTimer = time.NewTimer(wait)
+		boundaryTimerChannel = boundaryTimer.C
 
-		// We may still be potentially retrying some old policy.
-		// Let's stop that and attempt to load the new one below.
-		if me.retryTicker != nil {
-			me.retryTicker.Stop()
-			me.retryCancel <- true
+		me.logger.Debugf("Store-driven reconciler will wake up in %s for the policy time boundary at %s", wait, boundaryTime.Format(time.RFC3339))
+	}
 
-			me.retryTicker = nil
-			me.retryCancel = nil
-		}
+	for {
+		select {
+		case policy, more := <-channel:
+			if !more {
+				if boundaryTimer != nil {
+					boundaryTimer.Stop()
+				}
+				return
+			}
 
-		me.logger.Infof("Reconciling..")
-		err := me.reconciler.Reconcile(policy)
-		if err == nil {
-			me.logger.Infof("Reconciliation completed")
-		} else {
-			me.logger.Warnf("Reconciliation failed: %s", err)
-		}
+			me.logger.Infof("Store-driven reconciler received a new policy from the store")
+
+			currentPolicy = policy
 
-		me.lockReconciler.Unlock()
+		case <-boundaryTimerChannel:
+			boundaryTimer = nil
+			boundaryTimerChannel = nil
 
-		if err != nil {
-			me.retryTicker = time.NewTicker(
-				time.Duration(me.retryIntervalMilliseconds) * time.Millisecond,
-			)
-			// Buffered signalling channel, so we can avoid getting stuck if the retrier had exited
-			me.retryCancel = make(chan bool, 1)
-			go me.retryReconciliation(me.retryTicker, me.retryCancel, policy)
-			me.logger.Infof("Will retry reconciliation after %d ms..", me.retryIntervalMilliseconds)
+			if time.Now().Before(*boundaryTime) {
+				// We've only waited for timeBoundaryMaxWaitDuration. The boundary is still ahead of us.
+				scheduleBoundaryTimer()
+				continue
+			}
+
+			me.logger.Infof("Store-driven reconciler reached a policy time boundary (%s)", boundaryTime.Format(time.RFC3339))
 		}
+
+		me.reconcile(currentPolicy)
+
+		scheduleBoundaryTimer()
+	}
+}
+
+// determineTimeBoundaryWaitDuration returns how long to wait (at most timeBoundaryMaxWaitDuration) for the given time boundary
+func determineTimeBoundaryWaitDuration(boundaryTime time.Time, now time.Time) time.Duration {
+	wait := boundaryTime.Sub(now)
+	if wait > timeBoundaryMaxWaitDuration {
+		wait = timeBoundaryMaxWaitDuration
+	}
+	return wait
+}
+
+func (me *StoreDrivenReconciler) reconcile(policy *policy.Policy) {
+	me.lockReconciler.Lock()
+
+	// We may still be potentially retrying some old policy (or some old time boundary for the same policy).
+	// Let's stop that and attempt to load the new one below.
+	if me.retryTicker != nil {
+		me.retryTicker.Stop()
+		me.retryCancel <- true
+
+		me.retryTicker = nil
+		me.retryCancel = nil
+	}
+
+	me.logger.Infof("Reconciling..")
+	err := me.reconciler.Reconcile(policy)
+	if err == nil {
+		me.logger.Infof("Reconciliation completed")
+	} else {
+		me.logger.Warnf("Reconciliation failed: %s", err)
+	}
+
+	me.lockReconciler.Unlock()
+
+	if err != nil {
+		me.retryTicker = time.NewTicker(
+			time.Duration(me.retryIntervalMilliseconds) * time.Millisecond,
+		)
+		// Buffered signalling channel, so we can avoid getting stuck if the retrier had exited
+		me.retryCancel = make(chan bool, 1)
+		go me.retryReconciliation(me.retryTicker, me.retryCancel, policy)
+		me.logger.Infof("Will retry reconciliation after %d ms..", me.retryIntervalMilliseconds)
 	}
 }
 
@@ -0,0 +1,122 @@
+package reconciler
+
+import (
+	"devture-matrix-corporal/corporal/connector"
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/policy"
+	"devture-matrix-corporal/corporal/userauth"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testStateConnector is a connector which only reports (via a channel) that the current state is being determined.
+// Determining the state always fails, so that reconciliation stops right there.
+// Calling any other method panics.
+type testStateConnector struct {
+	connector.MatrixConnector
+
+	calls chan time.Time
+}
+
+func (me *testStateConnector) DetermineCurrentState(ctx *connector.AccessTokenContext, managedUserIds []string, adminUserId string) (*connector.CurrentState, error) {
+	me.calls <- time.Now()
+	return nil, fmt.Errorf("not implemented")
+}
+
+func TestDetermineTimeBoundaryWaitDuration(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	type testCase struct {
+		boundaryTime time.Time
+		expectedWait time.Duration
+	}
+
+	testCases := []testCase{
+		{boundaryTime: now.Add(5 * time.Minute), expectedWait: 5 * time.Minute},
+		{boundaryTime: now.Add(timeBoundaryMaxWaitDuration), expectedWait: timeBoundaryMaxWaitDuration},
+		// Far away boundaries are waited for in multiple steps
+		{boundaryTime: now.Add(30 * 24 * time.Hour), expectedWait: timeBoundaryMaxWaitDuration},
+	}
+
+	for idx, tc := range testCases {
+		wait := determineTimeBoundaryWaitDuration(tc.boundaryTime, now)
+		if wait != tc.expectedWait {
+			t.Errorf("test case #%d: expected wait %s, got %s", idx, tc.expectedWait, wait)
+		}
+	}
+}
+
+func TestStoreDrivenReconcilerReconcilesAtTimeBoundary(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hookStore := hook.NewStore()
+	roomAliasResolver := policy.NewRoomAliasResolver(logger, nil, time.Minute)
+	policyStore := policy.NewStore(
+		logger,
+		policy.NewValidator("host", hookStore, roomAliasResolver),
+		roomAliasResolver,
+		hookStore,
+	)
+
+	stateConnector := &testStateConnector{calls: make(chan time.Time, 10)}
+
+	storeDrivenReconciler := NewStoreDrivenReconciler(
+		logger,
+		policyStore,
+		New(logger, stateConnector, nil, "@corporal:host", nil, nil, hookStore),
+		// Retrying is not what we're testing, so make sure it doesn't get in the way
+		int(time.Hour/time.Millisecond),
+	)
+
+	err := storeDrivenReconciler.Start()
+	if err != nil {
+		t.Fatalf("failed starting: %s", err)
+	}
+	defer storeDrivenReconciler.Stop()
+
+	activeFrom := time.Now().Add(300 * time.Millisecond)
+
+	err = policyStore.Set(&policy.Policy{
+		SchemaVersion: 2,
+		User: []*policy.UserPolicy{
+			{
+				Id:         "@a:host",
+				Active:     true,
+				AuthType:   userauth.UserAuthTypePassthrough,
+				ActiveFrom: &activeFrom,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed setting policy: %s", err)
+	}
+
+	waitForReconciliation := func(description string) time.Time {
+		select {
+		case calledAt := <-stateConnector.calls:
+			return calledAt
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for reconciliation %s", description)
+		}
+		return time.Time{}
+	}
+
+	waitForReconciliation("on policy change")
+
+	calledAt := waitForReconciliation("at the time boundary")
+	if calledAt.Before(activeFrom) {
+		t.Errorf("expected reconciliation at (or after) the time boundary %s, got it at %s", activeFrom, calledAt)
+	}
+
+	// There are no more time boundaries, so nothing else should happen
+	select {
+	case calledAt := <-stateConnector.calls:
+		t.Errorf("expected no further reconciliation, got one at %s", calledAt)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
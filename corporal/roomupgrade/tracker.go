@@ -0,0 +1,120 @@
+package roomupgrade
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/policy"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Upgrade describes a managed room that was upgraded (replaced by a new room)
+type Upgrade struct {
+	// RoomId is the ID of the old (now tombstoned) room
+	RoomId string `json:"roomId"`
+
+	// ReplacementRoomId is the ID of the new room, which replaces RoomId
+	ReplacementRoomId string `json:"replacementRoomId"`
+
+	// UserId is the full Matrix User ID (MXID) of the user who upgraded the room
+	UserId string `json:"userId"`
+
+	// UpgradedAt is the time we've observed the upgrade at
+	UpgradedAt time.Time `json:"upgradedAt"`
+}
+
+// maxUpgrades is the number of most recent upgrades that a Tracker remembers.
+// Older upgrades are forgotten, so that memory usage stays bounded on long-running instances.
+const maxUpgrades = 1000
+
+// Tracker keeps track of managed rooms that got upgraded, so that whoever generates the policy can follow them.
+//
+// Upgrades are only kept in memory, so they're lost when matrix-corporal restarts.
+// Only the most recent maxUpgrades upgrades are remembered.
+// Interested parties are expected to either poll for upgrades (see List) or get notified
+// via hooks of type hook.EventTypeManagedRoomUpgraded.
+type Tracker struct {
+	policyStore          *policy.Store
+	hookStore            *hook.Store
+	restServiceConsultor *hook.RESTServiceConsultor
+	logger               *logrus.Logger
+
+	lock     sync.RWMutex
+	upgrades []Upgrade
+}
+
+func NewTracker(
+	policyStore *policy.Store,
+	hookStore *hook.Store,
+	restServiceConsultor *hook.RESTServiceConsultor,
+	logger *logrus.Logger,
+) *Tracker {
+	return &Tracker{
+		policyStore:          policyStore,
+		hookStore:            hookStore,
+		restServiceConsultor: restServiceConsultor,
+		logger:               logger,
+
+		upgrades: []Upgrade{},
+	}
+}
+
+// Record remembers the given upgrade (forgetting the oldest one if the limit is reached) and notifies the REST services of all interested hooks about it
+func (me *Tracker) Record(upgrade Upgrade) {
+	logger := me.logger.WithField("roomId", upgrade.RoomId)
+	logger = logger.WithField("replacementRoomId", upgrade.ReplacementRoomId)
+	logger = logger.WithField("userId", upgrade.UserId)
+
+	logger.Infof("Managed room got upgraded")
+
+	me.lock.Lock()
+	me.upgrades = append(me.upgrades, upgrade)
+	if len(me.upgrades) > maxUpgrades {
+		me.upgrades = me.upgrades[len(me.upgrades)-maxUpgrades:]
+	}
+	me.lock.Unlock()
+
+	me.emitHookEvents(upgrade, logger)
+}
+
+// List returns the most recent upgrades recorded so far (oldest first)
+func (me *Tracker) List() []Upgrade {
+	me.lock.RLock()
+	defer me.lock.RUnlock()
+
+	upgrades := make([]Upgrade, len(me.upgrades))
+	copy(upgrades, me.upgrades)
+
+	return upgrades
+}
+
+func (me *Tracker) emitHookEvents(upgrade Upgrade, logger *logrus.Entry) {
+	policyObj := me.policyStore.Get()
+	if policyObj == nil {
+		return
+	}
+
+	event := hook.RoomUpgradeEvent{
+		EventType:         hook.EventTypeManagedRoomUpgraded,
+		UserId:            upgrade.UserId,
+		RoomId:            upgrade.RoomId,
+		ReplacementRoomId: upgrade.ReplacementRoomId,
+	}
+
+	for _, hookObj := range me.hookStore.GetMerged() {
+		if hookObj.EventType != event.EventType {
+			continue
+		}
+
+		hookLogger := logger.WithField("hookId", hookObj.ID)
+		hookLogger = hookLogger.WithField("hookEventType", hookObj.EventType)
+
+		hookLogger.Infof("Executing room upgrade hook")
+
+		err := me.restServiceConsultor.NotifyAboutRoomUpgradeEvent(event, *hookObj, hookLogger)
+		if err != nil {
+			hookLogger.Warnf("Failed executing room upgrade hook: %s", err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package roomupgrade
+
+import (
+	"devture-matrix-corporal/corporal/hook"
+	"devture-matrix-corporal/corporal/policy"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func createTestTracker(t *testing.T, hooks []*hook.Hook) *Tracker {
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	hookStore := hook.NewStore()
+	roomAliasResolver := policy.NewRoomAliasResolver(logger, nil, time.Minute)
+	policyStore := policy.NewStore(
+		logger,
+		policy.NewValidator("host", hookStore, roomAliasResolver),
+		roomAliasResolver,
+		hookStore,
+	)
+
+	err := policyStore.Set(&policy.Policy{SchemaVersion: 2, Hooks: hooks})
+	if err != nil {
+		t.Fatalf("failed setting policy: %s", err)
+	}
+
+	return NewTracker(policyStore, hookStore, hook.NewRESTServiceConsultor(5*time.Second), logger)
+}
+
+func TestTrackerRecordAndList(t *testing.T) {
+	tracker := createTestTracker(t, nil)
+
+	if len(tracker.List()) != 0 {
+		t.Fatalf("expected no upgrades initially, got: %v", tracker.List())
+	}
+
+	first := Upgrade{RoomId: "!a:host", ReplacementRoomId: "!b:host", UserId: "@a:host", UpgradedAt: time.Unix(1000, 0)}
+	second := Upgrade{RoomId: "!b:host", ReplacementRoomId: "!c:host", UserId: "@a:host", UpgradedAt: time.Unix(2000, 0)}
+
+	tracker.Record(first)
+	tracker.Record(second)
+
+	upgrades := tracker.List()
+	if !reflect.DeepEqual(upgrades, []Upgrade{first, second}) {
+		t.Fatalf("expected upgrades oldest first, got: %v", upgrades)
+	}
+
+	// Modifying the returned list should not affect the tracker
+	upgrades[0].RoomId = "!modified:host"
+	if tracker.List()[0].RoomId != first.RoomId {
+		t.Errorf("expected List() to return a copy")
+	}
+}
+
+func TestTrackerForgetsOldestUpgrades(t *testing.T) {
+	tracker := createTestTracker(t, nil)
+
+	for i := 0; i < maxUpgrades+5; i++ {
+		tracker.Record(Upgrade{
+			RoomId:            fmt.Sprintf("!room%d:host", i),
+			ReplacementRoomId: fmt.Sprintf("!room%d:host", i+1),
+			UserId:            "@a:host",
+		})
+	}
+
+	upgrades := tracker.List()
+	if len(upgrades) != maxUpgrades {
+		t.Fatalf("expected %d upgrades, got %d", maxUpgrades, len(upgrades))
+	}
+
+	if upgrades[0].RoomId != "!room5:host" {
+		t.Errorf("expected the oldest remaining upgrade to be for !room5:host, got %s", upgrades[0].RoomId)
+	}
+
+	expectedNewestRoomId := fmt.Sprintf("!room%d:host", maxUpgrades+4)
+	if upgrades[len(upgrades)-1].RoomId != expectedNewestRoomId {
+		t.Errorf("expected the newest upgrade to be for %s, got %s", expectedNewestRoomId, upgrades[len(upgrades)-1].RoomId)
+	}
+}
+
+func TestTrackerNotifiesHooks(t *testing.T) {
+	type notification struct {
+		hookId string
+		event  hook.RoomUpgradeEvent
+	}
+
+	notifications := make(chan notification, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Meta struct {
+				HookID string `json:"hookId"`
+			} `json:"meta"`
+			Event hook.RoomUpgradeEvent `json:"event"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		notifications <- notification{hookId: payload.Meta.HookID, event: payload.Event}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	hooksJson := fmt.Sprintf(`[
+		{"id": "room-upgraded", "eventType": "managedRoomUpgraded", "action": "consult.RESTServiceURL", "RESTServiceURL": "%s"},
+		{"id": "user-created", "eventType": "reconciliationUserCreated", "action": "consult.RESTServiceURL", "RESTServiceURL": "%s"}
+	]`, server.URL, server.URL)
+
+	var hooks []*hook.Hook
+	err := json.Unmarshal([]byte(hooksJson), &hooks)
+	if err != nil {
+		t.Fatalf("failed parsing hooks: %s", err)
+	}
+
+	tracker := createTestTracker(t, hooks)
+
+	tracker.Record(Upgrade{RoomId: "!a:host", ReplacementRoomId: "!b:host", UserId: "@a:host"})
+
+	expectedEvent := hook.RoomUpgradeEvent{
+		EventType:         hook.EventTypeManagedRoomUpgraded,
+		UserId:            "@a:host",
+		RoomId:            "!a:host",
+		ReplacementRoomId: "!b:host",
+	}
+
+	select {
+	case n := <-notifications:
+		if n.hookId != "room-upgraded" {
+			t.Errorf("expected hook room-upgraded to be notified, got %s", n.hookId)
+		}
+		if !reflect.DeepEqual(n.event, expectedEvent) {
+			t.Errorf("expected event %#v, got %#v", expectedEvent, n.event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the REST service to be notified")
+	}
+
+	select {
+	case n := <-notifications:
+		t.Errorf("expected a single notification, also got one for hook %s", n.hookId)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
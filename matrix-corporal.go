@@ -21,8 +21,10 @@ package main
 import (
 	"devture-matrix-corporal/corporal/configuration"
 	"devture-matrix-corporal/corporal/container"
+	hookProvider "devture-matrix-corporal/corporal/hook/provider"
 	"devture-matrix-corporal/corporal/httpapi"
 	"devture-matrix-corporal/corporal/httpgateway"
+	"devture-matrix-corporal/corporal/policy"
 	"devture-matrix-corporal/corporal/policy/provider"
 	"devture-matrix-corporal/corporal/reconciliation/reconciler"
 	"flag"
@@ -114,6 +116,22 @@ BuildDate: %s
 		panic(err)
 	}
 
+	roomAliasResolver := container.Get("policy.room_alias_resolver").(*policy.RoomAliasResolver)
+	err = roomAliasResolver.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	// Hooks are not part of the policy, so they don't influence reconciliation.
+	// Still, we'd rather have them loaded before the policy, so that requests don't go through without them.
+	if configuration.Hooks.Path != "" {
+		hookFileProvider := container.Get("hook.provider.file").(*hookProvider.FileProvider)
+		err = hookFileProvider.Start()
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	policyProvider := container.Get("policy.provider").(provider.Provider)
 	err = policyProvider.Start()
 	if err != nil {